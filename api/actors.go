@@ -0,0 +1,69 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultTimelineLimit is the page size of an actor's timeline without a "limit".
+const defaultTimelineLimit = 50
+
+// actorResponse is the body of /actors/{login}: the actor's profile and a page of its events.
+type actorResponse struct {
+	models.ActorProfile
+	Timeline pageResponse `json:"timeline"`
+}
+
+// GetActor returns the profile of the actor named by the "login" path
+// variable over the time window (see parseTimeRange) with a page of its events
+// in the window, newest first unless "sort" is created_at (see parseSort),
+// paginated with "limit" and "cursor" (see parsePage).
+func GetActor(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := parseTimeRange(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		descending, err := parseSort(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, err := parsePage(r, defaultTimelineLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		login := mux.Vars(r)["login"]
+
+		// Query the store to get the actor's profile
+		profile, err := eventStore.ActorProfile(login, window)
+		if err == store.ErrNotFound {
+			http.Error(w, "actor not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		// Query the store to get a page of the actor's events
+		filter := models.EventFilter{TimeRange: window, Actors: []string{login}}
+		events, next, err := eventStore.ListEvents(filter, descending, page)
+		if err == store.ErrInvalidCursor {
+			http.Error(w, "cursor is invalid", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, actorResponse{ActorProfile: profile, Timeline: pageResponse{Items: events, NextCursor: encodeCursor(next)}})
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetActor(t *testing.T) {
+	eventStore := setupTestStore(t)
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	push := func(emails ...string) models.Payload {
+		var payload models.Payload
+		for _, email := range emails {
+			payload.Commits = append(payload.Commits, models.Commit{Author: models.Author{Email: email}})
+		}
+		return payload
+	}
+	for i, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, Payload: push("alice@work.example", "alice@home.example")},
+		{Type: "IssuesEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo2"}},
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo1"}, Payload: push("bob@example.com")},
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, Payload: push("alice@work.example")},
+	} {
+		event.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		eventStore.StoreEvent(event)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/actors/{login}", GetActor(eventStore))
+	get := func(path string) (int, actorResponse, []models.StoredEvent) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response actorResponse
+		var timeline struct {
+			Timeline struct {
+				Items []models.StoredEvent `json:"items"`
+			} `json:"timeline"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		json.Unmarshal(rr.Body.Bytes(), &timeline)
+		return rr.Code, response, timeline.Timeline.Items
+	}
+
+	code, response, events := get("/actors/alice?limit=2&sort=created_at")
+	if code != http.StatusOK {
+		t.Fatalf("Unexpected status %d", code)
+	}
+	first, last := start, start.Add(3*time.Hour)
+	expected := models.ActorProfile{
+		Login:        "alice",
+		Events:       3,
+		EventsByType: map[string]int64{"PushEvent": 2, "IssuesEvent": 1},
+		FirstSeen:    &first,
+		LastSeen:     &last,
+		Repos: []models.RepoActivity{
+			{URL: "repo1", LastSeen: last, Events: 2},
+			{URL: "repo2", LastSeen: start.Add(time.Hour), Events: 1},
+		},
+		Emails: []string{"alice@home.example", "alice@work.example"},
+	}
+	if !reflect.DeepEqual(response.ActorProfile, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, response.ActorProfile)
+	}
+	if len(events) != 2 || events[0].Type != "PushEvent" || events[1].Type != "IssuesEvent" || response.Timeline.NextCursor == "" {
+		t.Errorf("Expected the first page of alice's timeline, got %+v", events)
+	}
+
+	_, response, events = get("/actors/alice?limit=2&sort=created_at&cursor=" + response.Timeline.NextCursor)
+	if len(events) != 1 || !events[0].CreatedAt.Equal(last) || response.Timeline.NextCursor != "" {
+		t.Errorf("Expected the last page of alice's timeline, got %+v", events)
+	}
+
+	_, response, events = get("/actors/alice?since=2024-05-01T10:30:00Z")
+	if response.Events != 1 || len(response.Repos) != 1 || len(events) != 1 {
+		t.Errorf("Expected alice's profile in the window, got %+v", response)
+	}
+
+	for path, want := range map[string]int{
+		"/actors/nobody":           http.StatusNotFound,
+		"/actors/alice?sort=type":  http.StatusBadRequest,
+		"/actors/alice?cursor=AAA": http.StatusBadRequest,
+	} {
+		if code, _, _ := get(path); code != want {
+			t.Errorf("Expected %d for %s, got %d", want, path, code)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequireAdmin only lets requests through that carry the admin token as a
+// bearer token. With an empty token admin endpoints are disabled.
+func RequireAdmin(adminToken string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// PostErasure erases the actor login or commit author email named in the
+// JSON request body ({"login": ...} or {"email": ...}) and returns the report
+// of affected rows.
+func PostErasure(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request models.ErasureRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Request body must be a JSON object with a login or an email", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := request.Subject(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		report, err := eventStore.Erase(request, time.Now())
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Erased %s: %d rows affected", report.Kind, report.TotalRows)
+
+		writeJSON(w, report)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPostErasure(t *testing.T) {
+	testStore := setupTestStore(t)
+	testStore.StoreEvent(models.GitHubEvent{
+		Type:      "PushEvent",
+		Actor:     models.Actor{Login: "alice"},
+		Repo:      models.Repo{URL: "repo"},
+		Payload:   models.Payload{Commits: []models.Commit{{Author: models.Author{Email: "alice@example.com"}}}},
+		CreatedAt: time.Now(),
+	})
+
+	router := mux.NewRouter()
+	SetupRoutes(router, testStore, setupTestBroadcaster(t, testStore), nil, "secret")
+
+	erase := func(token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/admin/erasures", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := erase("", `{"login": "alice"}`); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", rr.Code)
+	}
+	if rr := erase("wrong", `{"login": "alice"}`); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", rr.Code)
+	}
+	if rr := erase("secret", `{"login": "alice", "email": "alice@example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an ambiguous request, got %d", rr.Code)
+	}
+
+	rr := erase("secret", `{"login": "alice"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report models.ErasureReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Kind != models.ErasureActor || report.Rows["github"] != 1 || strings.Contains(rr.Body.String(), "alice") {
+		t.Errorf("Unexpected erasure report: %s", rr.Body.String())
+	}
+
+	actors, _, _ := testStore.UniqueActors(models.TimeRange{}, models.Page{Limit: 100})
+	if len(actors) != 1 || actors[0].Login != report.Pseudonym {
+		t.Errorf("Expected the actor to be pseudonymized, got %v", actors)
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	router := mux.NewRouter()
+	eventStore := setupTestStore(t)
+	SetupRoutes(router, eventStore, setupTestBroadcaster(t, eventStore), nil, "")
+
+	req, _ := http.NewRequest("POST", "/admin/erasures", strings.NewReader(`{"login": "alice"}`))
+	req.Header.Set("Authorization", "Bearer ")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when no admin token is configured, got %d", rr.Code)
+	}
+}
@@ -1,24 +1,25 @@
 package api
 
 import (
-	"database/sql"
+	"awsomeProject/pkg/broadcast"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"reflect"
 	"testing"
 	"time"
 )
 
 func TestGetEventCounts(t *testing.T) {
-	// Set up a temporary test database
-	db := setupTestDatabase(t)
-	defer db.Close()
+	// Set up an in-memory test store
+	eventStore := setupTestStore(t)
 
-	// Set up test data in the database
-	setupTestData(db)
+	// Set up test data in the store
+	setupTestData(eventStore)
 
 	// Set up a request to the endpoint
 	req, err := http.NewRequest("GET", "/event-counts", nil)
@@ -30,7 +31,7 @@ func TestGetEventCounts(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler function with the request and response recorder
-	handler := GetEventCounts(db)
+	handler := GetEventCounts(eventStore)
 	handler(rr, req)
 
 	// Check the response status code
@@ -55,61 +56,66 @@ func TestGetEventCounts(t *testing.T) {
 	}
 }
 
-// Helper function to set up a temporary test database
-func setupTestDatabase(t *testing.T) *sql.DB {
-	// Open a database connection to a test database
-
-	// Read PostgreSQL connection details from environment variables
-	dbUser := os.Getenv("PGSQL_USER")
-	dbPassword := os.Getenv("PGSQL_PASSWORD")
-	dbHost := os.Getenv("PGSQL_HOSTNAME")
-	dbPort := os.Getenv("PGSQL_PORT")
-	dbName := os.Getenv("PGSQL_DATABASE")
-
-	// Create the PostgreSQL connection string
-	connStr := "user=" + dbUser + " password=" + dbPassword + " host=" + dbHost + " port=" + dbPort + " dbname=" + dbName + " sslmode=disable"
-
-	// Connect to the PostgreSQL database
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		t.Fatalf("error connecting to database: %v", err)
-	}
-
-	// Ensure the database tables are created (you may need to modify this)
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS github (
-		id SERIAL PRIMARY KEY,
-		event_type VARCHAR(255),
-		...
-	);`)
-	if err != nil {
-		t.Fatalf("error creating table: %v", err)
+// Helper function to set up an in-memory test store
+func TestGetEventCountsReportsCountedWindow(t *testing.T) {
+	eventStore := setupTestStore(t)
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: start.Add(50 * time.Minute)})
+	eventStore.CompactRollups(models.RollupTiers{Minute: time.Hour, Hour: 48 * time.Hour}, start.Add(5*time.Hour))
+
+	// The hour starting in the window is counted in full
+	for _, handler := range []http.HandlerFunc{GetEventCounts(eventStore), GetTopActors(eventStore), GetTopRepos(eventStore)} {
+		req, _ := http.NewRequest("GET", "/?since=2024-05-01T09:30:00Z&until=2024-05-01T10:30:00Z", nil)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if since, until := rr.Header().Get("X-Counted-Since"), rr.Header().Get("X-Counted-Until"); since != "2024-05-01T09:30:00Z" || until != "2024-05-01T11:00:00Z" {
+			t.Errorf("Expected the counted window to end with the hour, got %s to %s", since, until)
+		}
 	}
+}
 
-	return db
+func setupTestStore(t *testing.T) *store.MemoryStore {
+	t.Helper()
+	return store.NewMemoryStore()
 }
 
-func setupTestData(db *sql.DB) {
-	// Insert test data into the database as needed for your test
-	_, err := db.Exec("INSERT INTO github (event_type, actor, repo_url, created_at) VALUES ($1, $2, $3, $4)", "EventType1", "Actor1", "RepoURL1", time.Now())
+// setupTestBroadcaster starts a broadcaster of the events stored in eventStore
+// that polls it often, stopping it when the test ends.
+func setupTestBroadcaster(t *testing.T, eventStore store.EventStore) *broadcast.Broadcaster {
+	t.Helper()
+	broadcaster, err := broadcast.New(eventStore, 5*time.Millisecond)
 	if err != nil {
-		log.Fatalf("error inserting test data: %v", err)
+		t.Fatalf("Error creating broadcaster: %v", err)
 	}
+	broadcaster.Start()
+	t.Cleanup(broadcaster.Stop)
+	return broadcaster
+}
 
-	// Insert more test data as needed
-	_, err = db.Exec("INSERT INTO github (event_type, actor, repo_url, created_at) VALUES ($1, $2, $3, $4)", "EventType2", "Actor2", "RepoURL2", time.Now())
-	if err != nil {
-		log.Fatalf("error inserting test data: %v", err)
+func setupTestData(eventStore store.EventStore) {
+	// Insert test data into the store as needed for your test
+	for eventType, count := range map[string]int{"EventType1": 10, "EventType2": 20, "EventType3": 5} {
+		for i := 0; i < count; i++ {
+			actor := "Actor" + eventType[len(eventType)-1:]
+			event := models.GitHubEvent{
+				Type:      eventType,
+				Actor:     models.Actor{Login: actor},
+				Repo:      models.Repo{URL: "RepoURL" + eventType[len(eventType)-1:]},
+				CreatedAt: time.Now(),
+			}
+			if _, err := eventStore.StoreEvent(event); err != nil {
+				log.Fatalf("error inserting test data: %v", err)
+			}
+		}
 	}
-
 }
 
 func TestGetUniqueActors(t *testing.T) {
-	// Set up a temporary test database
-	db := setupTestDatabase(t)
-	defer db.Close()
+	// Set up an in-memory test store
+	eventStore := setupTestStore(t)
 
-	// Set up test data in the database
-	setupTestData(db)
+	// Set up test data in the store
+	setupTestData(eventStore)
 
 	// Set up a request to the endpoint
 	req, err := http.NewRequest("GET", "/unique-actors", nil)
@@ -121,7 +127,7 @@ func TestGetUniqueActors(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler function with the request and response recorder
-	handler := GetUniqueActors(db)
+	handler := GetUniqueActors(eventStore)
 	handler(rr, req)
 
 	// Check the response status code
@@ -130,42 +136,46 @@ func TestGetUniqueActors(t *testing.T) {
 	}
 
 	// Parse the actual response body
-	var actual []string
+	var actual struct {
+		Items      []models.ActorActivity `json:"items"`
+		NextCursor string                 `json:"next_cursor"`
+	}
 	if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
 		t.Fatalf("error unmarshalling response body: %v", err)
 	}
 
-	// Compare the actual slice with the expected slice
-	expected := []string{"Actor1", "Actor2", "Actor3"}
-	if !reflect.DeepEqual(actual, expected) {
+	// Compare the event counts per actor with the expected map
+	counts := make(map[string]int64)
+	for _, actor := range actual.Items {
+		counts[actor.Login] = actor.Events
+	}
+	expected := map[string]int64{"Actor1": 10, "Actor2": 20, "Actor3": 5}
+	if !reflect.DeepEqual(counts, expected) || actual.NextCursor != "" {
 		t.Errorf("handler returned unexpected body: got %v want %v", actual, expected)
 	}
 }
 
 func TestGetUniqueEmails(t *testing.T) {
-	// Set up a temporary test database
-	db := setupTestDatabase(t)
-	defer db.Close()
+	// Set up an in-memory test store
+	eventStore := setupTestStore(t)
 
-	// Set up test data in the database
-	setupTestData(db)
+	// Set up test data in the store
+	setupTestData(eventStore)
 
 	var testUniqueEmails = []string{"email1@example.com", "email2@example.com", "email3@example.com"}
 
-	// Set the test data for unique emails in the database
-	_, err := db.Exec("INSERT INTO github (event_type, actor, repo_url, created_at) VALUES ($1, $2, $3, $4)", "EventType1", "Actor1", "url1", time.Now())
-	if err != nil {
-		t.Fatalf("error inserting test data: %v", err)
-	}
-
-	_, err = db.Exec("INSERT INTO github (event_type, actor, repo_url, created_at) VALUES ($1, $2, $3, $4)", "EventType2", "Actor2", "url2", time.Now())
-	if err != nil {
-		t.Fatalf("error inserting test data: %v", err)
-	}
-
-	_, err = db.Exec("INSERT INTO github (event_type, actor, repo_url, created_at) VALUES ($1, $2, $3, $4)", "EventType3", "Actor3", "url3", time.Now())
-	if err != nil {
-		t.Fatalf("error inserting test data: %v", err)
+	// Set the test data for unique emails in the store
+	for i, email := range testUniqueEmails {
+		event := models.GitHubEvent{
+			Type:      "PushEvent",
+			Actor:     models.Actor{Login: fmt.Sprintf("Actor%d", i+1)},
+			Repo:      models.Repo{URL: fmt.Sprintf("url%d", i+1)},
+			Payload:   models.Payload{Commits: []models.Commit{{Author: models.Author{Email: email}}}},
+			CreatedAt: time.Now(),
+		}
+		if _, err := eventStore.StoreEvent(event); err != nil {
+			t.Fatalf("error inserting test data: %v", err)
+		}
 	}
 
 	req, err := http.NewRequest("GET", "/unique-emails", nil)
@@ -175,8 +185,8 @@ func TestGetUniqueEmails(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	// Create a handler function that uses the database connection
-	handler := GetUniqueEmails(db)
+	// Create a handler function that uses the store
+	handler := GetUniqueEmails(eventStore)
 
 	handler.ServeHTTP(rr, req)
 
@@ -195,3 +205,91 @@ func TestGetUniqueEmails(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), string(expected))
 	}
 }
+
+func TestAggregatesTimeWindow(t *testing.T) {
+	eventStore := setupTestStore(t)
+	now := time.Now()
+	for _, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "recent"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: now.Add(-10 * time.Minute)},
+		{Type: "PushEvent", Actor: models.Actor{Login: "earlier"}, Repo: models.Repo{URL: "repo2"}, CreatedAt: now.Add(-5 * time.Hour)},
+	} {
+		eventStore.StoreEvent(event)
+	}
+
+	get := func(handler http.HandlerFunc, path string, v interface{}) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code == http.StatusOK {
+			json.Unmarshal(rr.Body.Bytes(), v)
+		}
+		return rr.Code
+	}
+
+	var counts map[string]int
+	get(GetEventCounts(eventStore), "/event-counts?since=1h", &counts)
+	if counts["PushEvent"] != 1 {
+		t.Errorf("Expected 1 event in the last hour, got %v", counts)
+	}
+	get(GetEventCounts(eventStore), "/event-counts?since=1d", &counts)
+	if counts["PushEvent"] != 2 {
+		t.Errorf("Expected 2 events in the last day, got %v", counts)
+	}
+
+	var actors struct{ Items []models.ActorActivity }
+	get(GetUniqueActors(eventStore), "/unique-actors?until=1h", &actors)
+	if len(actors.Items) != 1 || actors.Items[0].Login != "earlier" {
+		t.Errorf("Expected only the earlier actor before the last hour, got %v", actors)
+	}
+
+	if code := get(GetUniqueRepoURLs(eventStore), "/unique-repo-urls?since=soon", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid since, got %d", code)
+	}
+}
+
+func TestUniqueActorsPagination(t *testing.T) {
+	eventStore := setupTestStore(t)
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: fmt.Sprintf("actor%d", i)}, CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	var pages [][]string
+	cursor := ""
+	for {
+		req, _ := http.NewRequest("GET", "/unique-actors?limit=2&cursor="+cursor, nil)
+		rr := httptest.NewRecorder()
+		GetUniqueActors(eventStore)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+		var page struct {
+			Items      []models.ActorActivity `json:"items"`
+			NextCursor string                 `json:"next_cursor"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &page)
+		var logins []string
+		for _, actor := range page.Items {
+			logins = append(logins, actor.Login)
+		}
+		pages = append(pages, logins)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	expected := [][]string{{"actor1", "actor2"}, {"actor3", "actor4"}, {"actor5"}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("Expected pages %v, got %v", expected, pages)
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "cursor=%25%25", "cursor=" + encodeCursor("not a key")} {
+		req, _ := http.NewRequest("GET", "/unique-actors?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetUniqueActors(eventStore)(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
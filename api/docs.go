@@ -0,0 +1,69 @@
+package api
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 document of the routes set up by SetupRoutes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsScript and docsStyle render /openapi.json in the browser, with a form
+// per operation that sends it. They are
+// inlined into docsPage so /docs loads no code from outside the binary.
+var (
+	//go:embed docs/docs.js
+	docsScript string
+	//go:embed docs/docs.css
+	docsStyle string
+)
+
+// docsPage is the HTML of /docs.
+var docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>GitHub Events Processor API</title>
+  <style>` + docsStyle + `</style>
+</head>
+<body>
+  <div id="docs">Loading /openapi.json...</div>
+  <script>` + docsScript + `</script>
+</body>
+</html>
+`
+
+// docsPolicy is the Content-Security-Policy of /docs, which only allows the
+// inlined script and style, and requests to this server: /openapi.json and
+// the requests sent from the page.
+var docsPolicy = fmt.Sprintf("default-src 'none'; connect-src 'self'; script-src '%s'; style-src '%s'",
+	cspHash(docsScript), cspHash(docsStyle))
+
+// cspHash returns the Content-Security-Policy source of an inline script or style.
+func cspHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// GetOpenAPISpec returns the OpenAPI document of the API.
+func GetOpenAPISpec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	}
+}
+
+// GetDocs serves the interactive documentation of the API.
+func GetDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		io.WriteString(w, docsPage)
+	}
+}
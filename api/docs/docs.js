@@ -0,0 +1,210 @@
+// Renders the OpenAPI document of the API as a list of operations, each with
+// a form that sends the request to this server and shows the response.
+(function () {
+  "use strict";
+
+  var root = document.getElementById("docs");
+
+  function element(tag, className, text) {
+    var node = document.createElement(tag);
+    if (className) node.className = className;
+    if (text !== undefined && text !== null) node.textContent = text;
+    return node;
+  }
+
+  // resolve follows a local reference such as #/components/schemas/StoredEvent
+  function resolve(spec, node) {
+    if (!node || !node.$ref) return node;
+    return node.$ref.replace(/^#\//, "").split("/").reduce(function (value, name) {
+      return value && value[name];
+    }, spec);
+  }
+
+  // typeName describes a schema in one line
+  function typeName(spec, schema) {
+    if (!schema) return "";
+    if (schema.$ref) return schema.$ref.split("/").pop();
+    if (schema.type === "array") return "[" + typeName(spec, schema.items) + "]";
+    if (schema.enum) return schema.enum.join(" | ");
+    return schema.format ? schema.type + " (" + schema.format + ")" : schema.type || "object";
+  }
+
+  function renderSchema(spec, name, schema) {
+    var section = element("section", "schema");
+    section.id = "schema-" + name;
+    section.appendChild(element("h3", null, name));
+    if (schema.description) section.appendChild(element("p", null, schema.description));
+    var properties = schema.properties || {};
+    var table = element("table");
+    Object.keys(properties).forEach(function (property) {
+      var row = element("tr");
+      row.appendChild(element("td", "name", property));
+      row.appendChild(element("td", "type", typeName(spec, properties[property])));
+      row.appendChild(element("td", null, properties[property].description));
+      table.appendChild(row);
+    });
+    if (table.children.length) section.appendChild(table);
+    return section;
+  }
+
+  // token is the admin bearer token entered at the top of the page
+  var token = element("input");
+  token.type = "password";
+  token.placeholder = "ADMIN_TOKEN";
+
+  // streams reports whether an operation answers with a stream or a protocol
+  // switch, which the form cannot show
+  function streams(operation) {
+    return Object.keys(operation.responses || {}).some(function (code) {
+      var content = (operation.responses[code] || {}).content || {};
+      return code === "101" || "text/event-stream" in content;
+    });
+  }
+
+  // renderTryIt returns a form that sends the operation with the parameters
+  // and body filled in, and prints the status and body of the response
+  function renderTryIt(path, method, operation, parameters, body) {
+    var form = element("form", "try");
+    form.appendChild(element("h4", null, "Try it"));
+    var inputs = {};
+    parameters.forEach(function (p) {
+      var label = element("label", null, p.name + " (" + p.in + ")");
+      var input = element("input");
+      input.name = p.name;
+      input.required = !!p.required;
+      if (p.schema && p.schema.default !== undefined) input.placeholder = String(p.schema.default);
+      inputs[p.name] = input;
+      label.appendChild(input);
+      form.appendChild(label);
+    });
+    var text;
+    if (body) {
+      text = element("textarea");
+      text.rows = 4;
+      text.value = "{}";
+      form.appendChild(text);
+    }
+    var send = element("button", null, "Send");
+    send.type = "submit";
+    form.appendChild(send);
+    var output = element("pre", "response");
+
+    form.addEventListener("submit", function (event) {
+      event.preventDefault();
+      var url = path;
+      var query = new URLSearchParams();
+      parameters.forEach(function (p) {
+        var value = inputs[p.name].value;
+        if (p.in === "path") url = url.replace("{" + p.name + "}", encodeURIComponent(value));
+        else if (p.in === "query" && value !== "") query.append(p.name, value);
+      });
+      if (query.toString()) url += "?" + query;
+      var init = { method: method.toUpperCase(), headers: {} };
+      if (operation.security && token.value) init.headers.Authorization = "Bearer " + token.value;
+      if (text) {
+        init.headers["Content-Type"] = "application/json";
+        init.body = text.value;
+      }
+      output.textContent = init.method + " " + url + "\n...";
+      fetch(url, init)
+        .then(function (response) {
+          return response.text().then(function (data) {
+            try { data = JSON.stringify(JSON.parse(data), null, 2); } catch (e) { /* not JSON */ }
+            output.textContent = init.method + " " + url + "\n" + response.status + " " + response.statusText + "\n\n" + data;
+          });
+        })
+        .catch(function (err) { output.textContent = init.method + " " + url + "\n" + err; });
+    });
+    form.appendChild(output);
+    return form;
+  }
+
+  function renderOperation(spec, path, method, operation) {
+    var section = element("details", "operation " + method);
+    var summary = element("summary");
+    summary.appendChild(element("span", "method", method.toUpperCase()));
+    summary.appendChild(element("span", "path", path));
+    summary.appendChild(element("span", "summary", operation.summary));
+    section.appendChild(summary);
+    if (operation.description) section.appendChild(element("p", null, operation.description));
+
+    var parameters = (operation.parameters || []).map(function (p) { return resolve(spec, p); });
+    if (parameters.length) {
+      section.appendChild(element("h4", null, "Parameters"));
+      var table = element("table");
+      parameters.forEach(function (p) {
+        var row = element("tr");
+        row.appendChild(element("td", "name", p.name + (p.required ? " *" : "")));
+        row.appendChild(element("td", "in", p.in));
+        row.appendChild(element("td", "type", typeName(spec, p.schema)));
+        row.appendChild(element("td", null, p.description));
+        table.appendChild(row);
+      });
+      section.appendChild(table);
+    }
+
+    var body = resolve(spec, operation.requestBody);
+    if (body && body.content) {
+      section.appendChild(element("h4", null, "Request body"));
+      Object.keys(body.content).forEach(function (type) {
+        section.appendChild(element("p", "type", type + ": " + typeName(spec, body.content[type].schema)));
+      });
+    }
+
+    section.appendChild(element("h4", null, "Responses"));
+    var responses = element("table");
+    Object.keys(operation.responses || {}).forEach(function (code) {
+      var response = resolve(spec, operation.responses[code]);
+      var content = response.content || {};
+      var row = element("tr");
+      row.appendChild(element("td", "name", code));
+      row.appendChild(element("td", null, response.description));
+      row.appendChild(element("td", "type", Object.keys(content).map(function (type) {
+        return type + ": " + typeName(spec, content[type].schema);
+      }).join(", ")));
+      responses.appendChild(row);
+    });
+    section.appendChild(responses);
+
+    if (streams(operation)) {
+      section.appendChild(element("p", "summary", "Streaming responses cannot be shown here; connect with a client such as curl or wscat."));
+    } else {
+      section.appendChild(renderTryIt(path, method, operation, parameters, body && body.content));
+    }
+    return section;
+  }
+
+  function render(spec) {
+    root.textContent = "";
+    root.appendChild(element("h1", null, spec.info.title + " " + spec.info.version));
+    if (spec.info.description) root.appendChild(element("p", null, spec.info.description));
+    var auth = element("label", "auth", "Admin token for the admin endpoints ");
+    auth.appendChild(token);
+    root.appendChild(auth);
+
+    // Operations grouped by their first tag, in the order of the document
+    var groups = {};
+    Object.keys(spec.paths).forEach(function (path) {
+      Object.keys(spec.paths[path]).forEach(function (method) {
+        var operation = spec.paths[path][method];
+        var tag = (operation.tags || ["other"])[0];
+        (groups[tag] = groups[tag] || []).push(renderOperation(spec, path, method, operation));
+      });
+    });
+    Object.keys(groups).forEach(function (tag) {
+      root.appendChild(element("h2", null, tag));
+      groups[tag].forEach(function (section) { root.appendChild(section); });
+    });
+
+    var schemas = (spec.components && spec.components.schemas) || {};
+    root.appendChild(element("h2", null, "Schemas"));
+    Object.keys(schemas).forEach(function (name) {
+      root.appendChild(renderSchema(spec, name, schemas[name]));
+    });
+  }
+
+  fetch("/openapi.json")
+    .then(function (response) { return response.json(); })
+    .then(render)
+    .catch(function (err) { root.textContent = "Error loading /openapi.json: " + err; });
+})();
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"awsomeProject/pkg/graphql"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// routePattern matches the patterns of mux path variables such as {id:[0-9]+}.
+var routePattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+
+	router := mux.NewRouter()
+	eventStore := store.NewMemoryStore()
+	SetupRoutes(router, eventStore, setupTestBroadcaster(t, eventStore), nil, "")
+	routed := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		path := routePattern.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			routed[method+" "+path] = true
+			if _, ok := spec.Paths[path][method]; !ok {
+				t.Errorf("%s %s is missing from openapi.json", strings.ToUpper(method), path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The document describes no routes that do not exist
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !routed[method+" "+path] {
+				t.Errorf("openapi.json describes %s %s, which is not routed", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISpecReferences(t *testing.T) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// resolve follows a local reference such as #/components/schemas/StoredEvent
+	resolve := func(ref string) bool {
+		var node interface{} = spec
+		for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			object, ok := node.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			if node, ok = object[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := node["$ref"].(string); ok && !resolve(ref) {
+				t.Errorf("Unresolved reference %s", ref)
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
+
+// readParameters returns the query and path parameters read by the functions
+// of the package, keyed by function name, and the functions each one calls.
+// Parameters are read with Get on r.URL.Query() or a variable named query, and
+// path variables by indexing mux.Vars(r) or a variable named vars.
+func readParameters(t *testing.T) (params map[string]map[string]bool, calls map[string][]string, files []*ast.File) {
+	t.Helper()
+	fset := token.NewFileSet()
+	packages, err := parser.ParseDir(fset, ".", func(info fs.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, calls = make(map[string]map[string]bool), make(map[string][]string)
+	literal := func(expr ast.Expr) (string, bool) {
+		lit, ok := expr.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return "", false
+		}
+		value, err := strconv.Unquote(lit.Value)
+		return value, err == nil
+	}
+	isCall := func(expr ast.Expr, name string) bool {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		return ok && selector.Sel.Name == name
+	}
+	isIdent := func(expr ast.Expr, name string) bool {
+		ident, ok := expr.(*ast.Ident)
+		return ok && ident.Name == name
+	}
+	for _, file := range packages["api"].Files {
+		files = append(files, file)
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			read := make(map[string]bool)
+			ast.Inspect(fn, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.CallExpr:
+					if selector, ok := node.Fun.(*ast.SelectorExpr); ok && selector.Sel.Name == "Get" && len(node.Args) == 1 &&
+						(isIdent(selector.X, "query") || isCall(selector.X, "Query")) {
+						if name, ok := literal(node.Args[0]); ok {
+							read["query "+name] = true
+						}
+					}
+					if ident, ok := node.Fun.(*ast.Ident); ok {
+						calls[fn.Name.Name] = append(calls[fn.Name.Name], ident.Name)
+					}
+				case *ast.IndexExpr:
+					if isIdent(node.X, "vars") || isCall(node.X, "Vars") {
+						if name, ok := literal(node.Index); ok {
+							read["path "+name] = true
+						}
+					}
+				}
+				return true
+			})
+			params[fn.Name.Name] = read
+		}
+	}
+	return params, calls, files
+}
+
+func TestOpenAPISpecParameters(t *testing.T) {
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Parameters map[string]openAPIParameter `json:"parameters"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Follow the functions called by the handler of each route in routes.go
+	params, calls, files := readParameters(t)
+	var collect func(name string, read map[string]bool, seen map[string]bool)
+	collect = func(name string, read map[string]bool, seen map[string]bool) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		for param := range params[name] {
+			read[param] = true
+		}
+		for _, callee := range calls[name] {
+			collect(callee, read, seen)
+		}
+	}
+	routes := make(map[string]map[string]bool)
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			if selector, ok := call.Fun.(*ast.SelectorExpr); !ok || selector.Sel.Name != "HandleFunc" {
+				return true
+			}
+			path, _ := strconv.Unquote(call.Args[0].(*ast.BasicLit).Value)
+			read, seen := make(map[string]bool), make(map[string]bool)
+			ast.Inspect(call.Args[1], func(node ast.Node) bool {
+				if handler, ok := node.(*ast.CallExpr); ok {
+					if ident, ok := handler.Fun.(*ast.Ident); ok {
+						collect(ident.Name, read, seen)
+					}
+				}
+				return true
+			})
+			routes[routePattern.ReplaceAllString(path, "{$1}")] = read
+			return true
+		})
+	}
+	if len(routes) == 0 {
+		t.Fatal("Expected routes in routes.go")
+	}
+
+	for path, read := range routes {
+		// Parameters of any operation of the path, which may differ by method
+		declared := make(map[string]bool)
+		for method, raw := range spec.Paths[path] {
+			var operation struct {
+				Parameters []openAPIParameter `json:"parameters"`
+			}
+			if err := json.Unmarshal(raw, &operation); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			for _, param := range operation.Parameters {
+				if param.Ref != "" {
+					param = spec.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+				}
+				if param.In == "query" || param.In == "path" {
+					declared[param.In+" "+param.Name] = true
+				}
+			}
+		}
+		for param := range read {
+			if !declared[param] {
+				t.Errorf("%s reads the %s parameter, which openapi.json does not declare", path, param)
+			}
+		}
+		for param := range declared {
+			if !read[param] {
+				t.Errorf("openapi.json declares the %s parameter of %s, which is not read", param, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISpecSchemas(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Schemas of the JSON objects the handlers read and write
+	for name, value := range map[string]interface{}{
+		"StoredEvent":       models.StoredEvent{},
+		"EventPage":         pageResponse{},
+		"TimeseriesPoint":   timeseriesPoint{},
+		"Timeseries":        timeseriesResponse{},
+		"ActorActivity":     models.ActorActivity{},
+		"ActorActivityPage": pageResponse{},
+		"RepoActivity":      models.RepoActivity{},
+		"RepoActivityPage":  pageResponse{},
+		"DomainGroup":       domainGroup{},
+		"ActorRank":         models.ActorRank{},
+		"RepoRank":          models.RepoRank{},
+		"HourCount":         models.HourCount{},
+		"RepoStats":         models.RepoStats{},
+		"ActorResponse":     actorResponse{},
+		"IssueMetrics":      models.IssueMetrics{},
+		"BacklogPoint":      models.BacklogPoint{},
+		"ErasureRequest":    models.ErasureRequest{},
+		"ErasureReport":     models.ErasureReport{},
+		"GraphQLRequest":    graphql.Request{},
+		"GraphQLResponse":   graphql.Response{},
+	} {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("openapi.json has no %s schema", name)
+			continue
+		}
+		fields := make(map[string]bool)
+		for _, field := range jsonFields(reflect.TypeOf(value)) {
+			fields[field] = true
+		}
+		for field := range fields {
+			if _, ok := schema.Properties[field]; !ok {
+				t.Errorf("The %s schema is missing the %q property", name, field)
+			}
+		}
+		for property := range schema.Properties {
+			if !fields[property] {
+				t.Errorf("The %s schema has a %q property, which is not encoded", name, property)
+			}
+		}
+	}
+}
+
+// openAPIParameter is a parameter of an operation, or a reference to one.
+type openAPIParameter struct {
+	Ref  string `json:"$ref"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+func TestGetDocs(t *testing.T) {
+	for _, tc := range []struct {
+		handler     http.HandlerFunc
+		contentType string
+		body        string
+	}{
+		{GetOpenAPISpec(), "application/json", `"openapi": "3.0.3"`},
+		{GetDocs(), "text/html; charset=utf-8", `fetch("/openapi.json")`},
+		{GetDocs(), "text/html; charset=utf-8", `fetch(url, init)`},
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		rr := httptest.NewRecorder()
+		tc.handler(rr, req)
+		if rr.Header().Get("Content-Type") != tc.contentType || !strings.Contains(rr.Body.String(), tc.body) {
+			t.Errorf("Expected %s with %q, got %s %s", tc.contentType, tc.body, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+	}
+}
+
+func TestGetDocsLoadsNothingExternal(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/docs", nil)
+	rr := httptest.NewRecorder()
+	GetDocs()(rr, req)
+	page := rr.Body.String()
+
+	if strings.Contains(page, " src=") || strings.Contains(page, " href=") {
+		t.Errorf("Expected the docs page to inline its assets, got %s", page)
+	}
+
+	// The policy allows exactly the inline script and style of the page
+	policy := rr.Header().Get("Content-Security-Policy")
+	for _, tag := range []string{"script", "style"} {
+		start := strings.Index(page, "<"+tag+">") + len(tag) + 2
+		end := strings.Index(page, "</"+tag+">")
+		sum := sha256.Sum256([]byte(page[start:end]))
+		source := fmt.Sprintf("%s-src 'sha256-%s'", tag, base64.StdEncoding.EncodeToString(sum[:]))
+		if !strings.Contains(policy, source) {
+			t.Errorf("Expected the policy to contain %q, got %q", source, policy)
+		}
+	}
+	if !strings.Contains(policy, "default-src 'none'") {
+		t.Errorf("Expected the policy to deny other sources, got %q", policy)
+	}
+}
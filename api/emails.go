@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// noreplyDomain is the domain of the private commit emails GitHub substitutes
+// for users who hide their address.
+const noreplyDomain = "users.noreply.github.com"
+
+// emailOptions are the query parameters of /unique-emails.
+type emailOptions struct {
+	excludeNoreply bool
+	groupByDomain  bool
+}
+
+// parseEmailOptions reads the "exclude_noreply" (a boolean) and "group_by"
+// (only "domain") query parameters.
+func parseEmailOptions(r *http.Request) (emailOptions, error) {
+	var options emailOptions
+	query := r.URL.Query()
+
+	if v := query.Get("exclude_noreply"); v != "" {
+		exclude, err := strconv.ParseBool(v)
+		if err != nil {
+			return options, fmt.Errorf("exclude_noreply must be true or false, got %q", v)
+		}
+		options.excludeNoreply = exclude
+	}
+
+	switch v := query.Get("group_by"); v {
+	case "":
+	case "domain":
+		options.groupByDomain = true
+	default:
+		return options, fmt.Errorf("group_by must be domain, got %q", v)
+	}
+	return options, nil
+}
+
+// emailDomain returns the lower-cased domain of an email address.
+func emailDomain(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+// excludeNoreply drops GitHub noreply addresses.
+func excludeNoreply(emails []string) []string {
+	kept := []string{}
+	for _, email := range emails {
+		if emailDomain(email) != noreplyDomain {
+			kept = append(kept, email)
+		}
+	}
+	return kept
+}
+
+// domainGroup is the set of emails of a single domain.
+type domainGroup struct {
+	Domain string   `json:"domain"`
+	Count  int      `json:"count"`
+	Emails []string `json:"emails"`
+}
+
+// groupByDomain groups emails by domain, largest group first and ties by domain.
+func groupByDomain(emails []string) []domainGroup {
+	byDomain := make(map[string]*domainGroup)
+	groups := []domainGroup{}
+	for _, email := range emails {
+		domain := emailDomain(email)
+		if byDomain[domain] == nil {
+			byDomain[domain] = &domainGroup{Domain: domain}
+		}
+		byDomain[domain].Emails = append(byDomain[domain].Emails, email)
+		byDomain[domain].Count++
+	}
+	for _, group := range byDomain {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Domain < groups[j].Domain
+	})
+	return groups
+}
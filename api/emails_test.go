@@ -0,0 +1,53 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUniqueEmailsOptions(t *testing.T) {
+	eventStore := setupTestStore(t)
+	var commits []models.Commit
+	for _, email := range []string{"a@example.com", "b@Example.com", "123+c@users.noreply.github.com", "d@other.org"} {
+		commits = append(commits, models.Commit{Author: models.Author{Email: email}})
+	}
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Payload: models.Payload{Commits: commits}, CreatedAt: time.Now()})
+
+	get := func(query string, v interface{}) int {
+		req, _ := http.NewRequest("GET", "/unique-emails?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetUniqueEmails(eventStore)(rr, req)
+		if rr.Code == http.StatusOK {
+			json.Unmarshal(rr.Body.Bytes(), v)
+		}
+		return rr.Code
+	}
+
+	var emails []string
+	get("exclude_noreply=true", &emails)
+	if !reflect.DeepEqual(emails, []string{"a@example.com", "b@Example.com", "d@other.org"}) {
+		t.Errorf("Expected noreply addresses to be excluded, got %v", emails)
+	}
+
+	var groups []domainGroup
+	get("group_by=domain", &groups)
+	expected := []domainGroup{
+		{Domain: "example.com", Count: 2, Emails: []string{"a@example.com", "b@Example.com"}},
+		{Domain: "other.org", Count: 1, Emails: []string{"d@other.org"}},
+		{Domain: "users.noreply.github.com", Count: 1, Emails: []string{"123+c@users.noreply.github.com"}},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Unexpected domain groups: %+v", groups)
+	}
+
+	for _, query := range []string{"exclude_noreply=maybe", "group_by=user"} {
+		if code := get(query, nil); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, code)
+		}
+	}
+}
@@ -1,172 +1,167 @@
 package api
 
 import (
-	"database/sql"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
 	"encoding/json"
 	"net/http"
-	"sync"
+	"time"
 )
 
-// Define data structures to store the collected data
-var eventTypeCount = make(map[string]int)
-var uniqueActors []string
-var uniqueRepoURLs []string
-var uniqueEmails []string
-
-var (
-	eventTypeCountMutex sync.Mutex
-	uniqueActorsMutex   sync.Mutex
-	uniqueRepoURLsMutex sync.Mutex
-	uniqueEmailsMutex   sync.Mutex
-)
+// API endpoints for retrieving data. Each accepts the "since", "until" and
+// "tz" query parameters described at parseTimeRange. The unique actor and
+// repository URL lists are ordered by latest activity and paginated with
+// "limit" and "cursor" (see parsePage).
 
-// API endpoints for retrieving data
+// Page sizes of the unique actor and repository URL lists without a "limit"
+const (
+	defaultActorsLimit   = 50
+	defaultRepoURLsLimit = 20
+)
 
-func GetEventCounts(db *sql.DB) http.HandlerFunc {
+func GetEventCounts(eventStore store.EventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Query the database to get event counts
-		rows, err := db.Query("SELECT event_type, COUNT(*) FROM github GROUP BY event_type")
+		window, err := parseTimeRange(r, time.Now())
 		if err != nil {
-			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
-
-		// Create a map to store the event counts
-		eventTypeCount := make(map[string]int)
-
-		// Iterate through the query results and populate the event counts map
-		for rows.Next() {
-			var eventType string
-			var count int
-			err := rows.Scan(&eventType, &count)
-			if err != nil {
-				http.Error(w, "Error scanning database rows", http.StatusInternalServerError)
-				return
-			}
-			eventTypeCount[eventType] = count
-		}
 
-		// Convert eventTypeCount to JSON and write it to the response
-		data, err := json.Marshal(eventTypeCount)
+		// Query the store to get event counts
+		eventTypeCount, err := eventStore.EventCounts(window)
 		if err != nil {
-			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+		if err := setCountedWindow(w, eventStore, window); err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+		// Convert eventTypeCount to JSON and write it to the response
+		writeJSON(w, eventTypeCount)
 	}
 }
 
-func GetUniqueActors(db *sql.DB) http.HandlerFunc {
+func GetUniqueActors(eventStore store.EventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Query the database to get unique actors
-		rows, err := db.Query("SELECT DISTINCT actor FROM github")
+		window, err := parseTimeRange(r, time.Now())
 		if err != nil {
-			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
-
-		// Create a slice to store the unique actors
-		var uniqueActors []string
 
-		// Iterate through the query results and add actors to the slice
-		for rows.Next() {
-			var actor string
-			err := rows.Scan(&actor)
-			if err != nil {
-				http.Error(w, "Error scanning database rows", http.StatusInternalServerError)
-				return
-			}
-			uniqueActors = append(uniqueActors, actor)
+		page, err := parsePage(r, defaultActorsLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		// Convert uniqueActors to JSON and write it to the response
-		data, err := json.Marshal(uniqueActors)
+		// Query the store to get a page of the most recently active actors
+		uniqueActors, next, err := eventStore.UniqueActors(window, page)
+		if err == store.ErrInvalidCursor {
+			http.Error(w, "cursor is invalid", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
-			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+		// Convert the page to JSON and write it to the response
+		writeJSON(w, pageResponse{Items: uniqueActors, NextCursor: encodeCursor(next)})
 	}
 }
 
-func GetUniqueRepoURLs(db *sql.DB) http.HandlerFunc {
+func GetUniqueRepoURLs(eventStore store.EventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Query the database to get unique repo URLs
-		rows, err := db.Query("SELECT DISTINCT repo_url FROM github")
+		window, err := parseTimeRange(r, time.Now())
 		if err != nil {
-			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
 
-		// Create a slice to store the unique repo URLs
-		var uniqueRepoURLs []string
-
-		// Iterate through the query results and populate the unique repo URLs slice
-		for rows.Next() {
-			var repoURL string
-			err := rows.Scan(&repoURL)
-			if err != nil {
-				http.Error(w, "Error scanning database rows", http.StatusInternalServerError)
-				return
-			}
-			uniqueRepoURLs = append(uniqueRepoURLs, repoURL)
+		page, err := parsePage(r, defaultRepoURLsLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		// Convert uniqueRepoURLs to JSON and write it to the response
-		data, err := json.Marshal(uniqueRepoURLs)
+		// Query the store to get a page of the most recently active repositories
+		uniqueRepoURLs, next, err := eventStore.UniqueRepoURLs(window, page)
+		if err == store.ErrInvalidCursor {
+			http.Error(w, "cursor is invalid", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
-			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+		// Convert the page to JSON and write it to the response
+		writeJSON(w, pageResponse{Items: uniqueRepoURLs, NextCursor: encodeCursor(next)})
 	}
 }
 
-func GetUniqueEmails(db *sql.DB) http.HandlerFunc {
+func GetUniqueEmails(eventStore store.EventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Query the database to get unique emails
-		rows, err := db.Query("SELECT DISTINCT actor FROM github")
+		window, err := parseTimeRange(r, time.Now())
 		if err != nil {
-			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer rows.Close()
 
-		// Create a slice to store the unique emails
-		var uniqueEmails []string
+		options, err := parseEmailOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		// Iterate through the query results and populate the unique emails slice
-		for rows.Next() {
-			var actor string
-			err := rows.Scan(&actor)
-			if err != nil {
-				http.Error(w, "Error scanning database rows", http.StatusInternalServerError)
-				return
-			}
-			uniqueEmails = append(uniqueEmails, actor)
+		// Query the store to get unique emails
+		uniqueEmails, err := eventStore.UniqueEmails(window)
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+		if options.excludeNoreply {
+			uniqueEmails = excludeNoreply(uniqueEmails)
 		}
 
 		// Convert uniqueEmails to JSON and write it to the response
-		data, err := json.Marshal(uniqueEmails)
-		if err != nil {
-			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		if options.groupByDomain {
+			writeJSON(w, groupByDomain(uniqueEmails))
 			return
 		}
+		writeJSON(w, uniqueEmails)
+	}
+}
+
+// setCountedWindow reports the time span that rollup counts over window
+// actually cover in the X-Counted-Since and X-Counted-Until headers, as hour
+// and day rollups are counted in full when their bucket starts in the window.
+func setCountedWindow(w http.ResponseWriter, eventStore store.EventStore, window models.TimeRange) error {
+	counted, err := eventStore.CountedWindow(window)
+	if err != nil {
+		return err
+	}
+	if !counted.Since.IsZero() {
+		w.Header().Set("X-Counted-Since", counted.Since.UTC().Format(time.RFC3339))
+	}
+	if !counted.Until.IsZero() {
+		w.Header().Set("X-Counted-Until", counted.Until.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+// writeJSON encodes v as the JSON body of a 200 response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
 }
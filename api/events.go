@@ -0,0 +1,233 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultEventsLimit is the page size of /events without a "limit".
+const defaultEventsLimit = 100
+
+// eventFields are the JSON field names of a stored event, which "fields" may select.
+var eventFields = jsonFields(reflect.TypeOf(models.StoredEvent{}))
+
+// eventQuery holds the query parameters of /events.
+type eventQuery struct {
+	filter        models.EventFilter
+	descending    bool
+	page          models.Page
+	fields        []string
+	includeEmails bool
+}
+
+// parseEventQuery reads the query parameters of /events: the time window (see
+// parseTimeRange), "type", "actor", "repo" and "source" as comma-separated
+// lists, "sort" as created_at or -created_at (the default), "limit" and
+// "cursor" (see parsePage), "fields" as a comma-separated list of event
+// fields to return and "include_emails" (see parseIncludeEmails).
+func parseEventQuery(r *http.Request, now time.Time) (eventQuery, error) {
+	var q eventQuery
+	window, err := parseTimeRange(r, now)
+	if err != nil {
+		return q, err
+	}
+	q.filter = parseEventFilter(r)
+	q.filter.TimeRange = window
+
+	if q.descending, err = parseSort(r); err != nil {
+		return q, err
+	}
+	if q.page, err = parsePage(r, defaultEventsLimit); err != nil {
+		return q, err
+	}
+	if q.includeEmails, err = parseIncludeEmails(r); err != nil {
+		return q, err
+	}
+
+	q.fields = splitList(r.URL.Query().Get("fields"))
+	for _, field := range q.fields {
+		if !contains(eventFields, field) {
+			return q, fmt.Errorf("unknown field %q, fields are %s", field, strings.Join(eventFields, ", "))
+		}
+	}
+	return q, nil
+}
+
+// parseEventFilter reads the "type", "actor", "repo" and "source" query
+// parameters of event lists as comma-separated lists.
+func parseEventFilter(r *http.Request) models.EventFilter {
+	query := r.URL.Query()
+	return models.EventFilter{
+		Types:   splitList(query.Get("type")),
+		Actors:  splitList(query.Get("actor")),
+		Repos:   splitList(query.Get("repo")),
+		Sources: splitList(query.Get("source")),
+	}
+}
+
+// parseIncludeEmails reads the "include_emails" query parameter of event
+// responses, a boolean. Commit author emails are personal data, so events
+// only list them if it is true.
+func parseIncludeEmails(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("include_emails")
+	if v == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("include_emails must be true or false, got %q", v)
+	}
+	return include, nil
+}
+
+// parseSort reads the "sort" query parameter of event lists, created_at for
+// oldest first or -created_at (the default) for newest first.
+func parseSort(r *http.Request) (descending bool, err error) {
+	switch v := r.URL.Query().Get("sort"); v {
+	case "", "-created_at":
+		return true, nil
+	case "created_at":
+		return false, nil
+	default:
+		return false, fmt.Errorf("sort must be created_at or -created_at, got %q", v)
+	}
+}
+
+// GetEvents lists the stored events matching the query parameters described at parseEventQuery.
+func GetEvents(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseEventQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Query the store to get a page of matching events
+		events, next, err := eventStore.ListEvents(q.filter, q.descending, q.page)
+		if err == store.ErrInvalidCursor {
+			http.Error(w, "cursor is invalid", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		if !q.includeEmails {
+			for i := range events {
+				events[i].Emails = nil
+			}
+		}
+
+		var items interface{} = events
+		if len(q.fields) > 0 {
+			if items, err = selectFields(events, q.fields); err != nil {
+				http.Error(w, "Error encoding the events", http.StatusInternalServerError)
+				return
+			}
+		}
+		writeJSON(w, pageResponse{Items: items, NextCursor: encodeCursor(next)})
+	}
+}
+
+// GetEvent returns the stored event with the store ID in the path, with its
+// commit author emails if "include_emails" is true.
+func GetEvent(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		includeEmails, err := parseIncludeEmails(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Query the store to get the event
+		event, err := eventStore.GetEvent(id)
+		if err == store.ErrNotFound {
+			http.Error(w, "event not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		if !includeEmails {
+			event.Emails = nil
+		}
+		writeJSON(w, event)
+	}
+}
+
+// selectFields reduces each event to the given JSON fields.
+func selectFields(events []models.StoredEvent, fields []string) ([]map[string]json.RawMessage, error) {
+	selected := make([]map[string]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		item := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if v, ok := all[field]; ok {
+				item[field] = v
+			}
+		}
+		selected = append(selected, item)
+	}
+	return selected, nil
+}
+
+// jsonFields returns the JSON names of the fields of a struct type,
+// including the fields of embedded structs without a name.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+		} else if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// splitList splits a comma-separated query parameter, dropping empty entries.
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// contains reports whether value is in values.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
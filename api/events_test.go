@@ -0,0 +1,148 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetEvents(t *testing.T) {
+	eventStore := setupTestStore(t)
+	now := time.Now()
+	for i, event := range []models.GitHubEvent{
+		{ID: "1", Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"},
+			Payload: models.Payload{Commits: []models.Commit{{Author: models.Author{Email: "alice@example.com"}}}}},
+		{ID: "2", Type: "IssuesEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo1"}},
+		{ID: "3", Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo2"}},
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo2"}, Source: models.SourceImport},
+	} {
+		event.CreatedAt = now.Add(-time.Duration(4-i) * time.Minute)
+		if _, err := eventStore.StoreEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(query string) ([]models.StoredEvent, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/events?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetEvents(eventStore)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Unexpected status %d for %s: %s", rr.Code, query, rr.Body.String())
+		}
+		var page struct {
+			Items      []models.StoredEvent `json:"items"`
+			NextCursor string               `json:"next_cursor"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &page)
+		return page.Items, page.NextCursor
+	}
+	ids := func(events []models.StoredEvent) []int64 {
+		var ids []int64
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return ids
+	}
+
+	for query, expected := range map[string][]int64{
+		"":                             {4, 3, 2, 1},
+		"sort=created_at":              {1, 2, 3, 4},
+		"type=PushEvent":               {4, 3, 1},
+		"actor=bob&repo=repo1":         {2},
+		"repo=repo1,repo2&actor=bob":   {3, 2},
+		"source=import":                {4},
+		"source=github&type=PushEvent": {3, 1},
+		"since=150s":                   {4, 3},
+	} {
+		if events, _ := list(query); !reflect.DeepEqual(ids(events), expected) {
+			t.Errorf("Expected events %v for %q, got %v", expected, query, ids(events))
+		}
+	}
+
+	// Commit author emails are only listed on request
+	if events, _ := list("actor=alice&source=github"); len(events) != 1 || events[0].Emails != nil {
+		t.Errorf("Expected alice's event without emails, got %+v", events)
+	}
+	if events, _ := list("actor=alice&source=github&include_emails=true"); len(events) != 1 || !reflect.DeepEqual(events[0].Emails, []string{"alice@example.com"}) {
+		t.Errorf("Expected alice's event with her email, got %+v", events)
+	}
+
+	var pages [][]int64
+	events, cursor := list("limit=3&sort=created_at")
+	pages = append(pages, ids(events))
+	events, cursor = list("limit=3&sort=created_at&cursor=" + cursor)
+	pages = append(pages, ids(events))
+	if expected := [][]int64{{1, 2, 3}, {4}}; !reflect.DeepEqual(pages, expected) || cursor != "" {
+		t.Errorf("Expected pages %v, got %v (next %q)", expected, pages, cursor)
+	}
+
+	req, _ := http.NewRequest("GET", "/events?fields=id,actor&limit=1", nil)
+	rr := httptest.NewRecorder()
+	GetEvents(eventStore)(rr, req)
+	if expected := `{"items":[{"actor":"alice","id":4}],"next_cursor":"`; rr.Body.String()[:len(expected)] != expected {
+		t.Errorf("Expected selected fields, got %s", rr.Body.String())
+	}
+
+	for _, query := range []string{"sort=actor", "fields=payload", "limit=0", "cursor=" + encodeCursor("1")} {
+		req, _ := http.NewRequest("GET", "/events?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetEvents(eventStore)(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestGetEvent(t *testing.T) {
+	eventStore := setupTestStore(t)
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	eventStore.StoreEvent(models.GitHubEvent{
+		ID: "42", Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: createdAt,
+		Payload: models.Payload{Commits: []models.Commit{
+			{Author: models.Author{Email: "alice@example.com"}},
+			{Author: models.Author{Email: "alice@example.com"}},
+		}},
+	})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/events/{id:[0-9]+}", GetEvent(eventStore))
+	req, _ := http.NewRequest("GET", "/events/1?include_emails=true", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var event models.StoredEvent
+	json.Unmarshal(rr.Body.Bytes(), &event)
+	expected := models.StoredEvent{
+		ID: 1, GitHubID: "42", Type: "PushEvent", Actor: "alice", Repo: "repo1", CreatedAt: createdAt,
+		Source: models.SourceGitHub, Emails: []string{"alice@example.com"},
+	}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, event)
+	}
+
+	// Emails are only listed on request
+	req, _ = http.NewRequest("GET", "/events/1", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "emails") {
+		t.Errorf("Expected the event without emails, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/events/2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing event, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,753 @@
+package api
+
+import (
+	"awsomeProject/pkg/graphql"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of /graphql
+const (
+	graphqlMaxDepth      = 10
+	graphqlMaxComplexity = 5000
+	graphqlMaxBody       = 64 << 10
+	// graphqlMaxFirst caps the "first" argument of lists.
+	graphqlMaxFirst = 100
+)
+
+// errDatabase is the error of a field whose store query failed. The cause is logged.
+var errDatabase = errors.New("Error querying the database")
+
+// actorRef is an actor with the window its summary covers.
+type actorRef struct {
+	login  string
+	window models.TimeRange
+}
+
+// repoRef is a repository with the window its summary covers.
+type repoRef struct {
+	url    string
+	window models.TimeRange
+}
+
+// eventConnection is a page of events.
+type eventConnection struct {
+	events []models.StoredEvent
+	next   string
+}
+
+// eventCount is the number of events of a type.
+type eventCount struct {
+	eventType string
+	count     int64
+}
+
+// rankedActor and rankedRepo are leaderboard entries with their window.
+type (
+	rankedActor struct {
+		models.ActorRank
+		window models.TimeRange
+	}
+	rankedRepo struct {
+		models.RepoRank
+		window models.TimeRange
+	}
+)
+
+// loadKey identifies a summary of an actor or repository.
+type loadKey struct {
+	value  string
+	window models.TimeRange
+}
+
+// latestKey identifies the latest events of an actor or repository.
+type latestKey struct {
+	value string
+	limit int
+}
+
+// graphqlLoader caches the store lookups of one GraphQL request, so every
+// field of a summary shares one batched lookup and each key is loaded once.
+type graphqlLoader struct {
+	eventStore  store.EventStore
+	profiles    map[loadKey]*models.ActorProfile
+	stats       map[loadKey]*models.RepoStats
+	actorEvents map[latestKey][]models.StoredEvent
+	repoEvents  map[latestKey][]models.StoredEvent
+}
+
+type loaderContextKey struct{}
+
+// loaderFrom returns the loader of the request a resolver runs for.
+func loaderFrom(ctx context.Context) *graphqlLoader {
+	return ctx.Value(loaderContextKey{}).(*graphqlLoader)
+}
+
+// actorProfiles returns the profile of each actor, nil for actors that are not stored.
+func (l *graphqlLoader) actorProfiles(refs []actorRef) ([]*models.ActorProfile, error) {
+	missing := make(map[models.TimeRange][]string)
+	for _, ref := range refs {
+		key := loadKey{ref.login, ref.window}
+		if _, ok := l.profiles[key]; !ok {
+			l.profiles[key] = nil
+			missing[ref.window] = append(missing[ref.window], ref.login)
+		}
+	}
+	for window, logins := range missing {
+		found, err := l.eventStore.ActorProfilesByLogin(logins, window)
+		if err != nil {
+			return nil, databaseError(err)
+		}
+		for login, profile := range found {
+			profile := profile
+			l.profiles[loadKey{login, window}] = &profile
+		}
+	}
+
+	profiles := make([]*models.ActorProfile, len(refs))
+	for i, ref := range refs {
+		profiles[i] = l.profiles[loadKey{ref.login, ref.window}]
+	}
+	return profiles, nil
+}
+
+// repoStats returns the statistics of each repository, nil for repositories that are not stored.
+func (l *graphqlLoader) repoStats(refs []repoRef) ([]*models.RepoStats, error) {
+	missing := make(map[models.TimeRange][]string)
+	for _, ref := range refs {
+		key := loadKey{ref.url, ref.window}
+		if _, ok := l.stats[key]; !ok {
+			l.stats[key] = nil
+			missing[ref.window] = append(missing[ref.window], ref.url)
+		}
+	}
+	for window, urls := range missing {
+		found, err := l.eventStore.RepoStatsByURL(urls, window)
+		if err != nil {
+			return nil, databaseError(err)
+		}
+		for url, stats := range found {
+			stats := stats
+			l.stats[loadKey{url, window}] = &stats
+		}
+	}
+
+	stats := make([]*models.RepoStats, len(refs))
+	for i, ref := range refs {
+		stats[i] = l.stats[loadKey{ref.url, ref.window}]
+	}
+	return stats, nil
+}
+
+// latestEvents returns the latest limit events of each value, loading the
+// missing ones with load.
+func latestEvents(cache map[latestKey][]models.StoredEvent, values []string, limit int,
+	load func(values []string, limit int) (map[string][]models.StoredEvent, error)) ([][]models.StoredEvent, error) {
+	var missing []string
+	for _, value := range values {
+		key := latestKey{value, limit}
+		if _, ok := cache[key]; !ok {
+			cache[key] = []models.StoredEvent{}
+			missing = append(missing, value)
+		}
+	}
+	if len(missing) > 0 {
+		found, err := load(missing, limit)
+		if err != nil {
+			return nil, databaseError(err)
+		}
+		for value, events := range found {
+			cache[latestKey{value, limit}] = events
+		}
+	}
+
+	events := make([][]models.StoredEvent, len(values))
+	for i, value := range values {
+		events[i] = cache[latestKey{value, limit}]
+	}
+	return events, nil
+}
+
+// databaseError logs a store error and hides it from clients.
+func databaseError(err error) error {
+	log.Printf("Error resolving GraphQL query: %v", err)
+	return errDatabase
+}
+
+// graphqlTime is the scalar of timestamps.
+var graphqlTime = &graphql.Scalar{
+	Name:        "Time",
+	Description: "An RFC 3339 timestamp in UTC.",
+	Serialize: func(v interface{}) (interface{}, error) {
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC().Format(time.RFC3339Nano), nil
+		case *time.Time:
+			return t.UTC().Format(time.RFC3339Nano), nil
+		}
+		return nil, fmt.Errorf("Time cannot represent %T", v)
+	},
+	Parse: func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("Time must be an RFC 3339 timestamp")
+	},
+}
+
+// field returns a resolver of a value computed from each source.
+func field(get func(source interface{}) interface{}) graphql.Resolver {
+	return graphql.Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+		return get(source), nil
+	})
+}
+
+// actorField returns a resolver of a value computed from each actor's
+// profile, which is empty for actors that are not stored.
+func actorField(get func(profile models.ActorProfile) interface{}) graphql.Resolver {
+	return func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		refs := make([]actorRef, len(sources))
+		for i, source := range sources {
+			refs[i] = source.(actorRef)
+		}
+		profiles, err := loaderFrom(ctx).actorProfiles(refs)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(sources))
+		for i, profile := range profiles {
+			if profile == nil {
+				profile = &models.ActorProfile{Login: refs[i].login}
+			}
+			values[i] = get(*profile)
+		}
+		return values, nil
+	}
+}
+
+// repoField returns a resolver of a value computed from each repository's
+// statistics, which are empty for repositories that are not stored.
+func repoField(get func(stats models.RepoStats, window models.TimeRange, args map[string]interface{}) interface{}) graphql.Resolver {
+	return func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		refs := make([]repoRef, len(sources))
+		for i, source := range sources {
+			refs[i] = source.(repoRef)
+		}
+		all, err := loaderFrom(ctx).repoStats(refs)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(sources))
+		for i, stats := range all {
+			if stats == nil {
+				stats = &models.RepoStats{URL: refs[i].url}
+			}
+			values[i] = get(*stats, refs[i].window, args)
+		}
+		return values, nil
+	}
+}
+
+// eventCounts sorts event counts by type.
+func eventCounts(counts map[string]int64) []eventCount {
+	sorted := make([]eventCount, 0, len(counts))
+	for eventType, count := range counts {
+		sorted = append(sorted, eventCount{eventType, count})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].eventType < sorted[j].eventType })
+	return sorted
+}
+
+// firstArg reads the "first" argument of a list.
+func firstArg(args map[string]interface{}) (int, error) {
+	first := args["first"].(int)
+	if first < 1 || first > graphqlMaxFirst {
+		return 0, fmt.Errorf("first must be between 1 and %d", graphqlMaxFirst)
+	}
+	return first, nil
+}
+
+// windowArgs reads the "since" and "until" arguments of a field (see parseTimeRange).
+func windowArgs(args map[string]interface{}) (models.TimeRange, error) {
+	since, _ := args["since"].(string)
+	until, _ := args["until"].(string)
+	return parseWindow(since, until, time.Now(), time.UTC)
+}
+
+// stringsArg reads a list of strings argument.
+func stringsArg(v interface{}) []string {
+	list, _ := v.([]interface{})
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// windowArguments are the arguments of fields summarizing a time window.
+var windowArguments = []*graphql.Argument{
+	{Name: "since", Type: graphql.String, Description: "Start of the window: an RFC 3339 time or a duration before now such as 1h or 7d."},
+	{Name: "until", Type: graphql.String, Description: "End of the window, like since."},
+}
+
+// newGraphQLSchema returns the schema of /graphql.
+func newGraphQLSchema() *graphql.Schema {
+	nonNull, list := graphql.NonNullOf, graphql.ListOf
+	str, integer, boolean := graphql.String, graphql.Int, graphql.Boolean
+
+	eventCountType := &graphql.Object{Name: "EventCount", Description: "The number of events of a type.", Fields: []*graphql.Field{
+		{Name: "type", Type: nonNull(str), Resolve: field(func(s interface{}) interface{} { return s.(eventCount).eventType })},
+		{Name: "count", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(eventCount).count })},
+	}}
+	hourCountType := &graphql.Object{Name: "HourCount", Description: "The number of events in a UTC hour of the day.", Fields: []*graphql.Field{
+		{Name: "hour", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(models.HourCount).Hour })},
+		{Name: "eventCount", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(models.HourCount).Events })},
+	}}
+	commitType := &graphql.Object{Name: "Commit", Description: "A commit of a push, one per distinct author email.", Fields: []*graphql.Field{
+		{Name: "authorEmail", Type: nonNull(str), Resolve: field(func(s interface{}) interface{} { return s })},
+	}}
+	pageInfoType := &graphql.Object{Name: "PageInfo", Fields: []*graphql.Field{
+		{Name: "endCursor", Type: str, Description: "The cursor to pass as after for the next page.", Resolve: field(func(s interface{}) interface{} {
+			if next := encodeCursor(s.(eventConnection).next); next != "" {
+				return next
+			}
+			return nil
+		})},
+		{Name: "hasNextPage", Type: nonNull(boolean), Resolve: field(func(s interface{}) interface{} { return s.(eventConnection).next != "" })},
+	}}
+
+	eventType := &graphql.Object{Name: "Event", Description: "A stored event."}
+	actorType := &graphql.Object{Name: "Actor", Description: "A GitHub user or bot, summarized over a time window."}
+	repoType := &graphql.Object{Name: "Repository", Description: "A GitHub repository, summarized over a time window."}
+	connectionType := &graphql.Object{Name: "EventConnection", Description: "A page of events.", Fields: []*graphql.Field{
+		{Name: "nodes", Type: nonNull(list(nonNull(eventType))), Resolve: field(func(s interface{}) interface{} { return s.(eventConnection).events })},
+		{Name: "pageInfo", Type: nonNull(pageInfoType), Resolve: field(func(s interface{}) interface{} { return s })},
+	}}
+	actorRankType := &graphql.Object{Name: "ActorRank", Description: "An actor's place on a leaderboard.", Fields: []*graphql.Field{
+		{Name: "rank", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(rankedActor).Rank })},
+		{Name: "eventCount", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(rankedActor).Events })},
+		{Name: "actor", Type: nonNull(actorType), Resolve: field(func(s interface{}) interface{} {
+			return actorRef{s.(rankedActor).Login, s.(rankedActor).window}
+		})},
+	}}
+	repoRankType := &graphql.Object{Name: "RepositoryRank", Description: "A repository's place on a leaderboard.", Fields: []*graphql.Field{
+		{Name: "rank", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(rankedRepo).Rank })},
+		{Name: "eventCount", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(rankedRepo).Events })},
+		{Name: "repository", Type: nonNull(repoType), Resolve: field(func(s interface{}) interface{} {
+			return repoRef{s.(rankedRepo).URL, s.(rankedRepo).window}
+		})},
+	}}
+	repoActivityType := &graphql.Object{Name: "RepositoryActivity", Description: "An actor's events in a repository.", Fields: []*graphql.Field{
+		{Name: "repository", Type: nonNull(repoType), Resolve: field(func(s interface{}) interface{} { return repoRef{url: s.(models.RepoActivity).URL} })},
+		{Name: "lastSeen", Type: nonNull(graphqlTime), Resolve: field(func(s interface{}) interface{} { return s.(models.RepoActivity).LastSeen })},
+		{Name: "eventCount", Type: nonNull(integer), Resolve: field(func(s interface{}) interface{} { return s.(models.RepoActivity).Events })},
+	}}
+	recentEventsArgs := []*graphql.Argument{{Name: "first", Type: nonNull(integer), Default: 10}}
+
+	eventType.Fields = []*graphql.Field{
+		{Name: "id", Type: nonNull(graphql.ID), Resolve: field(func(s interface{}) interface{} { return s.(models.StoredEvent).ID })},
+		{Name: "githubId", Type: str, Resolve: field(func(s interface{}) interface{} {
+			if id := s.(models.StoredEvent).GitHubID; id != "" {
+				return id
+			}
+			return nil
+		})},
+		{Name: "type", Type: nonNull(str), Resolve: field(func(s interface{}) interface{} { return s.(models.StoredEvent).Type })},
+		{Name: "createdAt", Type: nonNull(graphqlTime), Resolve: field(func(s interface{}) interface{} { return s.(models.StoredEvent).CreatedAt })},
+		{Name: "source", Type: nonNull(str), Resolve: field(func(s interface{}) interface{} { return s.(models.StoredEvent).Source })},
+		{Name: "actor", Type: actorType, Resolve: field(func(s interface{}) interface{} {
+			if login := s.(models.StoredEvent).Actor; login != "" {
+				return actorRef{login: login}
+			}
+			return nil
+		})},
+		{Name: "repository", Type: repoType, Resolve: field(func(s interface{}) interface{} {
+			if url := s.(models.StoredEvent).Repo; url != "" {
+				return repoRef{url: url}
+			}
+			return nil
+		})},
+		{Name: "commits", Type: nonNull(list(nonNull(commitType))), ListSize: 5, Resolve: field(func(s interface{}) interface{} {
+			return s.(models.StoredEvent).Emails
+		})},
+	}
+
+	actorType.Fields = []*graphql.Field{
+		{Name: "login", Type: nonNull(str), Resolve: field(func(s interface{}) interface{} { return s.(actorRef).login })},
+		{Name: "eventCount", Type: nonNull(integer), Resolve: actorField(func(p models.ActorProfile) interface{} { return p.Events })},
+		{Name: "eventsByType", Type: nonNull(list(nonNull(eventCountType))), ListSize: 10, Resolve: actorField(func(p models.ActorProfile) interface{} {
+			return eventCounts(p.EventsByType)
+		})},
+		{Name: "firstSeen", Type: graphqlTime, Resolve: actorField(func(p models.ActorProfile) interface{} { return p.FirstSeen })},
+		{Name: "lastSeen", Type: graphqlTime, Resolve: actorField(func(p models.ActorProfile) interface{} { return p.LastSeen })},
+		{Name: "repositories", Type: nonNull(list(nonNull(repoActivityType))), ListSize: 10, Description: "Most recent first.",
+			Resolve: actorField(func(p models.ActorProfile) interface{} { return p.Repos })},
+		{Name: "emails", Type: nonNull(list(nonNull(str))), Resolve: actorField(func(p models.ActorProfile) interface{} { return p.Emails })},
+		{Name: "recentEvents", Type: nonNull(list(nonNull(eventType))), Args: recentEventsArgs, Description: "The latest events of any time, newest first.",
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				first, err := firstArg(args)
+				if err != nil {
+					return nil, err
+				}
+				logins := make([]string, len(sources))
+				for i, source := range sources {
+					logins[i] = source.(actorRef).login
+				}
+				loader := loaderFrom(ctx)
+				events, err := latestEvents(loader.actorEvents, logins, first, loader.eventStore.LatestActorEvents)
+				if err != nil {
+					return nil, err
+				}
+				values := make([]interface{}, len(sources))
+				for i := range events {
+					values[i] = events[i]
+				}
+				return values, nil
+			}},
+	}
+
+	repoType.Fields = []*graphql.Field{
+		{Name: "url", Type: nonNull(str), Resolve: field(func(s interface{}) interface{} { return s.(repoRef).url })},
+		{Name: "owner", Type: str, Resolve: field(func(s interface{}) interface{} {
+			if owner, _, ok := strings.Cut(strings.TrimPrefix(s.(repoRef).url, repoURLPrefix), "/"); ok {
+				return owner
+			}
+			return nil
+		})},
+		{Name: "name", Type: str, Resolve: field(func(s interface{}) interface{} {
+			if _, name, ok := strings.Cut(strings.TrimPrefix(s.(repoRef).url, repoURLPrefix), "/"); ok {
+				return name
+			}
+			return nil
+		})},
+		{Name: "eventCount", Type: nonNull(integer), Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+			return s.Events
+		})},
+		{Name: "eventsByType", Type: nonNull(list(nonNull(eventCountType))), ListSize: 10,
+			Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+				return eventCounts(s.EventsByType)
+			})},
+		{Name: "contributorCount", Type: nonNull(integer), Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+			return s.Contributors
+		})},
+		{Name: "commitCount", Type: nonNull(integer), Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+			return s.Commits
+		})},
+		{Name: "firstActivity", Type: graphqlTime, Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+			return s.FirstActivity
+		})},
+		{Name: "lastActivity", Type: graphqlTime, Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+			return s.LastActivity
+		})},
+		{Name: "busiestHours", Type: nonNull(list(nonNull(hourCountType))), ListSize: 5, Description: "Busiest first.",
+			Resolve: repoField(func(s models.RepoStats, _ models.TimeRange, _ map[string]interface{}) interface{} {
+				return s.BusiestHours
+			})},
+		{Name: "topActors", Type: nonNull(list(nonNull(actorRankType))), Args: []*graphql.Argument{{Name: "first", Type: nonNull(integer), Default: 10}},
+			Description: "The actors with the most events in the repository, at most 10.",
+			Resolve: repoField(func(s models.RepoStats, window models.TimeRange, args map[string]interface{}) interface{} {
+				ranks := make([]rankedActor, 0, len(s.TopActors))
+				for _, rank := range s.TopActors {
+					if len(ranks) < args["first"].(int) {
+						ranks = append(ranks, rankedActor{rank, window})
+					}
+				}
+				return ranks
+			})},
+		{Name: "recentEvents", Type: nonNull(list(nonNull(eventType))), Args: recentEventsArgs, Description: "The latest events of any time, newest first.",
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				first, err := firstArg(args)
+				if err != nil {
+					return nil, err
+				}
+				urls := make([]string, len(sources))
+				for i, source := range sources {
+					urls[i] = source.(repoRef).url
+				}
+				loader := loaderFrom(ctx)
+				events, err := latestEvents(loader.repoEvents, urls, first, loader.eventStore.LatestRepoEvents)
+				if err != nil {
+					return nil, err
+				}
+				values := make([]interface{}, len(sources))
+				for i := range events {
+					values[i] = events[i]
+				}
+				return values, nil
+			}},
+	}
+
+	filterType := &graphql.InputObject{Name: "EventFilter", Description: "Selects events; empty lists do not filter.", Fields: append([]*graphql.Argument{
+		{Name: "types", Type: list(nonNull(str))},
+		{Name: "actors", Type: list(nonNull(str)), Description: "Actor logins."},
+		{Name: "repos", Type: list(nonNull(str)), Description: "Repository URLs."},
+		{Name: "sources", Type: list(nonNull(str)), Description: "github or import."},
+	}, windowArguments...)}
+	leaderboardArgs := append([]*graphql.Argument{
+		{Name: "types", Type: list(nonNull(str))},
+		{Name: "first", Type: nonNull(integer), Default: defaultTopLimit},
+		{Name: "excludeBots", Type: nonNull(boolean), Default: false},
+	}, windowArguments...)
+
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{Name: "events", Type: nonNull(connectionType), Description: "The events matching the filter, newest first unless oldestFirst.",
+			Args: []*graphql.Argument{
+				{Name: "filter", Type: filterType},
+				{Name: "oldestFirst", Type: nonNull(boolean), Default: false},
+				{Name: "first", Type: nonNull(integer), Default: 20},
+				{Name: "after", Type: str, Description: "The endCursor of the previous page."},
+			},
+			Resolve: rootField(func(eventStore store.EventStore, args map[string]interface{}) (interface{}, error) {
+				filterArgs, _ := args["filter"].(map[string]interface{})
+				window, err := windowArgs(filterArgs)
+				if err != nil {
+					return nil, err
+				}
+				filter := models.EventFilter{
+					TimeRange: window,
+					Types:     stringsArg(filterArgs["types"]),
+					Actors:    stringsArg(filterArgs["actors"]),
+					Repos:     stringsArg(filterArgs["repos"]),
+					Sources:   stringsArg(filterArgs["sources"]),
+				}
+				page := models.Page{}
+				if page.Limit, err = firstArg(args); err != nil {
+					return nil, err
+				}
+				if after, ok := args["after"].(string); ok {
+					if page.After, err = decodeCursor(after); err != nil {
+						return nil, err
+					}
+				}
+
+				events, next, err := eventStore.ListEvents(filter, !args["oldestFirst"].(bool), page)
+				if err == store.ErrInvalidCursor {
+					return nil, fmt.Errorf("cursor is invalid")
+				}
+				if err != nil {
+					return nil, databaseError(err)
+				}
+				return eventConnection{events, next}, nil
+			})},
+		{Name: "event", Type: eventType, Args: []*graphql.Argument{{Name: "id", Type: nonNull(graphql.ID)}},
+			Resolve: rootField(func(eventStore store.EventStore, args map[string]interface{}) (interface{}, error) {
+				id, err := strconv.ParseInt(args["id"].(string), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("id must be an integer")
+				}
+				event, err := eventStore.GetEvent(id)
+				if err == store.ErrNotFound {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, databaseError(err)
+				}
+				return event, nil
+			})},
+		{Name: "actor", Type: actorType, Description: "The actor with the login, summarized over the window, or null if unknown.",
+			Args: append([]*graphql.Argument{{Name: "login", Type: nonNull(str)}}, windowArguments...),
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				window, err := windowArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				ref := actorRef{args["login"].(string), window}
+				profiles, err := loaderFrom(ctx).actorProfiles([]actorRef{ref})
+				if err != nil || profiles[0] == nil {
+					return []interface{}{nil}, err
+				}
+				return []interface{}{ref}, nil
+			}},
+		{Name: "repository", Type: repoType, Description: "The repository owner/name, summarized over the window, or null if unknown.",
+			Args: append([]*graphql.Argument{{Name: "owner", Type: nonNull(str)}, {Name: "name", Type: nonNull(str)}}, windowArguments...),
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				window, err := windowArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				ref := repoRef{repoURLPrefix + args["owner"].(string) + "/" + args["name"].(string), window}
+				stats, err := loaderFrom(ctx).repoStats([]repoRef{ref})
+				if err != nil || stats[0] == nil {
+					return []interface{}{nil}, err
+				}
+				return []interface{}{ref}, nil
+			}},
+		{Name: "topActors", Type: nonNull(list(nonNull(actorRankType))), Args: leaderboardArgs,
+			Description: "The actors with the most events of the types in the window.",
+			Resolve: rootField(func(eventStore store.EventStore, args map[string]interface{}) (interface{}, error) {
+				window, limit, err := leaderboardArgsOf(args)
+				if err != nil {
+					return nil, err
+				}
+				ranks, err := eventStore.TopActors(window, stringsArg(args["types"]), limit, args["excludeBots"].(bool))
+				if err != nil {
+					return nil, databaseError(err)
+				}
+				ranked := make([]rankedActor, len(ranks))
+				for i, rank := range ranks {
+					ranked[i] = rankedActor{rank, window}
+				}
+				return ranked, nil
+			})},
+		{Name: "topRepositories", Type: nonNull(list(nonNull(repoRankType))), Args: leaderboardArgs,
+			Description: "The repositories with the most events of the types in the window.",
+			Resolve: rootField(func(eventStore store.EventStore, args map[string]interface{}) (interface{}, error) {
+				window, limit, err := leaderboardArgsOf(args)
+				if err != nil {
+					return nil, err
+				}
+				ranks, err := eventStore.TopRepos(window, stringsArg(args["types"]), limit, args["excludeBots"].(bool))
+				if err != nil {
+					return nil, databaseError(err)
+				}
+				ranked := make([]rankedRepo, len(ranks))
+				for i, rank := range ranks {
+					ranked[i] = rankedRepo{rank, window}
+				}
+				return ranked, nil
+			})},
+		{Name: "eventCounts", Type: nonNull(list(nonNull(eventCountType))), Args: windowArguments, ListSize: 20,
+			Description: "The number of ingested events per type in the window.",
+			Resolve: rootField(func(eventStore store.EventStore, args map[string]interface{}) (interface{}, error) {
+				window, err := windowArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				counts, err := eventStore.EventCounts(window)
+				if err != nil {
+					return nil, databaseError(err)
+				}
+				converted := make(map[string]int64, len(counts))
+				for eventType, count := range counts {
+					converted[eventType] = int64(count)
+				}
+				return eventCounts(converted), nil
+			})},
+	}}
+
+	return &graphql.Schema{Query: query, MaxDepth: graphqlMaxDepth, MaxComplexity: graphqlMaxComplexity}
+}
+
+// leaderboardArgsOf reads the window and "first" arguments of a leaderboard.
+func leaderboardArgsOf(args map[string]interface{}) (models.TimeRange, int, error) {
+	window, err := windowArgs(args)
+	if err != nil {
+		return window, 0, err
+	}
+	first := args["first"].(int)
+	if first < 1 || first > maxTopLimit {
+		return window, 0, fmt.Errorf("first must be between 1 and %d", maxTopLimit)
+	}
+	return window, first, nil
+}
+
+// rootField returns a resolver of a field of the query type.
+func rootField(resolve func(eventStore store.EventStore, args map[string]interface{}) (interface{}, error)) graphql.Resolver {
+	return func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		v, err := resolve(loaderFrom(ctx).eventStore, args)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+}
+
+// graphqlSchema is the schema of /graphql, built once.
+var graphqlSchema = newGraphQLSchema()
+
+// HandleGraphQL executes GraphQL queries over events, actors, repositories and
+// their aggregates (see GetGraphQLSchema). POST takes a JSON body with
+// "query", "operationName" and "variables", or the bare query as
+// application/graphql; GET takes the same as query parameters, with
+// "variables" as JSON. Queries that cannot be executed, because they are
+// invalid or exceed the depth and complexity limits, are answered with 400.
+func HandleGraphQL(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request graphql.Request
+		if r.Method == http.MethodGet {
+			query := r.URL.Query()
+			request.Query = query.Get("query")
+			request.OperationName = query.Get("operationName")
+			if v := query.Get("variables"); v != "" {
+				if err := decodeJSON(strings.NewReader(v), &request.Variables); err != nil {
+					http.Error(w, "variables must be a JSON object", http.StatusBadRequest)
+					return
+				}
+			}
+		} else {
+			if r.Body == nil {
+				r.Body = http.NoBody
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, graphqlMaxBody))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("body must be at most %d bytes", graphqlMaxBody), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+				request.Query = string(body)
+			} else if err := decodeJSON(strings.NewReader(string(body)), &request); err != nil {
+				http.Error(w, "body must be a JSON object with a query", http.StatusBadRequest)
+				return
+			}
+		}
+		if strings.TrimSpace(request.Query) == "" {
+			http.Error(w, "query is required", http.StatusBadRequest)
+			return
+		}
+
+		loader := &graphqlLoader{
+			eventStore:  eventStore,
+			profiles:    make(map[loadKey]*models.ActorProfile),
+			stats:       make(map[loadKey]*models.RepoStats),
+			actorEvents: make(map[latestKey][]models.StoredEvent),
+			repoEvents:  make(map[latestKey][]models.StoredEvent),
+		}
+		response := graphqlSchema.Execute(context.WithValue(r.Context(), loaderContextKey{}, loader), request)
+
+		data, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+			return
+		}
+		status := http.StatusOK
+		if response.Data == nil {
+			status = http.StatusBadRequest
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(data)
+	}
+}
+
+// decodeJSON decodes a JSON value keeping numbers as json.Number, as GraphQL
+// variables expect.
+func decodeJSON(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// GetGraphQLSchema describes the schema of /graphql in the GraphQL schema
+// definition language.
+func GetGraphQLSchema() http.HandlerFunc {
+	sdl := graphqlSchema.SDL()
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, sdl)
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingStore counts the batched lookups of a store.
+type countingStore struct {
+	*store.MemoryStore
+	calls map[string]int
+}
+
+func (s *countingStore) ActorProfilesByLogin(logins []string, window models.TimeRange) (map[string]models.ActorProfile, error) {
+	s.calls["ActorProfilesByLogin"]++
+	return s.MemoryStore.ActorProfilesByLogin(logins, window)
+}
+
+func (s *countingStore) RepoStatsByURL(urls []string, window models.TimeRange) (map[string]models.RepoStats, error) {
+	s.calls["RepoStatsByURL"]++
+	return s.MemoryStore.RepoStatsByURL(urls, window)
+}
+
+func (s *countingStore) LatestActorEvents(logins []string, limit int) (map[string][]models.StoredEvent, error) {
+	s.calls["LatestActorEvents"]++
+	return s.MemoryStore.LatestActorEvents(logins, limit)
+}
+
+// setupGraphQLStore stores events of three actors in two repositories.
+func setupGraphQLStore(t *testing.T) *countingStore {
+	eventStore := &countingStore{MemoryStore: setupTestStore(t), calls: make(map[string]int)}
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	hello, world := repoURLPrefix+"octo/hello", repoURLPrefix+"octo/world"
+	for i, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: hello},
+			Payload: models.Payload{Commits: []models.Commit{{Author: models.Author{Email: "alice@example.com"}}}}},
+		{Type: "IssuesEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: world}},
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: hello}},
+		{Type: "WatchEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: hello}},
+		{Type: "WatchEvent", Actor: models.Actor{Login: "carol"}, Repo: models.Repo{URL: world}},
+	} {
+		event.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		if _, err := eventStore.StoreEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return eventStore
+}
+
+// postGraphQL sends a query to /graphql and returns the status and body.
+func postGraphQL(t *testing.T, eventStore store.EventStore, query string, variables map[string]interface{}) (int, string) {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	HandleGraphQL(eventStore)(rr, req)
+	return rr.Code, strings.TrimSpace(rr.Body.String())
+}
+
+func TestGraphQLNestedQueryIsBatched(t *testing.T) {
+	eventStore := setupGraphQLStore(t)
+	code, body := postGraphQL(t, eventStore, `{
+		repository(owner: "octo", name: "hello") {
+			name eventCount commitCount
+			topActors {
+				rank eventCount
+				actor {
+					login eventCount
+					recentEvents(first: 2) { type repository { name } commits { authorEmail } }
+				}
+			}
+		}
+	}`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", code, body)
+	}
+
+	expected := `{"data":{"repository":{"name":"hello","eventCount":3,"commitCount":1,"topActors":[` +
+		`{"rank":1,"eventCount":2,"actor":{"login":"alice","eventCount":3,"recentEvents":[` +
+		`{"type":"WatchEvent","repository":{"name":"hello"},"commits":[]},` +
+		`{"type":"IssuesEvent","repository":{"name":"world"},"commits":[]}]}},` +
+		`{"rank":2,"eventCount":1,"actor":{"login":"bob","eventCount":1,"recentEvents":[` +
+		`{"type":"PushEvent","repository":{"name":"hello"},"commits":[]}]}}]}}}`
+	if body != expected {
+		t.Errorf("Expected %s, got %s", expected, body)
+	}
+
+	// One lookup per level, whatever the number of actors
+	for name, calls := range map[string]int{"RepoStatsByURL": 1, "ActorProfilesByLogin": 1, "LatestActorEvents": 1} {
+		if eventStore.calls[name] != calls {
+			t.Errorf("Expected %d calls of %s, got %d", calls, name, eventStore.calls[name])
+		}
+	}
+}
+
+func TestGraphQLEventsPagination(t *testing.T) {
+	eventStore := setupGraphQLStore(t)
+	query := `query($after: String) {
+		events(filter: {types: ["PushEvent", "WatchEvent"]}, oldestFirst: true, first: 2, after: $after) {
+			nodes { type actor { login } commits { authorEmail } }
+			pageInfo { endCursor hasNextPage }
+		}
+	}`
+
+	var page struct {
+		Data struct {
+			Events struct {
+				Nodes []struct {
+					Type  string `json:"type"`
+					Actor struct {
+						Login string `json:"login"`
+					} `json:"actor"`
+					Commits []struct {
+						AuthorEmail string `json:"authorEmail"`
+					} `json:"commits"`
+				} `json:"nodes"`
+				PageInfo struct {
+					EndCursor   string `json:"endCursor"`
+					HasNextPage bool   `json:"hasNextPage"`
+				} `json:"pageInfo"`
+			} `json:"events"`
+		} `json:"data"`
+	}
+	var logins []string
+	var after interface{}
+	for i := 0; i < 3; i++ {
+		code, body := postGraphQL(t, eventStore, query, map[string]interface{}{"after": after})
+		if code != http.StatusOK {
+			t.Fatalf("Unexpected status %d: %s", code, body)
+		}
+		page.Data.Events.PageInfo.EndCursor = ""
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, node := range page.Data.Events.Nodes {
+			logins = append(logins, node.Actor.Login)
+		}
+		if i == 0 && (len(page.Data.Events.Nodes[0].Commits) != 1 || page.Data.Events.Nodes[0].Commits[0].AuthorEmail != "alice@example.com") {
+			t.Errorf("Expected the commit of the first push, got %+v", page.Data.Events.Nodes[0])
+		}
+		if !page.Data.Events.PageInfo.HasNextPage {
+			break
+		}
+		after = page.Data.Events.PageInfo.EndCursor
+	}
+	if strings.Join(logins, ",") != "alice,bob,alice,carol" {
+		t.Errorf("Unexpected events by %v", logins)
+	}
+}
+
+func TestGraphQLAggregates(t *testing.T) {
+	eventStore := setupGraphQLStore(t)
+	code, body := postGraphQL(t, eventStore, `{
+		topActors(first: 1) { rank actor { login } }
+		topRepositories(types: ["WatchEvent"]) { rank eventCount repository { url } }
+		eventCounts { type count }
+		actor(login: "alice", since: "2024-05-01T10:00:00Z") { eventCount emails repositories { repository { name } eventCount } }
+		nobody: actor(login: "nobody") { login }
+	}`, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", code, body)
+	}
+	expected := `{"data":{"topActors":[{"rank":1,"actor":{"login":"alice"}}],` +
+		`"topRepositories":[{"rank":1,"eventCount":1,"repository":{"url":"https://api.github.com/repos/octo/hello"}},` +
+		`{"rank":1,"eventCount":1,"repository":{"url":"https://api.github.com/repos/octo/world"}}],` +
+		`"eventCounts":[{"type":"IssuesEvent","count":1},{"type":"PushEvent","count":2},{"type":"WatchEvent","count":2}],` +
+		`"actor":{"eventCount":2,"emails":[],"repositories":[{"repository":{"name":"hello"},"eventCount":1},{"repository":{"name":"world"},"eventCount":1}]},` +
+		`"nobody":null}}`
+	if body != expected {
+		t.Errorf("Expected %s, got %s", expected, body)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	eventStore := setupGraphQLStore(t)
+	for _, tc := range []struct {
+		query string
+		code  int
+		error string
+	}{
+		{`{ events { nodes { nope } } }`, http.StatusBadRequest, `cannot query field \"nope\" on type Event`},
+		{`{ events(first: 500) { nodes { id } } }`, http.StatusOK, `first must be between 1 and 100`},
+		{`{ events(first: null, oldestFirst: true) { nodes { id } } }`, http.StatusBadRequest, `argument \"first\" of Query.events: expected a value of type Int!, found null`},
+		{`{ events(oldestFirst: null) { nodes { id } } }`, http.StatusBadRequest, `argument \"oldestFirst\" of Query.events: expected a value of type Boolean!, found null`},
+		{`{ topActors(excludeBots: null) { rank } }`, http.StatusBadRequest, `argument \"excludeBots\" of Query.topActors: expected a value of type Boolean!, found null`},
+		{`{ topRepositories(first: null) { rank } }`, http.StatusBadRequest, `argument \"first\" of Query.topRepositories: expected a value of type Int!, found null`},
+		{`{ repository(owner: "octo", name: "hello") { topActors(first: null) { rank } } }`, http.StatusBadRequest, `argument \"first\" of Repository.topActors`},
+		{`{ actor(login: "alice") { recentEvents(first: null) { id } } }`, http.StatusBadRequest, `argument \"first\" of Actor.recentEvents`},
+		{`{ events(after: "bm9wZQ") { nodes { id } } }`, http.StatusOK, `cursor is invalid`},
+		{`{ actor(login: "alice", since: "soon") { login } }`, http.StatusOK, `since must be an RFC 3339 time`},
+		{`{ events(first: 100) { nodes { actor { recentEvents(first: 100) { id } } } } }`, http.StatusBadRequest, `query complexity 10301 exceeds the limit of 5000`},
+		{`{ events(first: 1) { nodes { actor { recentEvents(first: 1) { repository { recentEvents(first: 1) { actor {
+			recentEvents(first: 1) { repository { recentEvents(first: 1) { id } } } } } } } } } } }`,
+			http.StatusBadRequest, `query depth 11 exceeds the limit of 10`},
+	} {
+		code, body := postGraphQL(t, eventStore, tc.query, nil)
+		if code != tc.code || !strings.Contains(body, tc.error) {
+			t.Errorf("%s: expected %d with %q, got %d %s", tc.query, tc.code, tc.error, code, body)
+		}
+	}
+}
+
+func TestGraphQLGet(t *testing.T) {
+	eventStore := setupGraphQLStore(t)
+	query := url.Values{
+		"query":     {`query($login: String!) { actor(login: $login) { login eventCount } }`},
+		"variables": {`{"login": "bob"}`},
+	}
+	req, _ := http.NewRequest("GET", "/graphql?"+query.Encode(), nil)
+	rr := httptest.NewRecorder()
+	HandleGraphQL(eventStore)(rr, req)
+	if expected := `{"data":{"actor":{"login":"bob","eventCount":1}}}`; rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("Expected %s, got %d %s", expected, rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql/schema", nil)
+	rr = httptest.NewRecorder()
+	GetGraphQLSchema()(rr, req)
+	for _, expected := range []string{"type Query {", "type Repository {", "input EventFilter {", "scalar Time"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("Expected %q in the schema, got %s", expected, rr.Body.String())
+		}
+	}
+}
+
+func TestGraphQLNullVariables(t *testing.T) {
+	eventStore := setupGraphQLStore(t)
+	query := `query($first: Int, $excludeBots: Boolean) { topActors(first: $first, excludeBots: $excludeBots) { rank } }`
+
+	// Variables that are left out fall back to the defaults
+	code, body := postGraphQL(t, eventStore, query, nil)
+	if expected := `{"data":{"topActors":[{"rank":1},{"rank":2},{"rank":2}]}}`; code != http.StatusOK || body != expected {
+		t.Errorf("Expected %s, got %d %s", expected, code, body)
+	}
+
+	// Variables that are null are rejected
+	code, body = postGraphQL(t, eventStore, query, map[string]interface{}{"first": nil, "excludeBots": nil})
+	if code != http.StatusBadRequest || !strings.Contains(body, `found null`) {
+		t.Errorf("Expected null variables to be rejected, got %d %s", code, body)
+	}
+}
+
+func TestGraphQLIntrospection(t *testing.T) {
+	code, body := postGraphQL(t, setupGraphQLStore(t), `{
+		__schema { queryType { name } }
+		__type(name: "EventFilter") { kind inputFields { name } }
+		events: __type(name: "Query") { fields { name args { name defaultValue type { kind ofType { name } } } } }
+	}`, nil)
+	for _, expected := range []string{
+		`"__schema":{"queryType":{"name":"Query"}}`,
+		`"__type":{"kind":"INPUT_OBJECT","inputFields":[{"name":"types"},{"name":"actors"},{"name":"repos"},{"name":"sources"},{"name":"since"},{"name":"until"}]}`,
+		`{"name":"first","defaultValue":"20","type":{"kind":"NON_NULL","ofType":{"name":"Int"}}}`,
+	} {
+		if code != http.StatusOK || !strings.Contains(body, expected) {
+			t.Errorf("Expected %s in %d %s", expected, code, body)
+		}
+	}
+}
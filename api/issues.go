@@ -0,0 +1,46 @@
+package api
+
+import (
+	"awsomeProject/pkg/store"
+	"net/http"
+	"strconv"
+)
+
+// GetIssueMetrics returns per-repository median time to first response, median
+// time to close and the current open backlog. An optional "repo" query
+// parameter restricts the result to a single repository URL.
+func GetIssueMetrics(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics, err := eventStore.IssueMetrics(r.URL.Query().Get("repo"))
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, metrics)
+	}
+}
+
+// GetIssueBacklog returns, for each of the last "days" days (default 30), the
+// number of issues that were open at the end of that day per repository.
+func GetIssueBacklog(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := 30
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 365 {
+				http.Error(w, "days must be an integer between 1 and 365", http.StatusBadRequest)
+				return
+			}
+			days = n
+		}
+
+		backlog, err := eventStore.IssueBacklog(r.URL.Query().Get("repo"), days)
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, backlog)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Size limits of the leaderboards
+const (
+	defaultTopLimit = 10
+	maxTopLimit     = 100
+)
+
+// leaderboardQuery holds the query parameters of the leaderboards.
+type leaderboardQuery struct {
+	window      models.TimeRange
+	types       []string
+	limit       int
+	excludeBots bool
+}
+
+// parseLeaderboardQuery reads the query parameters of the leaderboards: the
+// time window (see parseTimeRange), "type" as a comma-separated list of event
+// types, "limit" (default 10) and "exclude_bots" (a boolean).
+func parseLeaderboardQuery(r *http.Request, now time.Time) (leaderboardQuery, error) {
+	q := leaderboardQuery{limit: defaultTopLimit}
+	window, err := parseTimeRange(r, now)
+	if err != nil {
+		return q, err
+	}
+	q.window = window
+	query := r.URL.Query()
+	q.types = splitList(query.Get("type"))
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTopLimit {
+			return q, fmt.Errorf("limit must be an integer between 1 and %d", maxTopLimit)
+		}
+		q.limit = n
+	}
+
+	if v := query.Get("exclude_bots"); v != "" {
+		exclude, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("exclude_bots must be true or false, got %q", v)
+		}
+		q.excludeBots = exclude
+	}
+	return q, nil
+}
+
+// GetTopActors ranks the actors with the most events in the window.
+func GetTopActors(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseLeaderboardQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Query the store to get the most active actors
+		actors, err := eventStore.TopActors(q.window, q.types, q.limit, q.excludeBots)
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+		if err := setCountedWindow(w, eventStore, q.window); err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, actors)
+	}
+}
+
+// GetTopRepos ranks the repositories with the most events in the window.
+func GetTopRepos(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseLeaderboardQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Query the store to get the most active repositories
+		repos, err := eventStore.TopRepos(q.window, q.types, q.limit, q.excludeBots)
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+		if err := setCountedWindow(w, eventStore, q.window); err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, repos)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLeaderboards(t *testing.T) {
+	eventStore := setupTestStore(t)
+	now := time.Now()
+	add := func(eventType, login, repo string, n int, age time.Duration) {
+		for i := 0; i < n; i++ {
+			eventStore.StoreEvent(models.GitHubEvent{Type: eventType, Actor: models.Actor{Login: login}, Repo: models.Repo{URL: repo}, CreatedAt: now.Add(-age)})
+		}
+	}
+	add("PushEvent", "carol", "repo1", 3, time.Minute)
+	add("PushEvent", "alice", "repo2", 3, time.Minute)
+	add("IssuesEvent", "bob", "repo2", 2, time.Minute)
+	add("PushEvent", "dependabot[bot]", "repo3", 5, time.Minute)
+	add("PushEvent", "dave", "repo3", 10, 48*time.Hour)
+
+	get := func(handler http.HandlerFunc, query string, v interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/top?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code == http.StatusOK {
+			json.Unmarshal(rr.Body.Bytes(), v)
+		}
+		return rr.Code
+	}
+
+	var actors []models.ActorRank
+	get(GetTopActors(eventStore), "since=1d&limit=3", &actors)
+	expected := []models.ActorRank{{Rank: 1, Login: "dependabot[bot]", Events: 5}, {Rank: 2, Login: "alice", Events: 3}, {Rank: 2, Login: "carol", Events: 3}}
+	if !reflect.DeepEqual(actors, expected) {
+		t.Errorf("Expected %v, got %v", expected, actors)
+	}
+
+	get(GetTopActors(eventStore), "since=1d&exclude_bots=true&type=PushEvent", &actors)
+	expected = []models.ActorRank{{Rank: 1, Login: "alice", Events: 3}, {Rank: 1, Login: "carol", Events: 3}}
+	if !reflect.DeepEqual(actors, expected) {
+		t.Errorf("Expected %v without bots and issues, got %v", expected, actors)
+	}
+
+	var repos []models.RepoRank
+	get(GetTopRepos(eventStore), "", &repos)
+	expectedRepos := []models.RepoRank{{Rank: 1, URL: "repo3", Events: 15}, {Rank: 2, URL: "repo2", Events: 5}, {Rank: 3, URL: "repo1", Events: 3}}
+	if !reflect.DeepEqual(repos, expectedRepos) {
+		t.Errorf("Expected %v, got %v", expectedRepos, repos)
+	}
+
+	get(GetTopRepos(eventStore), "exclude_bots=true&since=1d", &repos)
+	expectedRepos = []models.RepoRank{{Rank: 1, URL: "repo2", Events: 5}, {Rank: 2, URL: "repo1", Events: 3}}
+	if !reflect.DeepEqual(repos, expectedRepos) {
+		t.Errorf("Expected %v without bot events, got %v", expectedRepos, repos)
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "exclude_bots=maybe", "since=soon"} {
+		if code := get(GetTopActors(eventStore), query, nil); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, code)
+		}
+	}
+}
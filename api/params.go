@@ -0,0 +1,136 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localLayouts are the time formats accepted without a UTC offset. They are
+// interpreted in the zone given by the "tz" parameter.
+var localLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseTimeRange reads the "since", "until" and "tz" query parameters. since
+// and until are RFC 3339 times, times without an offset in the tz zone
+// (default UTC), or durations before now such as "90m", "1h" or "7d".
+func parseTimeRange(r *http.Request, now time.Time) (models.TimeRange, error) {
+	query := r.URL.Query()
+
+	loc := time.UTC
+	if tz := query.Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return models.TimeRange{}, fmt.Errorf("tz must be an IANA time zone such as Europe/Berlin, got %q", tz)
+		}
+		loc = l
+	}
+
+	return parseWindow(query.Get("since"), query.Get("until"), now, loc)
+}
+
+// parseWindow parses the bounds of a time window as described at
+// parseTimeRange. Empty bounds leave the window open.
+func parseWindow(since, until string, now time.Time, loc *time.Location) (models.TimeRange, error) {
+	var window models.TimeRange
+	for _, bound := range []struct {
+		name   string
+		value  string
+		target *time.Time
+	}{{"since", since, &window.Since}, {"until", until, &window.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseTime(bound.value, now, loc)
+		if err != nil {
+			return window, fmt.Errorf("%s must be an RFC 3339 time or a duration such as 1h or 7d, got %q", bound.name, bound.value)
+		}
+		*bound.target = t
+	}
+
+	if !window.Since.IsZero() && !window.Until.IsZero() && !window.Since.Before(window.Until) {
+		return window, fmt.Errorf("since must be before until")
+	}
+	return window, nil
+}
+
+// parseTime parses an absolute time or a duration before now.
+func parseTime(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if d, err := parseDuration(value); err == nil && d > 0 {
+		return now.Add(-d).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// parseDuration extends time.ParseDuration with a "d" suffix for whole days.
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// maxLimit caps the page size of list endpoints.
+const maxLimit = 1000
+
+// parsePage reads the "limit" and "cursor" query parameters. The cursor is the
+// opaque next_cursor of a previous response.
+func parsePage(r *http.Request, defaultLimit int) (models.Page, error) {
+	page := models.Page{Limit: defaultLimit}
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return page, fmt.Errorf("limit must be an integer between 1 and %d", maxLimit)
+		}
+		page.Limit = n
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			return page, err
+		}
+		page.After = after
+	}
+	return page, nil
+}
+
+// decodeCursor returns the key of the last item of a page from its cursor.
+func decodeCursor(cursor string) (string, error) {
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(after) == 0 {
+		return "", fmt.Errorf("cursor is invalid")
+	}
+	return string(after), nil
+}
+
+// encodeCursor turns the key of the last item of a page into an opaque cursor.
+func encodeCursor(after string) string {
+	if after == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(after))
+}
+
+// pageResponse is the body of a paginated list endpoint.
+type pageResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
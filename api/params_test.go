@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2023, 9, 10, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		query        string
+		since, until time.Time
+		wantErr      bool
+	}{
+		{"", time.Time{}, time.Time{}, false},
+		{"since=1h", now.Add(-time.Hour), time.Time{}, false},
+		{"since=7d&until=1d", now.AddDate(0, 0, -7), now.AddDate(0, 0, -1), false},
+		{"since=2023-09-01T00:00:00%2B02:00", time.Date(2023, 8, 31, 22, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"since=2023-09-01&tz=Europe/Berlin", time.Date(2023, 8, 31, 22, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"since=yesterday", time.Time{}, time.Time{}, true},
+		{"since=-1h", time.Time{}, time.Time{}, true},
+		{"tz=Mars/Olympus", time.Time{}, time.Time{}, true},
+		{"since=1h&until=2h", time.Time{}, time.Time{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/event-counts?"+tc.query, nil)
+			window, err := parseTimeRange(req, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", window)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !window.Since.Equal(tc.since) || !window.Until.Equal(tc.until) {
+				t.Errorf("Expected [%v, %v), got [%v, %v)", tc.since, tc.until, window.Since, window.Until)
+			}
+		})
+	}
+}
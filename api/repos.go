@@ -0,0 +1,39 @@
+package api
+
+import (
+	"awsomeProject/pkg/store"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// repoURLPrefix prefixes the owner/name of a repository in the URLs GitHub
+// reports for the repositories of events.
+const repoURLPrefix = "https://api.github.com/repos/"
+
+// GetRepoStats summarizes the events in the time window (see parseTimeRange)
+// of the repository named by the "owner" and "name" path variables.
+func GetRepoStats(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := parseTimeRange(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		vars := mux.Vars(r)
+
+		// Query the store to get the repository's statistics
+		stats, err := eventStore.RepoStats(repoURLPrefix+vars["owner"]+"/"+vars["name"], window)
+		if err == store.ErrNotFound {
+			http.Error(w, "repository not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, stats)
+	}
+}
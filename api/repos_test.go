@@ -0,0 +1,75 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetRepoStats(t *testing.T) {
+	eventStore := setupTestStore(t)
+	repo := models.Repo{URL: repoURLPrefix + "octocat/hello-world"}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	push := func(size int) models.Payload {
+		return models.Payload{Size: size, Commits: []models.Commit{{Author: models.Author{Email: "a@example.com"}}}}
+	}
+	for _, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: repo, Payload: push(3), CreatedAt: day.Add(9 * time.Hour)},
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: repo, Payload: push(1), CreatedAt: day.Add(9*time.Hour + 30*time.Minute)},
+		{Type: "IssuesEvent", Actor: models.Actor{Login: "bob"}, Repo: repo, CreatedAt: day.Add(14 * time.Hour)},
+		{Type: "WatchEvent", Actor: models.Actor{Login: "carol"}, Repo: repo, CreatedAt: day.Add(-48 * time.Hour)},
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: repoURLPrefix + "octocat/other"}, CreatedAt: day},
+	} {
+		eventStore.StoreEvent(event)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/repos/{owner}/{name}/stats", GetRepoStats(eventStore))
+	get := func(path string) (*httptest.ResponseRecorder, models.RepoStats) {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var stats models.RepoStats
+		json.Unmarshal(rr.Body.Bytes(), &stats)
+		return rr, stats
+	}
+
+	rr, stats := get("/repos/octocat/hello-world/stats?since=2024-05-01&until=2024-05-02")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	first, last := day.Add(9*time.Hour), day.Add(14*time.Hour)
+	expected := models.RepoStats{
+		URL:           repo.URL,
+		Events:        3,
+		EventsByType:  map[string]int64{"PushEvent": 2, "IssuesEvent": 1},
+		Contributors:  2,
+		Commits:       4,
+		FirstActivity: &first,
+		LastActivity:  &last,
+		BusiestHours:  []models.HourCount{{Hour: 9, Events: 2}, {Hour: 14, Events: 1}},
+		TopActors:     []models.ActorRank{{Rank: 1, Login: "bob", Events: 2}, {Rank: 2, Login: "alice", Events: 1}},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+
+	if _, stats := get("/repos/octocat/hello-world/stats"); stats.Events != 4 || stats.Contributors != 3 {
+		t.Errorf("Expected all 4 events by 3 contributors without a window, got %+v", stats)
+	}
+	if _, stats := get("/repos/octocat/hello-world/stats?since=1h"); stats.Events != 0 || stats.FirstActivity != nil || len(stats.TopActors) != 0 {
+		t.Errorf("Expected empty stats for a window without events, got %+v", stats)
+	}
+	if rr, _ := get("/repos/octocat/missing/stats"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown repository, got %d", rr.Code)
+	}
+	if rr, _ := get("/repos/octocat/hello-world/stats?since=soon"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid since, got %d", rr.Code)
+	}
+}
@@ -1,14 +1,34 @@
 package api
 
 import (
-	"database/sql"
+	"awsomeProject/pkg/broadcast"
+	"awsomeProject/pkg/store"
 	"github.com/gorilla/mux"
 )
 
-// SetupRoutes sets up the API routes.
-func SetupRoutes(router *mux.Router, db *sql.DB) {
-	router.HandleFunc("/event-counts", GetEventCounts(db)).Methods("GET")
-	router.HandleFunc("/unique-actors", GetUniqueActors(db)).Methods("GET")
-	router.HandleFunc("/unique-repo-urls", GetUniqueRepoURLs(db)).Methods("GET")
-	router.HandleFunc("/unique-emails", GetUniqueEmails(db)).Methods("GET")
+// SetupRoutes sets up the API routes. Live event routes are fed by
+// broadcaster, and browsers may open WebSockets from the API's own host or
+// from allowedOrigins. Admin routes require adminToken as a bearer token and
+// are disabled when it is empty.
+func SetupRoutes(router *mux.Router, eventStore store.EventStore, broadcaster *broadcast.Broadcaster, allowedOrigins []string, adminToken string) {
+	router.HandleFunc("/events", GetEvents(eventStore)).Methods("GET")
+	router.HandleFunc("/events/{id:[0-9]+}", GetEvent(eventStore)).Methods("GET")
+	router.HandleFunc("/events/stream", GetEventStream(eventStore, broadcaster)).Methods("GET")
+	router.HandleFunc("/events/ws", GetEventSocket(eventStore, broadcaster, allowedOrigins)).Methods("GET")
+	router.HandleFunc("/event-counts", GetEventCounts(eventStore)).Methods("GET")
+	router.HandleFunc("/event-counts/timeseries", GetEventTimeseries(eventStore)).Methods("GET")
+	router.HandleFunc("/unique-actors", GetUniqueActors(eventStore)).Methods("GET")
+	router.HandleFunc("/unique-repo-urls", GetUniqueRepoURLs(eventStore)).Methods("GET")
+	router.HandleFunc("/unique-emails", GetUniqueEmails(eventStore)).Methods("GET")
+	router.HandleFunc("/top-actors", GetTopActors(eventStore)).Methods("GET")
+	router.HandleFunc("/top-repos", GetTopRepos(eventStore)).Methods("GET")
+	router.HandleFunc("/repos/{owner}/{name}/stats", GetRepoStats(eventStore)).Methods("GET")
+	router.HandleFunc("/actors/{login}", GetActor(eventStore)).Methods("GET")
+	router.HandleFunc("/graphql", HandleGraphQL(eventStore)).Methods("GET", "POST")
+	router.HandleFunc("/graphql/schema", GetGraphQLSchema()).Methods("GET")
+	router.HandleFunc("/issue-metrics", GetIssueMetrics(eventStore)).Methods("GET")
+	router.HandleFunc("/issue-metrics/backlog", GetIssueBacklog(eventStore)).Methods("GET")
+	router.HandleFunc("/admin/erasures", RequireAdmin(adminToken, PostErasure(eventStore))).Methods("POST")
+	router.HandleFunc("/openapi.json", GetOpenAPISpec()).Methods("GET")
+	router.HandleFunc("/docs", GetDocs()).Methods("GET")
 }
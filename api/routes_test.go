@@ -3,28 +3,23 @@
 package api
 
 import (
-	"database/sql"
+	"awsomeProject/pkg/store"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gorilla/mux"
-	_ "github.com/lib/pq"
 )
 
 func TestSetupRoutes(t *testing.T) {
 	// Create a new router
 	router := mux.NewRouter()
 
-	// Create a test database connection (replace with your database details)
-	testDB, err := sql.Open("postgres", "user=mydb dbname=mydb sslmode=disable")
-	if err != nil {
-		t.Fatalf("Failed to open database connection: %v", err)
-	}
-	defer testDB.Close()
+	// Create an in-memory test store
+	testStore := store.NewMemoryStore()
 
-	// Set up routes with the test database
-	SetupRoutes(router, testDB)
+	// Set up routes with the test store
+	SetupRoutes(router, testStore, setupTestBroadcaster(t, testStore), nil, "")
 
 	// Define test cases for the routes
 	testCases := []struct {
@@ -32,10 +27,34 @@ func TestSetupRoutes(t *testing.T) {
 		method     string
 		statusCode int
 	}{
+		{"/events", "GET", http.StatusOK},
+		{"/events?fields=id,nope", "GET", http.StatusBadRequest},
+		{"/events/1", "GET", http.StatusNotFound},
+		{"/events/99999999999999999999", "GET", http.StatusBadRequest},
+		{"/events/stream?last_event_id=abc", "GET", http.StatusBadRequest},
+		{"/events/ws", "GET", http.StatusBadRequest},
 		{"/event-counts", "GET", http.StatusOK},
+		{"/event-counts/timeseries?resolution=day", "GET", http.StatusOK},
+		{"/event-counts/timeseries?resolution=minute&since=7d", "GET", http.StatusBadRequest},
 		{"/unique-actors", "GET", http.StatusOK},
 		{"/unique-repo-urls", "GET", http.StatusOK},
 		{"/unique-emails", "GET", http.StatusOK},
+		{"/top-actors?type=PushEvent&exclude_bots=true", "GET", http.StatusOK},
+		{"/top-repos?limit=1000", "GET", http.StatusBadRequest},
+		{"/repos/octocat/hello-world/stats", "GET", http.StatusNotFound},
+		{"/actors/octocat", "GET", http.StatusNotFound},
+		{"/graphql?query=%7Bevents%7Bnodes%7Bid%7D%7D%7D", "GET", http.StatusOK},
+		{"/graphql", "POST", http.StatusBadRequest},
+		{"/graphql/schema", "GET", http.StatusOK},
+		{"/issue-metrics", "GET", http.StatusOK},
+		{"/issue-metrics/backlog", "GET", http.StatusOK},
+		{"/issue-metrics/backlog?days=0", "GET", http.StatusBadRequest},
+		{"/event-counts?since=1h&tz=UTC", "GET", http.StatusOK},
+		{"/unique-emails?until=tomorrow", "GET", http.StatusBadRequest},
+		{"/events?include_emails=maybe", "GET", http.StatusBadRequest},
+		{"/admin/erasures", "POST", http.StatusForbidden},
+		{"/openapi.json", "GET", http.StatusOK},
+		{"/docs", "GET", http.StatusOK},
 		// Add more test cases as needed
 	}
 
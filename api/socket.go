@@ -0,0 +1,294 @@
+package api
+
+import (
+	"awsomeProject/pkg/broadcast"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"awsomeProject/pkg/websocket"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timing of /events/ws, variables so tests can shorten them
+var (
+	// socketSnapshotInterval is how often aggregate snapshots are sent.
+	socketSnapshotInterval = 30 * time.Second
+	// socketWriteTimeout is how long a client may take to accept a message.
+	socketWriteTimeout = 10 * time.Second
+	// socketSendBuffer is how many messages may wait for a client before it
+	// is disconnected as a slow consumer.
+	socketSendBuffer = 256
+)
+
+// Limits of /events/ws
+const (
+	maxSubscriptions   = 32
+	maxSocketMessage   = 4 << 10
+	socketSnapshotSpan = time.Hour
+)
+
+// socketRequest is a message from a client: a "subscribe" with an ID and a
+// filter, or an "unsubscribe" of an ID.
+type socketRequest struct {
+	Type   string             `json:"type"`
+	ID     string             `json:"id"`
+	Filter subscriptionFilter `json:"filter"`
+}
+
+// subscriptionFilter selects the events of a subscription. Empty lists do not filter.
+type subscriptionFilter struct {
+	Types  []string `json:"types"`
+	Repos  []string `json:"repos"`
+	Actors []string `json:"actors"`
+}
+
+// socketMessage is a message to a client: "subscribed" and "unsubscribed"
+// acknowledgements, an "event" with the IDs of the subscriptions it matches,
+// a "snapshot" of event counts or an "error".
+type socketMessage struct {
+	Type          string              `json:"type"`
+	ID            string              `json:"id,omitempty"`
+	Subscriptions []string            `json:"subscriptions,omitempty"`
+	Event         *models.StoredEvent `json:"event,omitempty"`
+	Snapshot      *socketSnapshot     `json:"snapshot,omitempty"`
+	Error         string              `json:"error,omitempty"`
+}
+
+// socketSnapshot is the number of events per event type in the last hour.
+type socketSnapshot struct {
+	Since       time.Time      `json:"since"`
+	Until       time.Time      `json:"until"`
+	EventCounts map[string]int `json:"event_counts"`
+}
+
+// socketClient is the state of one WebSocket connection.
+type socketClient struct {
+	conn *websocket.Conn
+	// includeEmails lists the commit author emails of events
+	includeEmails bool
+
+	mu            sync.Mutex
+	subscriptions map[string]models.EventFilter
+	send          chan []byte
+	sendClosed    bool
+
+	// stopped is closed by stop, which records why in code and reason
+	stopOnce sync.Once
+	stopped  chan struct{}
+	code     int
+	reason   string
+}
+
+// stop ends the connection with a close code and reason, unless it was stopped already.
+func (c *socketClient) stop(code int, reason string) {
+	c.stopOnce.Do(func() {
+		c.code, c.reason = code, reason
+		close(c.stopped)
+	})
+}
+
+// queue hands a message to the writer, stopping a client whose send buffer is full.
+func (c *socketClient) queue(message socketMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error encoding WebSocket message: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sendClosed {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		c.stop(websocket.ClosePolicyViolation, "slow consumer")
+	}
+}
+
+// closeSend stops the writer once it has sent the queued messages.
+func (c *socketClient) closeSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendClosed = true
+	close(c.send)
+}
+
+// write sends queued messages until closeSend, stopping the client if it does
+// not accept a message in time. Messages queued after the client was stopped
+// are dropped.
+func (c *socketClient) write() {
+	for data := range c.send {
+		select {
+		case <-c.stopped:
+			continue
+		default:
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+		if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			c.stop(websocket.ClosePolicyViolation, "write timeout")
+			for range c.send {
+			}
+			return
+		}
+	}
+}
+
+// read applies the client's requests until the connection fails or closes.
+func (c *socketClient) read() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.stop(websocket.CloseNormal, "")
+			return
+		}
+		var request socketRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			c.queue(socketMessage{Type: "error", Error: "message must be a JSON object"})
+			continue
+		}
+		if reply, err := c.apply(request); err != nil {
+			c.queue(socketMessage{Type: "error", ID: request.ID, Error: err.Error()})
+		} else {
+			c.queue(reply)
+		}
+	}
+}
+
+// apply changes the subscriptions as requested.
+func (c *socketClient) apply(request socketRequest) (socketMessage, error) {
+	if request.ID == "" {
+		return socketMessage{}, fmt.Errorf("%s needs an id", request.Type)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch request.Type {
+	case "subscribe":
+		if _, ok := c.subscriptions[request.ID]; !ok && len(c.subscriptions) == maxSubscriptions {
+			return socketMessage{}, fmt.Errorf("at most %d subscriptions are allowed", maxSubscriptions)
+		}
+		c.subscriptions[request.ID] = models.EventFilter{Types: request.Filter.Types, Repos: request.Filter.Repos, Actors: request.Filter.Actors}
+		return socketMessage{Type: "subscribed", ID: request.ID}, nil
+	case "unsubscribe":
+		if _, ok := c.subscriptions[request.ID]; !ok {
+			return socketMessage{}, fmt.Errorf("no subscription %q", request.ID)
+		}
+		delete(c.subscriptions, request.ID)
+		return socketMessage{Type: "unsubscribed", ID: request.ID}, nil
+	default:
+		return socketMessage{}, fmt.Errorf("type must be subscribe or unsubscribe, got %q", request.Type)
+	}
+}
+
+// matching returns the sorted IDs of the subscriptions an event matches.
+func (c *socketClient) matching(event models.StoredEvent) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ids []string
+	for id, filter := range c.subscriptions {
+		if filter.MatchStored(event) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GetEventSocket accepts WebSocket connections on which clients subscribe to
+// newly ingested events. Clients send {"type": "subscribe", "id": ..., "filter":
+// {"types": [...], "repos": [...], "actors": [...]}} and {"type":
+// "unsubscribe", "id": ...} messages at any time, and receive the events
+// matching their subscriptions as the broadcaster delivers them, plus a
+// snapshot of the event counts of the last hour on connecting and
+// periodically. Events list their commit author emails if the
+// "include_emails" query parameter is true. Browsers may connect from the API's own host or from
+// allowedOrigins. Clients that fall too far behind are disconnected with
+// code 1008.
+func GetEventSocket(eventStore store.EventStore, broadcaster *broadcast.Broadcaster, allowedOrigins []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		includeEmails, err := parseIncludeEmails(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		conn, err := websocket.Upgrade(w, r, allowedOrigins)
+		if err != nil {
+			return
+		}
+		conn.SetReadLimit(maxSocketMessage)
+		subscription := broadcaster.Subscribe()
+		defer subscription.Unsubscribe()
+
+		client := &socketClient{
+			conn:          conn,
+			includeEmails: includeEmails,
+			subscriptions: make(map[string]models.EventFilter),
+			send:          make(chan []byte, socketSendBuffer),
+			stopped:       make(chan struct{}),
+		}
+		written := make(chan struct{})
+		go func() {
+			client.write()
+			close(written)
+		}()
+		go client.read()
+
+		serveSocket(eventStore, subscription, client)
+		client.closeSend()
+		<-written
+		conn.Close(client.code, client.reason)
+	}
+}
+
+// serveSocket sends the events of a subscription and snapshots to a client
+// until it is stopped.
+func serveSocket(eventStore store.EventStore, subscription *broadcast.Subscription, client *socketClient) {
+	snapshots := time.NewTicker(socketSnapshotInterval)
+	defer snapshots.Stop()
+
+	sendSnapshot := func() bool {
+		until := time.Now()
+		since := until.Add(-socketSnapshotSpan)
+		counts, err := eventStore.EventCounts(models.TimeRange{Since: since, Until: until})
+		if err != nil {
+			log.Printf("Error serving WebSocket: %v", err)
+			client.stop(websocket.CloseInternalError, "store unavailable")
+			return false
+		}
+		client.queue(socketMessage{Type: "snapshot", Snapshot: &socketSnapshot{Since: since, Until: until, EventCounts: counts}})
+		return true
+	}
+	if !sendSnapshot() {
+		return
+	}
+
+	for {
+		select {
+		case <-client.stopped:
+			return
+		case <-snapshots.C:
+			if !sendSnapshot() {
+				return
+			}
+		case event, ok := <-subscription.Events:
+			if !ok {
+				client.stop(websocket.ClosePolicyViolation, "slow consumer")
+				return
+			}
+			if !client.includeEmails {
+				event.Emails = nil
+			}
+			if ids := client.matching(event); len(ids) > 0 {
+				client.queue(socketMessage{Type: "event", Subscriptions: ids, Event: &event})
+			}
+		}
+	}
+}
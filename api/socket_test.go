@@ -0,0 +1,171 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/websocket"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetEventSocket(t *testing.T) {
+	defer func(snapshot time.Duration) {
+		socketSnapshotInterval = snapshot
+	}(socketSnapshotInterval)
+	socketSnapshotInterval = time.Hour
+
+	eventStore := setupTestStore(t)
+	push := func(eventType, login string) {
+		eventStore.StoreEvent(models.GitHubEvent{Type: eventType, Actor: models.Actor{Login: login}, Repo: models.Repo{URL: "repo1"}, CreatedAt: time.Now()})
+	}
+	push("PushEvent", "before")
+	server := httptest.NewServer(GetEventSocket(eventStore, setupTestBroadcaster(t, eventStore), nil))
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	send := func(message string) {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func() socketMessage {
+		t.Helper()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Error reading: %v", err)
+		}
+		var message socketMessage
+		json.Unmarshal(data, &message)
+		return message
+	}
+
+	if message := receive(); message.Type != "snapshot" || message.Snapshot.EventCounts["PushEvent"] != 1 {
+		t.Errorf("Expected a snapshot on connecting, got %+v", message)
+	}
+
+	send(`{"type": "subscribe", "id": "pushes", "filter": {"types": ["PushEvent"]}}`)
+	send(`{"type": "subscribe", "id": "alice", "filter": {"actors": ["alice"]}}`)
+	for _, id := range []string{"pushes", "alice"} {
+		if message := receive(); message.Type != "subscribed" || message.ID != id {
+			t.Errorf("Expected %s to be subscribed, got %+v", id, message)
+		}
+	}
+
+	push("PushEvent", "alice")
+	push("IssuesEvent", "bob")
+	push("IssuesEvent", "alice")
+	for _, expected := range []struct {
+		actor         string
+		subscriptions []string
+	}{{"alice", []string{"alice", "pushes"}}, {"alice", []string{"alice"}}} {
+		message := receive()
+		if message.Type != "event" || message.Event.Actor != expected.actor || !reflect.DeepEqual(message.Subscriptions, expected.subscriptions) {
+			t.Errorf("Expected an event of %s for %v, got %+v", expected.actor, expected.subscriptions, message)
+		}
+	}
+
+	send(`{"type": "unsubscribe", "id": "alice"}`)
+	if message := receive(); message.Type != "unsubscribed" {
+		t.Errorf("Expected alice to be unsubscribed, got %+v", message)
+	}
+	push("IssuesEvent", "alice")
+	push("PushEvent", "bob")
+	if message := receive(); message.Type != "event" || message.Event.Actor != "bob" {
+		t.Errorf("Expected only bob's push after unsubscribing, got %+v", message)
+	}
+
+	for _, request := range []string{`nonsense`, `{"type": "subscribe"}`, `{"type": "unsubscribe", "id": "alice"}`, `{"type": "publish", "id": "x"}`} {
+		send(request)
+		if message := receive(); message.Type != "error" || message.Error == "" {
+			t.Errorf("Expected an error for %s, got %+v", request, message)
+		}
+	}
+}
+
+func TestSocketClientSlowConsumer(t *testing.T) {
+	client := &socketClient{send: make(chan []byte, 2), stopped: make(chan struct{})}
+	for i := 0; i < 3; i++ {
+		client.queue(socketMessage{Type: "snapshot"})
+	}
+
+	select {
+	case <-client.stopped:
+	default:
+		t.Fatal("Expected a client with a full send buffer to be stopped")
+	}
+	if client.code != websocket.ClosePolicyViolation || client.reason != "slow consumer" {
+		t.Errorf("Expected a slow consumer close, got %d %q", client.code, client.reason)
+	}
+}
+
+func TestGetEventSocketDisconnectsSlowConsumers(t *testing.T) {
+	defer func(buffer int) {
+		socketSendBuffer = buffer
+	}(socketSendBuffer)
+	socketSendBuffer = 1
+
+	eventStore := setupTestStore(t)
+	server := httptest.NewServer(GetEventSocket(eventStore, setupTestBroadcaster(t, eventStore), nil))
+	defer server.Close()
+	conn, err := websocket.Dial("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "subscribe", "id": "all"}`))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, expected := range []string{"snapshot", "subscribed"} {
+		_, data, err := conn.ReadMessage()
+		if err != nil || !strings.Contains(string(data), `"type":"`+expected+`"`) {
+			t.Fatalf("Expected a %s message, got %s (%v)", expected, data, err)
+		}
+	}
+
+	// Stop reading while a burst of events is stored, so that the server's
+	// socket buffers fill up
+	payload := models.Payload{Commits: []models.Commit{{Author: models.Author{Email: strings.Repeat("x", 60000) + "@example.com"}}}}
+	for i := 0; i < 200; i++ {
+		eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Payload: payload, CreatedAt: time.Now()})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for {
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code != websocket.ClosePolicyViolation {
+				t.Errorf("Expected a policy violation close, got %v", closeErr)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Expected the server to close the connection, got %v", err)
+		}
+	}
+}
+
+func TestGetEventSocketChecksOrigin(t *testing.T) {
+	eventStore := setupTestStore(t)
+	handler := GetEventSocket(eventStore, setupTestBroadcaster(t, eventStore), []string{"https://dashboard.example"})
+
+	req := httptest.NewRequest("GET", "http://api.example/events/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://evil.example")
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a foreign origin, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"awsomeProject/pkg/broadcast"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// streamHeartbeatInterval is how often a comment is sent to keep idle
+// connections of /events/stream open, a variable so tests can shorten it.
+var streamHeartbeatInterval = 15 * time.Second
+
+// Limits of /events/stream
+const (
+	// streamBatchSize caps the number of events replayed from the store per query.
+	streamBatchSize = 100
+	// streamRetry is how long disconnected clients wait before reconnecting.
+	streamRetry = 3 * time.Second
+)
+
+// GetEventStream streams newly ingested events matching the "type", "actor",
+// "repo" and "source" query parameters (see parseEventFilter) as Server-Sent
+// Events, as the broadcaster delivers them, with their commit author emails
+// if "include_emails" is true. Each event carries its store ID,
+// so a client reconnecting with the Last-Event-ID header, or the
+// "last_event_id" query parameter, resumes with the events stored after it.
+// An event committed late (see broadcast.Lookback) may follow events with
+// greater IDs, and is missed by a client that reconnects before it arrives.
+// New clients start with the next ingested event. Clients that fall behind
+// the broadcaster are disconnected and resume on reconnecting.
+func GetEventStream(eventStore store.EventStore, broadcaster *broadcast.Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		filter := parseEventFilter(r)
+		includeEmails, err := parseIncludeEmails(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+		subscription := broadcaster.Subscribe()
+		defer subscription.Unsubscribe()
+		afterID := subscription.After
+		if lastID != "" {
+			id, err := strconv.ParseInt(lastID, 10, 64)
+			if err != nil || id < 0 {
+				http.Error(w, "Last-Event-ID must be an event id", http.StatusBadRequest)
+				return
+			}
+			afterID = id
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		flusher.Flush()
+
+		send := func(event models.StoredEvent) bool {
+			if !includeEmails {
+				event.Emails = nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error streaming events: %v", err)
+				return false
+			}
+			_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, data)
+			return err == nil
+		}
+
+		// Replay the events stored between the client's last event and the
+		// subscription, after which the broadcaster takes over. Events
+		// committed late may be replayed and then delivered, so the replayed
+		// IDs the broadcaster may still deliver are remembered.
+		replayed := make(map[int64]bool)
+		for afterID < subscription.After {
+			events, err := eventStore.EventsAfter(filter, afterID, streamBatchSize)
+			if err != nil {
+				log.Printf("Error streaming events: %v", err)
+				return
+			}
+			for _, event := range events {
+				if event.ID > subscription.After {
+					break
+				}
+				if event.ID > subscription.After-broadcast.Lookback {
+					replayed[event.ID] = true
+				}
+				if !send(event) {
+					return
+				}
+			}
+			flusher.Flush()
+			if len(events) < streamBatchSize {
+				break
+			}
+			afterID = events[len(events)-1].ID
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case event, ok := <-subscription.Events:
+				if !ok {
+					return
+				}
+				if filter.MatchStored(event) && !replayed[event.ID] {
+					if !send(event) {
+						return
+					}
+					flusher.Flush()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFrames reads Server-Sent Event frames until n events have arrived and
+// returns them with the number of heartbeats seen meanwhile.
+func readFrames(t *testing.T, reader *bufio.Reader, n int) (ids []string, events []models.StoredEvent, heartbeats int) {
+	t.Helper()
+	var id string
+	for len(events) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == ": heartbeat":
+			heartbeats++
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			var event models.StoredEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("Error decoding event: %v", err)
+			}
+			ids = append(ids, id)
+			events = append(events, event)
+		}
+	}
+	return ids, events, heartbeats
+}
+
+func TestGetEventStream(t *testing.T) {
+	defer func(heartbeat time.Duration) {
+		streamHeartbeatInterval = heartbeat
+	}(streamHeartbeatInterval)
+	streamHeartbeatInterval = 20 * time.Millisecond
+
+	eventStore := setupTestStore(t)
+	push := func(login string) {
+		eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: login}, CreatedAt: time.Now()})
+	}
+	push("before")
+	server := httptest.NewServer(GetEventStream(eventStore, setupTestBroadcaster(t, eventStore)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?actor=alice,carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	push("alice")
+	push("bob")
+	push("carol")
+	ids, events, _ := readFrames(t, reader, 2)
+	if events[0].Actor != "alice" || events[1].Actor != "carol" || ids[0] != "2" || ids[1] != "4" {
+		t.Errorf("Expected alice's and carol's events, got %v %+v", ids, events)
+	}
+
+	// Heartbeats keep the idle stream alive
+	time.Sleep(50 * time.Millisecond)
+	push("alice")
+	if _, _, heartbeats := readFrames(t, reader, 1); heartbeats == 0 {
+		t.Errorf("Expected heartbeats on an idle stream")
+	}
+
+	// Reconnecting resumes after the last event received
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Body.Close()
+	_, events, _ = readFrames(t, bufio.NewReader(resumed.Body), 3)
+	if events[0].Actor != "bob" || events[1].Actor != "carol" || events[2].Actor != "alice" {
+		t.Errorf("Expected the events after 2, got %+v", events)
+	}
+}
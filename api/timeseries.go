@@ -0,0 +1,148 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxBuckets caps the number of buckets of a time series.
+const maxBuckets = 1440
+
+// defaultSpans is the window of a time series without "since", per resolution.
+var defaultSpans = map[string]time.Duration{
+	models.ResolutionMinute: time.Hour,
+	models.ResolutionHour:   24 * time.Hour,
+	models.ResolutionDay:    30 * 24 * time.Hour,
+}
+
+// timeseriesQuery holds the query parameters of /event-counts/timeseries.
+type timeseriesQuery struct {
+	resolution string
+	filter     models.EventFilter
+}
+
+// timeseriesPoint is the event count of one bucket. The last bucket is
+// partial when the window ends before the bucket does. Compacted names the
+// coarser resolution the bucket's events are only kept at: they are all
+// counted in the bucket holding the start of the coarser one.
+type timeseriesPoint struct {
+	Bucket    time.Time `json:"bucket"`
+	Count     int64     `json:"count"`
+	Partial   bool      `json:"partial,omitempty"`
+	Compacted string    `json:"compacted,omitempty"`
+}
+
+// timeseriesResponse is the body of /event-counts/timeseries.
+type timeseriesResponse struct {
+	Resolution string                       `json:"resolution"`
+	Since      time.Time                    `json:"since"`
+	Until      time.Time                    `json:"until"`
+	Series     map[string][]timeseriesPoint `json:"series"`
+}
+
+// parseTimeseriesQuery reads the query parameters of /event-counts/timeseries:
+// "resolution" (minute, hour or day, default hour), the time window (see
+// parseTimeRange) and "type", "repo" and "actor" as comma-separated lists.
+// The window defaults to the last hour, day or 30 days by resolution, and its
+// start is aligned down to a bucket boundary in UTC.
+func parseTimeseriesQuery(r *http.Request, now time.Time) (timeseriesQuery, error) {
+	var q timeseriesQuery
+	query := r.URL.Query()
+
+	q.resolution = query.Get("resolution")
+	if q.resolution == "" {
+		q.resolution = models.ResolutionHour
+	}
+	width, ok := models.ResolutionWidths[q.resolution]
+	if !ok {
+		return q, fmt.Errorf("resolution must be minute, hour or day, got %q", q.resolution)
+	}
+
+	window, err := parseTimeRange(r, now)
+	if err != nil {
+		return q, err
+	}
+	if window.Until.IsZero() {
+		window.Until = now
+	}
+	if window.Since.IsZero() {
+		window.Since = window.Until.Add(-defaultSpans[q.resolution])
+	}
+	window.Since = window.Since.UTC().Truncate(width)
+	window.Until = window.Until.UTC()
+	if window.Until.Sub(window.Since) > maxBuckets*width {
+		return q, fmt.Errorf("window spans more than %d %s buckets", maxBuckets, q.resolution)
+	}
+
+	q.filter = models.EventFilter{
+		TimeRange: window,
+		Types:     splitList(query.Get("type")),
+		Repos:     splitList(query.Get("repo")),
+		Actors:    splitList(query.Get("actor")),
+	}
+	return q, nil
+}
+
+// GetEventTimeseries returns event counts per event type bucketed by
+// resolution over the window, with a zero count for every bucket without events.
+func GetEventTimeseries(eventStore store.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseTimeseriesQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Query the store to get the non-empty buckets
+		points, err := eventStore.EventTimeseries(q.resolution, q.filter)
+		if err != nil {
+			http.Error(w, "Error querying the database", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, timeseriesResponse{
+			Resolution: q.resolution,
+			Since:      q.filter.Since,
+			Until:      q.filter.Until,
+			Series:     fillSeries(points, q.filter.TimeRange, models.ResolutionWidths[q.resolution], q.filter.Types),
+		})
+	}
+}
+
+// fillSeries spreads the points into one series per event type with a point
+// for every bucket starting in the window, zero where there were no events,
+// marking a last bucket that ends after the window as partial. Each of types
+// gets a series even without events. Buckets covered by a point of rollups
+// coarser than width are marked as compacted in every series, since
+// compaction applies to all events alike.
+func fillSeries(points []models.CountPoint, window models.TimeRange, width time.Duration, types []string) map[string][]timeseriesPoint {
+	counts := make(map[string]map[time.Time]int64)
+	for _, eventType := range types {
+		counts[eventType] = make(map[time.Time]int64)
+	}
+	compacted := make(map[time.Time]string)
+	for _, point := range points {
+		if counts[point.EventType] == nil {
+			counts[point.EventType] = make(map[time.Time]int64)
+		}
+		counts[point.EventType][point.Bucket.UTC()] += point.Count
+		if coarse := models.ResolutionWidths[point.Resolution]; coarse > width {
+			for bucket := point.Bucket.UTC(); bucket.Before(point.Bucket.Add(coarse)); bucket = bucket.Add(width) {
+				compacted[bucket] = point.Resolution
+			}
+		}
+	}
+
+	series := make(map[string][]timeseriesPoint, len(counts))
+	for eventType, byBucket := range counts {
+		points := []timeseriesPoint{}
+		for bucket := window.Since; bucket.Before(window.Until); bucket = bucket.Add(width) {
+			points = append(points, timeseriesPoint{Bucket: bucket, Count: byBucket[bucket], Partial: bucket.Add(width).After(window.Until), Compacted: compacted[bucket]})
+		}
+		series[eventType] = points
+	}
+	return series
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetEventTimeseries(t *testing.T) {
+	eventStore := setupTestStore(t)
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: start.Add(5 * time.Minute)},
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo2"}, CreatedAt: start.Add(10 * time.Minute)},
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: start.Add(2*time.Hour + 30*time.Minute)},
+		{Type: "IssuesEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: start.Add(time.Hour)},
+	} {
+		eventStore.StoreEvent(event)
+	}
+
+	series := func(query string) timeseriesResponse {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/event-counts/timeseries?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetEventTimeseries(eventStore)(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Unexpected status %d for %s: %s", rr.Code, query, rr.Body.String())
+		}
+		var response timeseriesResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response
+	}
+	counts := func(points []timeseriesPoint) []int64 {
+		var counts []int64
+		for _, point := range points {
+			counts = append(counts, point.Count)
+		}
+		return counts
+	}
+
+	response := series("since=2024-05-01T10:30:00Z&until=2024-05-01T14:00:00Z")
+	if !response.Since.Equal(start) || response.Resolution != models.ResolutionHour {
+		t.Errorf("Expected hourly buckets from %v, got %s from %v", start, response.Resolution, response.Since)
+	}
+	expected := map[string][]int64{"PushEvent": {2, 0, 1, 0}, "IssuesEvent": {0, 1, 0, 0}}
+	for eventType, want := range expected {
+		if got := counts(response.Series[eventType]); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %s counts %v, got %v", eventType, want, got)
+		}
+	}
+	if len(response.Series) != len(expected) {
+		t.Errorf("Expected series %v, got %v", expected, response.Series)
+	}
+
+	for _, points := range response.Series {
+		for _, point := range points {
+			if point.Partial {
+				t.Errorf("Expected only whole buckets in an aligned window, got %+v", point)
+			}
+		}
+	}
+
+	// A window ending inside a bucket marks it as partial
+	response = series("since=2024-05-01T10:00:00Z&until=2024-05-01T12:40:00Z")
+	if points := response.Series["PushEvent"]; len(points) != 3 || points[1].Partial || !points[2].Partial || points[2].Count != 1 {
+		t.Errorf("Expected the last of 3 buckets to be partial, got %+v", points)
+	}
+
+	response = series("resolution=minute&since=2024-05-01T10:04:00Z&until=2024-05-01T10:08:00Z&actor=alice&type=PushEvent,ForkEvent")
+	if got := counts(response.Series["PushEvent"]); !reflect.DeepEqual(got, []int64{0, 1, 0, 0}) {
+		t.Errorf("Expected alice's pushes by minute, got %v", got)
+	}
+	if got := counts(response.Series["ForkEvent"]); !reflect.DeepEqual(got, []int64{0, 0, 0, 0}) {
+		t.Errorf("Expected a zero series for a requested type without events, got %v", got)
+	}
+
+	response = series("resolution=day&since=2024-05-01&until=2024-05-03&repo=repo2")
+	if got := counts(response.Series["PushEvent"]); !reflect.DeepEqual(got, []int64{1, 0}) || len(response.Series) != 1 {
+		t.Errorf("Expected repo2's pushes by day, got %v", response.Series)
+	}
+
+	// Minutes compacted into an hour are counted at its first minute, and
+	// every minute of the hour is flagged
+	if _, err := eventStore.CompactRollups(models.RollupTiers{Minute: time.Hour, Hour: 30 * 24 * time.Hour}, start.Add(4*time.Hour)); err != nil {
+		t.Fatalf("Error compacting rollups: %v", err)
+	}
+	response = series("resolution=minute&since=2024-05-01T10:00:00Z&until=2024-05-01T10:03:00Z")
+	points, compacted := response.Series["PushEvent"], true
+	for _, point := range points {
+		compacted = compacted && point.Compacted == models.ResolutionHour
+	}
+	if got := counts(points); !reflect.DeepEqual(got, []int64{2, 0, 0}) || !compacted {
+		t.Errorf("Expected the compacted hour at its first minute, flagged, got %+v", points)
+	}
+
+	for _, query := range []string{"resolution=week", "resolution=minute&since=2d", "since=soon"} {
+		req, _ := http.NewRequest("GET", "/event-counts/timeseries?"+query, nil)
+		rr := httptest.NewRecorder()
+		GetEventTimeseries(eventStore)(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
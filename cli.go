@@ -0,0 +1,245 @@
+package main
+
+import (
+	"awsomeProject/pkg/archive"
+	"awsomeProject/pkg/migrations"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"awsomeProject/pkg/retention"
+	"awsomeProject/pkg/store"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `usage:
+  main                       run the event processor and API server
+  main migrate up            apply all pending schema migrations
+  main migrate down [steps]  revert the last applied migrations (default 1)
+  main migrate status        print the current schema version
+  main retention [-dry-run]  apply the retention policy once and print the report
+  main restore <archive-dir> restore an archive written by retention into the github table
+  main export [-since time] [-until time] [-types t1,t2] [-repos r1,r2] <dir>
+                             export events to a new archive directory under dir
+  main import <archive-dir>  verify an exported archive and import it, skipping stored events
+  main rotate-email-keys     encrypt plaintext emails and re-encrypt those under old keys
+  main erase -login <login>  pseudonymize an actor everywhere and scrub it from future events
+  main erase -email <email>  remove a commit author email everywhere and from future events`
+
+// runCommand executes a CLI subcommand against the database.
+func runCommand(db *sql.DB, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(db, args[1:])
+	case "retention":
+		return runRetention(db, args[1:])
+	case "restore":
+		return runRestore(db, args[1:])
+	case "export":
+		return runExport(db, args[1:])
+	case "import":
+		return runImport(db, args[1:])
+	case "rotate-email-keys":
+		return runRotateEmailKeys(db)
+	case "erase":
+		return runErase(db, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+// newStore returns a Postgres event store using the email keyring from the
+// environment, and the keyring.
+func newStore(db *sql.DB) (*store.PostgresStore, *pii.Keyring, error) {
+	keys, err := pii.LoadKeyring()
+	if err != nil {
+		return nil, nil, err
+	}
+	return store.NewPostgresStore(db, keys), keys, nil
+}
+
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate subcommand\n%s", usage)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migrations.Up(db); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		if err := migrations.Down(db, steps); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q\n%s", args[0], usage)
+	}
+
+	version, err := migrations.Version(db)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %d\n", version)
+	return nil
+}
+
+func runErase(db *sql.DB, args []string) error {
+	var request models.ErasureRequest
+	flags := flag.NewFlagSet("erase", flag.ContinueOnError)
+	flags.StringVar(&request.Login, "login", "", "actor login to pseudonymize")
+	flags.StringVar(&request.Email, "email", "", "commit author email to remove")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if _, _, err := request.Subject(); err != nil {
+		return fmt.Errorf("%v\n%s", err, usage)
+	}
+
+	eventStore, _, err := newStore(db)
+	if err != nil {
+		return err
+	}
+	report, err := eventStore.Erase(request, time.Now())
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+func runRetention(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("retention", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be purged without deleting anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := retention.LoadConfig()
+	if err != nil {
+		return err
+	}
+	eventStore, keys, err := newStore(db)
+	if err != nil {
+		return err
+	}
+	report, err := retention.NewJob(eventStore, config, keys).Run(*dryRun)
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func runRestore(db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("restore takes exactly one archive directory\n%s", usage)
+	}
+
+	eventStore, keys, err := newStore(db)
+	if err != nil {
+		return err
+	}
+	restored, err := archive.Restore(args[0], eventStore, keys)
+	if err != nil {
+		return fmt.Errorf("restored %d events before failing: %w", restored, err)
+	}
+	fmt.Printf("Restored %d events from %s\n", restored, args[0])
+	return nil
+}
+
+func runExport(db *sql.DB, args []string) error {
+	var since, until, types, repos string
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.StringVar(&since, "since", "", "only export events created at or after this RFC 3339 time")
+	flags.StringVar(&until, "until", "", "only export events created before this RFC 3339 time")
+	flags.StringVar(&types, "types", "", "comma separated event types to export")
+	flags.StringVar(&repos, "repos", "", "comma separated repository URLs to export")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("export takes exactly one output directory\n%s", usage)
+	}
+
+	var filter models.EventFilter
+	for _, bound := range []struct {
+		value  string
+		target *time.Time
+	}{{since, &filter.Since}, {until, &filter.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return fmt.Errorf("invalid time %q, expected RFC 3339", bound.value)
+		}
+		*bound.target = t
+	}
+	if types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+	if repos != "" {
+		filter.Repos = strings.Split(repos, ",")
+	}
+
+	eventStore, keys, err := newStore(db)
+	if err != nil {
+		return err
+	}
+	runDir, manifest, err := archive.Export(flags.Arg(0), eventStore, filter, time.Now(), keys)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d events to %s\n", manifest.Events, runDir)
+	return nil
+}
+
+func runImport(db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("import takes exactly one archive directory\n%s", usage)
+	}
+
+	eventStore, keys, err := newStore(db)
+	if err != nil {
+		return err
+	}
+	report, err := archive.Import(args[0], eventStore, keys)
+	if err != nil {
+		return fmt.Errorf("imported %d events before failing: %w", report.Inserted, err)
+	}
+	fmt.Printf("Imported %d events from %s, %d already present\n", report.Inserted, args[0], report.Skipped)
+	return nil
+}
+
+func runRotateEmailKeys(db *sql.DB) error {
+	eventStore, _, err := newStore(db)
+	if err != nil {
+		return err
+	}
+	rewritten, err := eventStore.ReencryptEmails()
+	if err != nil {
+		return fmt.Errorf("re-encrypted %d emails before failing: %w", rewritten, err)
+	}
+	fmt.Printf("Re-encrypted %d emails\n", rewritten)
+	return nil
+}
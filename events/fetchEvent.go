@@ -3,57 +3,17 @@ package events
 import (
 	"awsomeProject/pkg/client"
 	"awsomeProject/pkg/models"
-	"database/sql"
+	"awsomeProject/pkg/store"
 	"encoding/json"
 	"fmt"
-	_ "github.com/lib/pq"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"sync"
+	"sort"
 	"time"
 )
 
-var events []models.GitHubEvent
-
-// Declare data structures and slices
-var eventTypeCount = make(map[string]int)
-var uniqueActors []string
-var uniqueRepoURLs []string
-var uniqueEmails []string
-
-var (
-	uniqueActorsMutex   sync.Mutex
-	uniqueRepoURLsMutex sync.Mutex
-	uniqueEmailsMutex   sync.Mutex
-)
-
-var fetchOnce sync.Once
-
-var db *sql.DB
-
-func init() {
-	var err error
-
-	// Read PostgreSQL connection details from environment variables
-	dbUser := os.Getenv("PGSQL_USER")
-	dbPassword := os.Getenv("PGSQL_PASSWORD")
-	dbHost := os.Getenv("PGSQL_HOSTNAME")
-	dbPort := os.Getenv("PGSQL_PORT")
-	dbName := os.Getenv("PGSQL_DATABASE")
-
-	// Create the PostgreSQL connection string
-	connStr := "user=" + dbUser + " password=" + dbPassword + " host=" + dbHost + " port=" + dbPort + " dbname=" + dbName + " sslmode=disable"
-
-	// Connect to the PostgreSQL database
-	db, err = sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatalf("Error connecting to the database: %v", err)
-	}
-	createGitHubEventsTable()
-}
-
 func InitiateShutdown() {
 	client := &http.Client{Timeout: time.Second * 10}
 	req, err := http.NewRequest("GET", "http://localhost:8080/shutdown", nil)
@@ -78,201 +38,129 @@ func InitiateShutdown() {
 	log.Println("Shutdown initiated.")
 }
 
-func createGitHubEventsTable() {
-	createTableSQL := `
-        CREATE TABLE IF NOT EXISTS github (
-            id serial PRIMARY KEY,
-            event_type varchar(255),
-            actor varchar(255),
-            repo_url varchar(255),
-            created_at timestamp NOT NULL
-        );
-    `
-
-	_, err := db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatalf("Error creating github table: %v", err)
+// storeGitHubEvent to store GitHub event data and any issue lifecycle change it
+// carries. The change is only applied if the event was not stored before, so
+// fetching an event again does not apply it twice.
+func storeGitHubEvent(eventStore store.EventStore, event models.GitHubEvent) error {
+	inserted, err := eventStore.StoreEvent(event)
+	if err != nil || !inserted {
+		return err
+	}
+	if update, ok := issueUpdateFromEvent(event); ok {
+		return eventStore.StoreIssueUpdate(update)
 	}
+	return nil
 }
 
-// storeGitHubEvent to store GitHub event data in the database
-func storeGitHubEvent(event models.GitHubEvent) error {
-	_, err := db.Exec("INSERT INTO github (event_type, actor, repo_url, created_at) VALUES ($1, $2, $3, $4)", event.Type, event.Actor.Login, event.Repo.URL, event.CreatedAt)
-	return err
+// getGitHubEvents to retrieve GitHub event data from the store
+func getGitHubEvents(eventStore store.EventStore) ([]models.GitHubEvent, error) {
+	return eventStore.Events()
 }
 
-// getGitHubEvents to retrieve GitHub event data from the database
-func getGitHubEvents() ([]models.GitHubEvent, error) {
-	rows, err := db.Query("SELECT event_type, actor, repo_url, created_at FROM github")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var events []models.GitHubEvent
-	for rows.Next() {
-		var event models.GitHubEvent
-		err := rows.Scan(&event.Type, &event.Actor.Login, &event.Repo.URL, &event.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		events = append(events, event)
-	}
+// eventsURL is the GitHub API endpoint listing public events, a variable so
+// tests can serve their own.
+var eventsURL = "https://api.github.com/events"
 
-	return events, nil
+// Config controls the ingestion job.
+type Config struct {
+	Token    string
+	Interval time.Duration
 }
 
-func CleanupOldData(db *sql.DB) {
-	// Calculate the date threshold based on your retention policy
-	retentionThreshold := time.Now().AddDate(0, 0, -2) // Retain data for 2 days
-
-	// Execute SQL query to delete old data
-	_, err := db.Exec("DELETE FROM github WHERE created_at < $1", retentionThreshold)
-	if err != nil {
-		log.Printf("Error cleaning up data: %v", err)
+// LoadConfig reads the GitHub token from GITHUB_ACCESS_TOKEN and the fetch
+// interval from INGEST_INTERVAL, a Go duration defaulting to one minute.
+func LoadConfig() (Config, error) {
+	config := Config{Token: os.Getenv("GITHUB_ACCESS_TOKEN"), Interval: time.Minute}
+	if value := os.Getenv("INGEST_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("INGEST_INTERVAL: invalid duration %q", value)
+		}
+		config.Interval = d
 	}
+	return config, nil
 }
 
-// FetchAndProcessEvents fetches and processes GitHub events.
-func FetchAndProcessEvents() {
-	// The code inside this function will run only once, regardless of how many times it's called.
-	fetchOnce.Do(func() {
-		// Your fetch and processing logic here
-		fmt.Println("Fetching and processing events...")
-		githubToken := os.Getenv("GITHUB_ACCESS_TOKEN")
-		if githubToken == "" {
-			fmt.Println("GitHub access token not found. Please set the GITHUB_ACCESS_TOKEN environment variable.")
-			return
-		}
-		client := client.CreateGitHubClient()
-
-		// Create an HTTP GET request
-		req, err := http.NewRequest("GET", "https://api.github.com/events", nil)
-		if err != nil {
-			log.Fatalf("Error creating HTTP request: %v", err)
-			return
-		}
-
-		// Set headers, including the Authorization header for authentication
-		req.Header.Set("Authorization", "token "+githubToken)
-
-		// Make the GET request
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatalf("Error making GET request: %v", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("Non-200 response: %d", resp.StatusCode) // Log the response status code
-			return
-		}
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading response body: %v", err) // Log the error
-			return
-		}
-		// Parse JSON response
-		if err := json.Unmarshal(body, &events); err != nil {
-			log.Printf("Error parsing JSON: %v", err) // Log the error
-			return
-		}
-		for _, event := range events {
-			// Increment event type count
-			eventType := event.Type
-			eventTypeCount[eventType]++
-
-			// Add actor to unique actors slice
-			actorLogin := event.Actor.Login
-			uniqueActors = addUniqueActor(actorLogin, uniqueActors)
-
-			// Add repository URL to unique repo URLs slice
-			repoURL := event.Repo.URL
-			uniqueRepoURLs = addUniqueRepoURL(repoURL, uniqueRepoURLs)
-
-			// Extract and add unique email addresses from commits
-			for _, commit := range event.Payload.Commits {
-				authorEmail := commit.Author.Email
-				uniqueEmails = addUniqueEmail(authorEmail, uniqueEmails)
-			}
-			err := storeGitHubEvent(event)
-			if err != nil {
-				log.Printf("Error storing GitHub event: %v", err)
-			}
-		}
-		CleanupOldData(db)
-	})
+// Job fetches GitHub events into a store.
+type Job struct {
+	store  store.EventStore
+	config Config
+	notify func()
 }
 
-// countEventTypes counts event types.
-func countEventTypes(events []models.GitHubEvent) map[string]int {
-	eventCounts := make(map[string]int)
-	for _, event := range events {
-		eventType := event.Type
-		eventCounts[eventType]++
-	}
-	return eventCounts
+// NewJob returns an ingestion job for the given store and config. notify, if
+// not nil, is called after each fetch that handed events to the store.
+func NewJob(eventStore store.EventStore, config Config, notify func()) *Job {
+	return &Job{store: eventStore, config: config, notify: notify}
 }
 
-// addUniqueActor adds unique actors to the slice (maintaining the last 50).
-func addUniqueActor(actor string, actors []string) []string {
-	uniqueActorsMutex.Lock()         // Lock the mutex before modifying the shared data
-	defer uniqueActorsMutex.Unlock() // Ensure the mutex is unlocked when the function exits
+// Run fetches the latest public events once and stores them oldest first. The
+// store skips the ones stored by earlier fetches. It returns the number of
+// events handed to the store.
+func (j *Job) Run() (int, error) {
+	client := client.CreateGitHubClient()
 
-	// Check if the actor is already in the slice
-	for _, a := range actors {
-		if a == actor {
-			return actors // Actor is already in the slice
-		}
+	// Create an HTTP GET request
+	req, err := http.NewRequest("GET", eventsURL, nil)
+	if err != nil {
+		return 0, err
 	}
 
-	// Add the actor to the slice
-	actors = append(actors, actor)
+	// Set headers, including the Authorization header for authentication
+	req.Header.Set("Authorization", "token "+j.config.Token)
 
-	// Limit the slice size to 50 (remove the oldest actor if necessary)
-	if len(actors) > 50 {
-		actors = actors[1:]
+	// Make the GET request
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
 	}
+	defer resp.Body.Close()
 
-	return actors
-}
-
-// addUniqueRepoURL adds unique repository URLs to the slice (maintaining the last 20).
-func addUniqueRepoURL(repoURL string, repoURLs []string) []string {
-	uniqueRepoURLsMutex.Lock()         // Lock the mutex before modifying the shared data
-	defer uniqueRepoURLsMutex.Unlock() // Ensure the mutex is unlocked when the function exits
-	// Check if the repoURL is already in the slice
-	for _, url := range repoURLs {
-		if url == repoURL {
-			return repoURLs // Repo URL is already in the slice
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("non-200 response: %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	// Parse JSON response
+	var events []models.GitHubEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return 0, err
+	}
+	// GitHub lists the newest events first; issue changes apply in order
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	handled := 0
+	for _, event := range events {
+		if err := storeGitHubEvent(j.store, event); err != nil {
+			log.Printf("Error storing GitHub event: %v", err)
+			continue
 		}
+		handled++
 	}
-
-	// Add the repoURL to the slice
-	repoURLs = append(repoURLs, repoURL)
-
-	// Limit the slice size to 20 (remove the oldest URL if necessary)
-	if len(repoURLs) > 20 {
-		repoURLs = repoURLs[1:]
+	if handled > 0 && j.notify != nil {
+		j.notify()
 	}
-
-	return repoURLs
+	return handled, nil
 }
 
-// addUniqueEmail adds unique email addresses to the slice.
-func addUniqueEmail(email string, emails []string) []string {
-	uniqueEmailsMutex.Lock()         // Lock the mutex before modifying the shared data
-	defer uniqueEmailsMutex.Unlock() // Ensure the mutex is unlocked when the function exits
-	// Check if the email is already in the slice
-	for _, e := range emails {
-		if e == email {
-			return emails // Email is already in the slice
+// Start runs the job now and then every configured interval in the
+// background. Without a GitHub token nothing is fetched.
+func (j *Job) Start() {
+	if j.config.Token == "" {
+		log.Println("GitHub access token not found. Please set the GITHUB_ACCESS_TOKEN environment variable.")
+		return
+	}
+	run := func() {
+		if _, err := j.Run(); err != nil {
+			log.Printf("Error fetching GitHub events: %v", err)
 		}
 	}
 
-	// Add the email to the slice
-	uniqueEmails = append(uniqueEmails, email)
-
-	return uniqueEmails
+	go func() {
+		run()
+		for range time.Tick(j.config.Interval) {
+			run()
+		}
+	}()
 }
@@ -2,34 +2,17 @@ package events
 
 import (
 	"awsomeProject/pkg/models"
-	"database/sql"
-	"os"
+	"awsomeProject/pkg/store"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
-var testDB *sql.DB
-
-func TestMain(m *testing.M) {
-	// Setup test database connection here
-	var err error
-	testDB, err = sql.Open("postgres", "user=username dbname=testdb sslmode=disable")
-	if err != nil {
-		panic("Error connecting to the test database: " + err.Error())
-	}
-	defer testDB.Close()
-
-	// Create necessary tables or perform migrations for the test database
-
-	// Run tests
-	exitCode := m.Run()
-
-	// Teardown code here, if necessary
-
-	// Exit with the appropriate exit code
-	os.Exit(exitCode)
-}
-
 func TestStoreGitHubEvent(t *testing.T) {
+	// Create an in-memory test store
+	testStore := store.NewMemoryStore()
+
 	// Create a test GitHub event
 	event := models.GitHubEvent{
 		Type: "PushEvent",
@@ -41,87 +24,166 @@ func TestStoreGitHubEvent(t *testing.T) {
 	}
 
 	// Call the storeGitHubEvent function
-	err := storeGitHubEvent(event)
+	err := storeGitHubEvent(testStore, event)
 	if err != nil {
 		t.Fatalf("Error storing GitHub event: %v", err)
 	}
 
-	// Add assertions to verify that the event was stored correctly in the test database
-}
-
-func TestGetGitHubEvents(t *testing.T) {
-	// Call the getGitHubEvents function to retrieve GitHub events from the test database
-	_, err := getGitHubEvents()
+	// Verify that the event was stored correctly in the test store
+	stored, err := testStore.Events()
 	if err != nil {
 		t.Fatalf("Error retrieving GitHub events: %v", err)
 	}
-
+	if len(stored) != 1 || stored[0].Actor.Login != "JohnDoe" {
+		t.Errorf("Expected the stored event to be returned, got %v", stored)
+	}
 }
 
-func TestAddUniqueActor(t *testing.T) {
-	actors := []string{"actor1", "actor2", "actor3"}
-	newActor := "actor4"
-
-	result := addUniqueActor(newActor, actors)
-
-	// Check that the new actor is added
-	if result[len(result)-1] != newActor {
-		t.Errorf("Expected actor to be added, got %v", result)
+func TestStoreGitHubEventTracksIssues(t *testing.T) {
+	testStore := store.NewMemoryStore()
+	opened := time.Now().Add(-time.Hour)
+	issue := &models.Issue{Number: 1, User: models.Actor{Login: "author"}, CreatedAt: opened}
+
+	// Store an opened issue followed by a close
+	for _, event := range []models.GitHubEvent{
+		{Type: "IssuesEvent", Repo: models.Repo{URL: "repo"}, Payload: models.Payload{Action: "opened", Issue: issue}, CreatedAt: opened},
+		{Type: "IssuesEvent", Repo: models.Repo{URL: "repo"}, Payload: models.Payload{Action: "closed", Issue: issue}, CreatedAt: opened.Add(30 * time.Minute)},
+	} {
+		if err := storeGitHubEvent(testStore, event); err != nil {
+			t.Fatalf("Error storing GitHub event: %v", err)
+		}
 	}
 
-	// Check that the length of the slice is limited to 50
-	if len(result) > 50 {
-		t.Errorf("Expected length to be limited to 50, got %v", len(result))
+	metrics, err := testStore.IssueMetrics("repo")
+	if err != nil {
+		t.Fatalf("Error retrieving issue metrics: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].ClosedIssues != 1 || metrics[0].MedianTimeToCloseSeconds == nil || *metrics[0].MedianTimeToCloseSeconds != 1800 {
+		t.Errorf("Expected one issue closed after 1800 seconds, got %+v", metrics)
 	}
 }
 
-func TestAddUniqueRepoURL(t *testing.T) {
-	repoURLs := []string{"url1", "url2", "url3"}
-	newRepoURL := "url4"
-
-	result := addUniqueRepoURL(newRepoURL, repoURLs)
+func TestStoreGitHubEventAppliesIssueChangesOnce(t *testing.T) {
+	testStore := store.NewMemoryStore()
+	opened := time.Now().Add(-time.Hour)
+	issue := &models.Issue{Number: 1, User: models.Actor{Login: "author"}, CreatedAt: opened}
+	events := []models.GitHubEvent{
+		{ID: "1", Type: "IssuesEvent", Repo: models.Repo{URL: "repo"}, Payload: models.Payload{Action: "opened", Issue: issue}, CreatedAt: opened},
+		{ID: "2", Type: "IssuesEvent", Repo: models.Repo{URL: "repo"}, Payload: models.Payload{Action: "closed", Issue: issue}, CreatedAt: opened.Add(time.Minute)},
+		{ID: "3", Type: "IssuesEvent", Repo: models.Repo{URL: "repo"}, Payload: models.Payload{Action: "reopened", Issue: issue}, CreatedAt: opened.Add(2 * time.Minute)},
+		{ID: "4", Type: "IssuesEvent", Repo: models.Repo{URL: "repo"}, Payload: models.Payload{Action: "closed", Issue: issue}, CreatedAt: opened.Add(3 * time.Minute)},
+	}
 
-	// Check that the new repo URL is added
-	if result[len(result)-1] != newRepoURL {
-		t.Errorf("Expected repo URL to be added, got %v", result)
+	for _, event := range events {
+		if err := storeGitHubEvent(testStore, event); err != nil {
+			t.Fatalf("Error storing GitHub event: %v", err)
+		}
+	}
+	// Storing the reopen again, as a later fetch still listing it does, changes nothing
+	if err := storeGitHubEvent(testStore, events[2]); err != nil {
+		t.Fatalf("Error storing GitHub event: %v", err)
 	}
 
-	// Check that the length of the slice is limited to 20
-	if len(result) > 20 {
-		t.Errorf("Expected length to be limited to 20, got %v", len(result))
+	metrics, err := testStore.IssueMetrics("repo")
+	if err != nil {
+		t.Fatalf("Error retrieving issue metrics: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].OpenIssues != 0 || metrics[0].ClosedIssues != 1 {
+		t.Errorf("Expected one closed issue, got %+v", metrics)
 	}
 }
 
-func TestAddUniqueEmail(t *testing.T) {
-	emails := []string{"email1@example.com", "email2@example.com", "email3@example.com"}
-	newEmail := "email4@example.com"
-
-	result := addUniqueEmail(newEmail, emails)
-
-	// Check that the new email is added
-	if result[len(result)-1] != newEmail {
-		t.Errorf("Expected email to be added, got %v", result)
+func TestGetGitHubEvents(t *testing.T) {
+	// Call the getGitHubEvents function to retrieve GitHub events from an empty test store
+	events, err := getGitHubEvents(store.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("Error retrieving GitHub events: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events, got %v", events)
 	}
 }
 
-func TestCountEventTypes(t *testing.T) {
-	events := []models.GitHubEvent{
-		{Type: "PushEvent"},
-		{Type: "PullRequestEvent"},
-		{Type: "PushEvent"},
-		{Type: "IssuesEvent"},
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("GITHUB_ACCESS_TOKEN", "token")
+	t.Setenv("INGEST_INTERVAL", "30s")
+	config, err := LoadConfig()
+	if err != nil || config.Token != "token" || config.Interval != 30*time.Second {
+		t.Errorf("Unexpected config %+v (%v)", config, err)
 	}
 
-	result := countEventTypes(events)
+	t.Setenv("INGEST_INTERVAL", "0s")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for a zero interval")
+	}
+}
 
-	// Check that event types are counted correctly
-	if result["PushEvent"] != 2 {
-		t.Errorf("Expected 2 PushEvents, got %v", result["PushEvent"])
+func TestJobRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[{"id": "1", "type": "PushEvent", "actor": {"login": "alice"}, "created_at": "2024-05-01T10:00:00Z"},
+			{"id": "2", "type": "WatchEvent", "actor": {"login": "bob"}, "created_at": "2024-05-01T10:01:00Z"}]`))
+	}))
+	defer server.Close()
+	defer func(url string) { eventsURL = url }(eventsURL)
+	eventsURL = server.URL
+
+	testStore := store.NewMemoryStore()
+	notified := 0
+	job := NewJob(testStore, Config{Token: "secret", Interval: time.Minute}, func() { notified++ })
+
+	// Fetching the same events again stores them once
+	for i := 0; i < 2; i++ {
+		handled, err := job.Run()
+		if err != nil || handled != 2 {
+			t.Fatalf("Expected 2 events to be handled, got %d (%v)", handled, err)
+		}
 	}
-	if result["PullRequestEvent"] != 1 {
-		t.Errorf("Expected 1 PullRequestEvent, got %v", result["PullRequestEvent"])
+	if id, _ := testStore.LastEventID(); id != 2 {
+		t.Errorf("Expected 2 stored events, got %d", id)
 	}
-	if result["IssuesEvent"] != 1 {
-		t.Errorf("Expected 1 IssuesEvent, got %v", result["IssuesEvent"])
+	if notified != 2 {
+		t.Errorf("Expected a notification per fetch, got %d", notified)
+	}
+
+	// Failed fetches are reported without notifying
+	job = NewJob(testStore, Config{Token: "wrong", Interval: time.Minute}, func() { notified++ })
+	if _, err := job.Run(); err == nil || notified != 2 {
+		t.Errorf("Expected an error without a notification, got %v after %d notifications", err, notified)
+	}
+}
+
+func TestJobRunAppliesIssueChangesOnce(t *testing.T) {
+	// GitHub lists the newest events first
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": "3", "type": "IssuesEvent", "repo": {"url": "repo"}, "created_at": "2024-05-01T10:05:00Z",
+				"payload": {"action": "closed", "issue": {"number": 1, "user": {"login": "author"}, "created_at": "2024-05-01T09:00:00Z"}}},
+			{"id": "2", "type": "IssuesEvent", "repo": {"url": "repo"}, "created_at": "2024-05-01T10:01:00Z",
+				"payload": {"action": "reopened", "issue": {"number": 1, "user": {"login": "author"}, "created_at": "2024-05-01T09:00:00Z"}}},
+			{"id": "1", "type": "IssuesEvent", "repo": {"url": "repo"}, "created_at": "2024-05-01T09:00:00Z",
+				"payload": {"action": "opened", "issue": {"number": 1, "user": {"login": "author"}, "created_at": "2024-05-01T09:00:00Z"}}}]`))
+	}))
+	defer server.Close()
+	defer func(url string) { eventsURL = url }(eventsURL)
+	eventsURL = server.URL
+
+	testStore := store.NewMemoryStore()
+	job := NewJob(testStore, Config{Token: "secret", Interval: time.Minute}, nil)
+
+	// Fetching the page again does not reapply the reopen
+	for i := 0; i < 2; i++ {
+		if _, err := job.Run(); err != nil {
+			t.Fatalf("Error running job: %v", err)
+		}
+		metrics, err := testStore.IssueMetrics("repo")
+		if err != nil {
+			t.Fatalf("Error retrieving issue metrics: %v", err)
+		}
+		if len(metrics) != 1 || metrics[0].OpenIssues != 0 || metrics[0].ClosedIssues != 1 {
+			t.Errorf("Expected the issue to be closed after fetch %d, got %+v", i+1, metrics)
+		}
 	}
 }
@@ -0,0 +1,52 @@
+package events
+
+import "awsomeProject/pkg/models"
+
+// issueUpdateFromEvent extracts the issue lifecycle change carried by an event.
+// It returns false for events that do not affect issue state.
+func issueUpdateFromEvent(event models.GitHubEvent) (models.IssueUpdate, bool) {
+	issue := event.Payload.Issue
+	if issue == nil {
+		return models.IssueUpdate{}, false
+	}
+
+	update := models.IssueUpdate{
+		RepoURL:    event.Repo.URL,
+		Number:     issue.Number,
+		Author:     issue.User.Login,
+		Actor:      event.Actor.Login,
+		OpenedAt:   issue.CreatedAt,
+		OccurredAt: event.CreatedAt,
+	}
+	if update.OpenedAt.IsZero() {
+		update.OpenedAt = event.CreatedAt
+	}
+
+	switch event.Type {
+	case "IssuesEvent":
+		switch event.Payload.Action {
+		case "opened", "closed", "reopened":
+			update.Action = event.Payload.Action
+		default:
+			return models.IssueUpdate{}, false
+		}
+	case "IssueCommentEvent":
+		comment := event.Payload.Comment
+		if event.Payload.Action != "created" || comment == nil {
+			return models.IssueUpdate{}, false
+		}
+		update.Action = "commented"
+		update.Actor = comment.User.Login
+		if !comment.CreatedAt.IsZero() {
+			update.OccurredAt = comment.CreatedAt
+		}
+		if comment.User.Login != "" && comment.User.Login != issue.User.Login {
+			respondedAt := update.OccurredAt
+			update.FirstResponse = &respondedAt
+		}
+	default:
+		return models.IssueUpdate{}, false
+	}
+
+	return update, true
+}
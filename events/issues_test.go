@@ -0,0 +1,53 @@
+package events
+
+import (
+	"awsomeProject/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestIssueUpdateFromEvent(t *testing.T) {
+	opened := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	commented := opened.Add(2 * time.Hour)
+	issue := &models.Issue{Number: 7, User: models.Actor{Login: "author"}, CreatedAt: opened}
+
+	// An opened issue records a transition
+	update, ok := issueUpdateFromEvent(models.GitHubEvent{
+		Type:      "IssuesEvent",
+		Actor:     models.Actor{Login: "author"},
+		Repo:      models.Repo{URL: "https://api.github.com/repos/example/repo"},
+		Payload:   models.Payload{Action: "opened", Issue: issue},
+		CreatedAt: opened,
+	})
+	if !ok || update.Action != "opened" || update.Number != 7 || !update.OpenedAt.Equal(opened) {
+		t.Errorf("Expected opened update for issue 7, got %+v (ok=%v)", update, ok)
+	}
+
+	// A comment by someone other than the author is a first response candidate
+	update, ok = issueUpdateFromEvent(models.GitHubEvent{
+		Type:    "IssueCommentEvent",
+		Actor:   models.Actor{Login: "maintainer"},
+		Payload: models.Payload{Action: "created", Issue: issue, Comment: &models.Comment{User: models.Actor{Login: "maintainer"}, CreatedAt: commented}},
+	})
+	if !ok || update.FirstResponse == nil || !update.FirstResponse.Equal(commented) {
+		t.Errorf("Expected first response at %v, got %+v (ok=%v)", commented, update, ok)
+	}
+
+	// A comment by the author is not a response
+	update, ok = issueUpdateFromEvent(models.GitHubEvent{
+		Type:    "IssueCommentEvent",
+		Actor:   models.Actor{Login: "author"},
+		Payload: models.Payload{Action: "created", Issue: issue, Comment: &models.Comment{User: models.Actor{Login: "author"}, CreatedAt: commented}},
+	})
+	if !ok || update.FirstResponse != nil {
+		t.Errorf("Expected no first response for author comment, got %+v (ok=%v)", update, ok)
+	}
+
+	// Other actions and event types are ignored
+	if _, ok := issueUpdateFromEvent(models.GitHubEvent{Type: "IssuesEvent", Payload: models.Payload{Action: "labeled", Issue: issue}}); ok {
+		t.Errorf("Expected labeled action to be ignored")
+	}
+	if _, ok := issueUpdateFromEvent(models.GitHubEvent{Type: "PushEvent"}); ok {
+		t.Errorf("Expected PushEvent to be ignored")
+	}
+}
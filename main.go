@@ -3,20 +3,87 @@ package main
 import (
 	"awsomeProject/api"
 	"awsomeProject/events"
-	"database/sql"
+	"awsomeProject/pkg/broadcast"
+	"awsomeProject/pkg/database"
+	"awsomeProject/pkg/migrations"
+	"awsomeProject/pkg/retention"
+	"awsomeProject/pkg/rollup"
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
 func main() {
-	// Initialize your database and other dependencies here
+	// Build the connection pool shared by ingestion, the API and background jobs
+	dbConfig, err := database.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading database config: %v", err)
+	}
+	db, err := database.Open(dbConfig)
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
 
-	// Call FetchAndProcessEvents once when the program starts
-	events.FetchAndProcessEvents()
+	// Run a CLI command instead of the server when one is given
+	if len(os.Args) > 1 {
+		if err := runCommand(db, os.Args[1:]); err != nil {
+			log.Fatalf("Error running %s: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// Bring the schema up to date before anything touches the database
+	if err := migrations.Up(db); err != nil {
+		log.Fatalf("Error migrating the database: %v", err)
+	}
+
+	// Share a single event store between the fetcher and the API
+	eventStore, keys, err := newStore(db)
+	if err != nil {
+		log.Fatalf("Error loading email encryption keys: %v", err)
+	}
+
+	// Encrypt any plaintext emails and move old ones to the current key before ingesting
+	if _, err := eventStore.ReencryptEmails(); err != nil {
+		log.Fatalf("Error encrypting emails: %v", err)
+	}
+
+	// Keep daily event partitions created a week ahead
+	eventStore.MaintainPartitions(7, 24*time.Hour)
+
+	// Deliver newly stored events to live clients, looking for events stored
+	// by other processes every few seconds
+	broadcaster, err := broadcast.New(eventStore, 5*time.Second)
+	if err != nil {
+		log.Fatalf("Error querying the database: %v", err)
+	}
+	broadcaster.Start()
+
+	// Fetch GitHub events on a schedule, publishing them to live clients
+	ingestConfig, err := events.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading ingestion config: %v", err)
+	}
+	events.NewJob(eventStore, ingestConfig, broadcaster.Notify).Start()
+
+	// Purge expired events on a schedule
+	retentionConfig, err := retention.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading retention config: %v", err)
+	}
+	retention.NewJob(eventStore, retentionConfig, keys).Start()
+
+	// Downsample aged rollups on a schedule
+	rollupConfig, err := rollup.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading rollup config: %v", err)
+	}
+	rollup.NewJob(eventStore, rollupConfig).Start()
 
 	// Create a new Gorilla Mux router instance
 	router := mux.NewRouter()
@@ -27,24 +94,9 @@ func main() {
 		Handler: router,
 	}
 
-	// Read PostgreSQL connection details from environment variables
-	dbUser := os.Getenv("PGSQL_USER")
-	dbPassword := os.Getenv("PGSQL_PASSWORD")
-	dbHost := os.Getenv("PGSQL_HOSTNAME")
-	dbPort := os.Getenv("PGSQL_PORT")
-	dbName := os.Getenv("PGSQL_DATABASE")
-
-	// Create the PostgreSQL connection string
-	connStr := "user=" + dbUser + " password=" + dbPassword + " host=" + dbHost + " port=" + dbPort + " dbname=" + dbName + " sslmode=disable"
-
-	// Connect to the PostgreSQL database
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatalf("Error connecting to the database: %v", err)
-	}
-
 	// Configure your API routes
-	api.SetupRoutes(router, db)
+	allowedOrigins := strings.Fields(strings.ReplaceAll(os.Getenv("WS_ALLOWED_ORIGINS"), ",", " "))
+	api.SetupRoutes(router, eventStore, broadcaster, allowedOrigins, os.Getenv("ADMIN_TOKEN"))
 
 	// Create a channel to signal the server to shut down
 	shutdownChan := make(chan struct{})
@@ -86,5 +138,6 @@ func main() {
 		log.Println("Received shutdown request. Shutting down...")
 	}
 
+	db.Close()
 	os.Exit(0)
 }
@@ -0,0 +1,339 @@
+// Package archive writes events to gzip-compressed NDJSON files, either before
+// they are purged or as an export, and reads them back so they can be
+// restored or imported into another environment.
+//
+// Each archive run is a directory named after its start time, to the
+// nanosecond, containing one
+// events-YYYY-MM-DD.ndjson.gz file per day of events and a manifest.json that
+// lists every file with its event count and SHA-256 checksum. Commit author
+// emails are written encrypted with the email keyring, so a copied archive
+// does not leak them, and decrypted when the archive is read.
+package archive
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"awsomeProject/pkg/store"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestVersion is the version of the archive layout written by Write.
+// Version 1 archives, which hold emails in plaintext, can still be read.
+const ManifestVersion = 2
+
+// runDirLayout is the time layout of the name of an archive run directory.
+const runDirLayout = "20060102T150405.000000000Z"
+
+// ManifestFile is the name of the manifest inside an archive directory.
+const ManifestFile = "manifest.json"
+
+// Manifest describes the files of an archive.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Events    int       `json:"events"`
+	Files     []File    `json:"files"`
+	// Filter is the selection of an export, if the archive is one.
+	Filter *models.EventFilter `json:"filter,omitempty"`
+	// EmailKey is the id of the key the commit author emails are encrypted
+	// with, which the keyring reading the archive must hold.
+	EmailKey string `json:"email_key,omitempty"`
+}
+
+// File is a single day of archived events.
+type File struct {
+	Name   string `json:"name"`
+	Day    string `json:"day"`
+	Events int    `json:"events"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Write archives events into a new directory under dir and returns its path.
+// Events are grouped into one file per UTC day, with their commit author
+// emails encrypted under the current key of keys.
+func Write(dir string, createdAt time.Time, events []models.GitHubEvent, keys *pii.Keyring) (string, Manifest, error) {
+	return write(dir, createdAt, events, nil, keys)
+}
+
+// write archives events, recording the export filter in the manifest if one is given.
+func write(dir string, createdAt time.Time, events []models.GitHubEvent, filter *models.EventFilter, keys *pii.Keyring) (string, Manifest, error) {
+	manifest := Manifest{Version: ManifestVersion, CreatedAt: createdAt.UTC(), Events: len(events), Filter: filter, EmailKey: keys.CurrentKey()}
+	runDir := filepath.Join(dir, createdAt.UTC().Format(runDirLayout))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", manifest, err
+	}
+	// Never write into the directory of another run
+	if err := os.Mkdir(runDir, 0o755); err != nil {
+		return "", manifest, err
+	}
+
+	byDay := make(map[string][]models.GitHubEvent)
+	var days []string
+	for _, event := range events {
+		event, err := mapEmails(event, keys.Encrypt)
+		if err != nil {
+			return runDir, manifest, err
+		}
+		day := event.CreatedAt.UTC().Format("2006-01-02")
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], event)
+	}
+
+	for _, day := range days {
+		file, err := writeDay(runDir, day, byDay[day])
+		if err != nil {
+			return runDir, manifest, err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return runDir, manifest, err
+	}
+	return runDir, manifest, os.WriteFile(filepath.Join(runDir, ManifestFile), data, 0o644)
+}
+
+// writeDay writes one day of events as gzip-compressed NDJSON.
+func writeDay(runDir, day string, events []models.GitHubEvent) (File, error) {
+	file := File{Name: "events-" + day + ".ndjson.gz", Day: day, Events: len(events)}
+	f, err := os.Create(filepath.Join(runDir, file.Name))
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, hash)}
+	gz := gzip.NewWriter(counter)
+	encoder := json.NewEncoder(gz)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return file, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return file, err
+	}
+	if err := f.Sync(); err != nil {
+		return file, err
+	}
+
+	file.Bytes = counter.n
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return file, nil
+}
+
+// ReadManifest loads and checks the manifest of an archive directory.
+func ReadManifest(runDir string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(runDir, ManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("parsing manifest: %w", err)
+	}
+	if manifest.Version < 1 || manifest.Version > ManifestVersion {
+		return manifest, fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+	events := 0
+	for _, file := range manifest.Files {
+		events += file.Events
+	}
+	if events != manifest.Events {
+		return manifest, fmt.Errorf("manifest lists %d events, but its files %d", manifest.Events, events)
+	}
+	return manifest, nil
+}
+
+// Verify checks the size and checksum of every file listed in the manifest.
+func Verify(runDir string, manifest Manifest) error {
+	for _, file := range manifest.Files {
+		f, err := os.Open(filepath.Join(runDir, file.Name))
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		n, err := io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if n != file.Bytes || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return fmt.Errorf("%s: checksum mismatch", file.Name)
+		}
+	}
+	return nil
+}
+
+// Read verifies an archive and calls fn for every archived event in order,
+// with its commit author emails decrypted with keys. Every file is decoded
+// and its event count checked before fn is first called, so fn sees no
+// events of an archive that does not match its manifest.
+func Read(runDir string, keys *pii.Keyring, fn func(models.GitHubEvent) error) (Manifest, error) {
+	manifest, err := ReadManifest(runDir)
+	if err != nil {
+		return manifest, err
+	}
+	if err := Verify(runDir, manifest); err != nil {
+		return manifest, err
+	}
+	if manifest.EmailKey != "" && !keys.HasKey(manifest.EmailKey) {
+		return manifest, fmt.Errorf("emails are encrypted with key %q, which the keyring does not hold", manifest.EmailKey)
+	}
+
+	read := fn
+	if manifest.EmailKey != "" {
+		read = func(event models.GitHubEvent) error {
+			event, err := mapEmails(event, keys.Decrypt)
+			if err != nil {
+				return err
+			}
+			return fn(event)
+		}
+	}
+	check := func(event models.GitHubEvent) error {
+		_, err := mapEmails(event, keys.Decrypt)
+		return err
+	}
+	if manifest.EmailKey == "" {
+		check = func(models.GitHubEvent) error { return nil }
+	}
+	for _, pass := range []func(models.GitHubEvent) error{check, read} {
+		for _, file := range manifest.Files {
+			if err := readFile(filepath.Join(runDir, file.Name), file.Events, pass); err != nil {
+				return manifest, fmt.Errorf("%s: %w", file.Name, err)
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// readFile decodes a gzip-compressed NDJSON file, checking its event count.
+func readFile(path string, expected int, fn func(models.GitHubEvent) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event models.GitHubEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("line %d: %w", count+1, err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if count != expected {
+		return fmt.Errorf("expected %d events, found %d", expected, count)
+	}
+	return nil
+}
+
+// Export writes the events of the store matching the filter to a new archive
+// directory under dir, encrypting emails like Write, and returns its path and manifest.
+func Export(dir string, eventStore store.EventStore, filter models.EventFilter, now time.Time, keys *pii.Keyring) (string, Manifest, error) {
+	events, err := eventStore.FindEvents(filter)
+	if err != nil {
+		return "", Manifest{}, err
+	}
+	runDir, manifest, err := write(dir, now, events, &filter, keys)
+	return runDir, manifest, err
+}
+
+// ImportReport says how many events of an archive were inserted and how many
+// were already stored.
+type ImportReport struct {
+	Events   int `json:"events"`
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// Import verifies an archive and stores its events, skipping those already
+// stored, so importing the same archive twice has no further effect. An
+// archive whose files do not match its manifest stores nothing. keys must
+// hold the key the archive's emails are encrypted with.
+func Import(runDir string, eventStore store.EventStore, keys *pii.Keyring) (ImportReport, error) {
+	var report ImportReport
+	_, err := Read(runDir, keys, func(event models.GitHubEvent) error {
+		event.Source = models.SourceImport
+		inserted, err := eventStore.ImportEvent(event)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			report.Inserted++
+		} else {
+			report.Skipped++
+		}
+		return nil
+	})
+	report.Events = report.Inserted + report.Skipped
+	return report, err
+}
+
+// Restore imports an archive and returns how many events were restored.
+func Restore(runDir string, eventStore store.EventStore, keys *pii.Keyring) (int, error) {
+	report, err := Import(runDir, eventStore, keys)
+	return report.Inserted, err
+}
+
+// mapEmails returns event with fn applied to its commit author emails.
+func mapEmails(event models.GitHubEvent, fn func(string) (string, error)) (models.GitHubEvent, error) {
+	if len(event.Payload.Commits) == 0 {
+		return event, nil
+	}
+	commits := make([]models.Commit, len(event.Payload.Commits))
+	for i, commit := range event.Payload.Commits {
+		if commit.Author.Email != "" {
+			email, err := fn(commit.Author.Email)
+			if err != nil {
+				return event, err
+			}
+			commit.Author.Email = email
+		}
+		commits[i] = commit
+	}
+	event.Payload.Commits = commits
+	return event, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,211 @@
+package archive
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"awsomeProject/pkg/store"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeys returns an email keyring whose current key has the given id.
+func testKeys(t *testing.T, id string) *pii.Keyring {
+	t.Helper()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, pii.KeySize))
+	keys, err := pii.ParseKeyring(id+":"+key, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestWriteAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	day1 := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	events := []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: day1,
+			Payload: models.Payload{Commits: []models.Commit{{Author: models.Author{Email: "alice@example.com"}}}}},
+		{Type: "WatchEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo"}, CreatedAt: day1.Add(time.Hour)},
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo"}, CreatedAt: day2},
+	}
+
+	keys := testKeys(t, "v1")
+	runDir, manifest, err := Write(dir, day2, events, keys)
+	if err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+
+	// One file per day, listed in the manifest
+	if manifest.Events != 3 || len(manifest.Files) != 2 || manifest.Files[0].Day != "2023-09-01" || manifest.Files[0].Events != 2 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+
+	// Restoring loads every event back into a store
+	eventStore := store.NewMemoryStore()
+	restored, err := Restore(runDir, eventStore, keys)
+	if err != nil || restored != 3 {
+		t.Fatalf("Expected 3 events to be restored, got %d (err=%v)", restored, err)
+	}
+	emails, _ := eventStore.UniqueEmails(models.TimeRange{})
+	if len(emails) != 1 || emails[0] != "alice@example.com" {
+		t.Errorf("Expected commit emails to be restored, got %v", emails)
+	}
+}
+
+func TestRestoreRejectsCorruptArchive(t *testing.T) {
+	now := time.Now()
+	keys := testKeys(t, "v1")
+	runDir, manifest, err := Write(t.TempDir(), now, []models.GitHubEvent{{Type: "PushEvent", CreatedAt: now}}, keys)
+	if err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+
+	// Tamper with the archived file
+	path := filepath.Join(runDir, manifest.Files[0].Name)
+	if err := os.WriteFile(path, []byte("not gzip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	eventStore := store.NewMemoryStore()
+	if _, err := Restore(runDir, eventStore, keys); err == nil {
+		t.Errorf("Expected a checksum error")
+	}
+	if events, _ := eventStore.Events(); len(events) != 0 {
+		t.Errorf("Expected nothing to be restored from a corrupt archive, got %v", events)
+	}
+}
+
+func TestWriteRefusesExistingRunDir(t *testing.T) {
+	dir, now := t.TempDir(), time.Now()
+	keys := testKeys(t, "v1")
+	if _, _, err := Write(dir, now, nil, keys); err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+	if _, _, err := Write(dir, now.Add(time.Nanosecond), nil, keys); err != nil {
+		t.Errorf("Expected runs a nanosecond apart to get their own directories, got %v", err)
+	}
+	if _, _, err := Write(dir, now, nil, keys); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Expected writing the same run twice to fail, got %v", err)
+	}
+}
+
+func TestImportChecksCountsBeforeStoring(t *testing.T) {
+	day := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	keys := testKeys(t, "v1")
+	runDir, manifest, err := Write(t.TempDir(), day, []models.GitHubEvent{
+		{ID: "1", Type: "PushEvent", CreatedAt: day},
+		{ID: "2", Type: "PushEvent", CreatedAt: day.AddDate(0, 0, 1)},
+	}, keys)
+	if err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+
+	for name, tamper := range map[string]func(*Manifest){
+		"total":     func(m *Manifest) { m.Events++ },
+		"last file": func(m *Manifest) { m.Events++; m.Files[1].Events++ },
+	} {
+		tampered := manifest
+		tampered.Files = append([]File(nil), manifest.Files...)
+		tamper(&tampered)
+		data, _ := json.Marshal(tampered)
+		if err := os.WriteFile(filepath.Join(runDir, ManifestFile), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		eventStore := store.NewMemoryStore()
+		if _, err := Import(runDir, eventStore, keys); err == nil {
+			t.Errorf("%s: expected a count mismatch", name)
+		}
+		if events, _ := eventStore.Events(); len(events) != 0 {
+			t.Errorf("%s: expected nothing to be imported, got %v", name, events)
+		}
+	}
+}
+
+func TestExportImportIsIdempotent(t *testing.T) {
+	day := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	source := store.NewMemoryStore()
+	for _, event := range []models.GitHubEvent{
+		{ID: "1", Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: day,
+			Payload: models.Payload{Commits: []models.Commit{{Author: models.Author{Email: "alice@example.com"}}}}},
+		{ID: "2", Type: "WatchEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: day},
+		{ID: "3", Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo2"}, CreatedAt: day},
+		{ID: "4", Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: day.AddDate(0, 0, 2)},
+	} {
+		source.StoreEvent(event)
+	}
+
+	filter := models.EventFilter{TimeRange: models.TimeRange{Until: day.AddDate(0, 0, 1)}, Types: []string{"PushEvent"}, Repos: []string{"repo1"}}
+	keys := testKeys(t, "v1")
+	runDir, manifest, err := Export(t.TempDir(), source, filter, day, keys)
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	if manifest.Events != 1 || manifest.Filter == nil {
+		t.Errorf("Expected one filtered event and the filter in the manifest, got %+v", manifest)
+	}
+
+	target := store.NewMemoryStore()
+	report, err := Import(runDir, target, keys)
+	if err != nil || report.Inserted != 1 || report.Skipped != 0 {
+		t.Fatalf("Unexpected first import: %+v (err=%v)", report, err)
+	}
+	report, err = Import(runDir, target, keys)
+	if err != nil || report.Inserted != 0 || report.Skipped != 1 {
+		t.Errorf("Expected a second import to skip every event, got %+v (err=%v)", report, err)
+	}
+
+	events, _ := target.Events()
+	emails, _ := target.UniqueEmails(models.TimeRange{})
+	if len(events) != 1 || events[0].ID != "1" || len(emails) != 1 {
+		t.Errorf("Unexpected imported events %+v and emails %v", events, emails)
+	}
+}
+
+func TestWriteEncryptsEmails(t *testing.T) {
+	day := time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC)
+	events := []models.GitHubEvent{{Type: "PushEvent", CreatedAt: day,
+		Payload: models.Payload{Commits: []models.Commit{{Author: models.Author{Email: "alice@example.com"}}}}}}
+	runDir, manifest, err := Write(t.TempDir(), day, events, testKeys(t, "v1"))
+	if err != nil {
+		t.Fatalf("Error writing archive: %v", err)
+	}
+	if manifest.EmailKey != "v1" {
+		t.Errorf("Expected the manifest to name the email key, got %+v", manifest)
+	}
+
+	// The archived file holds the email encrypted
+	f, err := os.Open(filepath.Join(runDir, manifest.Files[0].Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gz)
+	if strings.Contains(string(data), "alice@example.com") || !strings.Contains(string(data), `"email":"v1:`) {
+		t.Errorf("Expected the email to be archived encrypted, got %s", data)
+	}
+
+	// Reading the archive needs the key
+	eventStore := store.NewMemoryStore()
+	if _, err := Import(runDir, eventStore, testKeys(t, "v2")); err == nil || !strings.Contains(err.Error(), `key "v1"`) {
+		t.Errorf("Expected an error naming the missing key, got %v", err)
+	}
+	if events, _ := eventStore.Events(); len(events) != 0 {
+		t.Errorf("Expected nothing to be imported without the key, got %v", events)
+	}
+}
@@ -0,0 +1,202 @@
+// Package broadcast hands newly stored events to live subscribers, such as
+// the /events/stream and /events/ws clients, reading them from the store once
+// for all subscribers.
+package broadcast
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"log"
+	"sync"
+	"time"
+)
+
+// batchSize caps the number of events read from the store per query.
+const batchSize = 100
+
+// subscriptionBuffer is how many events may wait for a subscriber before its
+// subscription is dropped.
+const subscriptionBuffer = 1024
+
+// Lookback is how many IDs below the greatest delivered one are read again on
+// each delivery. Store IDs are assigned before events are committed, so an
+// event committed after events with greater IDs, as by an import running
+// alongside ingestion, is delivered if it is committed within Lookback IDs.
+const Lookback = 1000
+
+// Broadcaster reads the events it has not delivered yet when notified by
+// ingestion, and at least every interval to pick up events stored by other
+// processes, and sends them to its subscribers.
+type Broadcaster struct {
+	store    store.EventStore
+	interval time.Duration
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu sync.Mutex
+	// head is the greatest delivered ID. Events with IDs up to floor count
+	// as delivered; delivered holds the delivered IDs above it.
+	head        int64
+	floor       int64
+	delivered   map[int64]bool
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events delivered after it was made, in ID order
+// except for events committed late (see Lookback).
+type Subscription struct {
+	// Events is closed by Unsubscribe, by Stop, or when the subscriber falls
+	// more than a buffer of events behind.
+	Events <-chan models.StoredEvent
+	// After is the greatest ID delivered before the subscription. Events sent
+	// on Events have greater IDs unless they were committed late, in which
+	// case they were not delivered before the subscription.
+	After int64
+
+	events      chan models.StoredEvent
+	broadcaster *Broadcaster
+}
+
+// New returns a broadcaster for the events stored in eventStore after now.
+func New(eventStore store.EventStore, interval time.Duration) (*Broadcaster, error) {
+	head, err := eventStore.LastEventID()
+	if err != nil {
+		return nil, err
+	}
+	return &Broadcaster{
+		store:       eventStore,
+		interval:    interval,
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		head:        head,
+		floor:       head,
+		delivered:   make(map[int64]bool),
+		subscribers: make(map[*Subscription]struct{}),
+	}, nil
+}
+
+// Start delivers events in the background until Stop.
+func (b *Broadcaster) Start() {
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-b.wake:
+			case <-ticker.C:
+			}
+			if err := b.deliver(); err != nil {
+				log.Printf("Error broadcasting events: %v", err)
+			}
+		}
+	}()
+}
+
+// Stop ends delivery and closes all subscriptions.
+func (b *Broadcaster) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.done)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for subscription := range b.subscribers {
+			b.drop(subscription)
+		}
+	})
+}
+
+// Notify tells the broadcaster that events were stored. It does not block.
+func (b *Broadcaster) Notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe returns a subscription to the events stored from now on.
+func (b *Broadcaster) Subscribe() *Subscription {
+	events := make(chan models.StoredEvent, subscriptionBuffer)
+	subscription := &Subscription{Events: events, events: events, broadcaster: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subscription.After = b.head
+	select {
+	case <-b.done:
+		close(events)
+	default:
+		b.subscribers[subscription] = struct{}{}
+	}
+	return subscription
+}
+
+// Unsubscribe stops the subscription and closes its Events channel.
+func (s *Subscription) Unsubscribe() {
+	s.broadcaster.mu.Lock()
+	defer s.broadcaster.mu.Unlock()
+	if _, ok := s.broadcaster.subscribers[s]; ok {
+		s.broadcaster.drop(s)
+	}
+}
+
+// drop removes a subscription and closes its channel. The caller holds mu.
+func (b *Broadcaster) drop(subscription *Subscription) {
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
+
+// deliver sends the events not delivered yet to the subscribers, dropping
+// subscribers whose buffer is full.
+func (b *Broadcaster) deliver() error {
+	b.mu.Lock()
+	after := b.floor
+	b.mu.Unlock()
+
+	for {
+		events, err := b.store.EventsAfter(models.EventFilter{}, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		for _, event := range events {
+			if event.ID <= b.floor || b.delivered[event.ID] {
+				continue
+			}
+			for subscription := range b.subscribers {
+				select {
+				case subscription.events <- event:
+				default:
+					b.drop(subscription)
+				}
+			}
+			b.delivered[event.ID] = true
+			if event.ID > b.head {
+				b.head = event.ID
+			}
+		}
+		b.advanceFloor()
+		b.mu.Unlock()
+
+		if len(events) < batchSize {
+			return nil
+		}
+		after = events[len(events)-1].ID
+	}
+}
+
+// advanceFloor forgets the delivered IDs more than Lookback below the head.
+// The caller holds mu.
+func (b *Broadcaster) advanceFloor() {
+	if b.head-Lookback <= b.floor {
+		return
+	}
+	b.floor = b.head - Lookback
+	for id := range b.delivered {
+		if id <= b.floor {
+			delete(b.delivered, id)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package broadcast
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"testing"
+	"time"
+)
+
+// receive returns the next event of a subscription, failing after a second.
+func receive(t *testing.T, subscription *Subscription) (models.StoredEvent, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-subscription.Events:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for an event")
+		return models.StoredEvent{}, false
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	eventStore := store.NewMemoryStore()
+	push := func(login string) {
+		eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: login}, CreatedAt: time.Now()})
+	}
+	push("before")
+
+	broadcaster, err := New(eventStore, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating broadcaster: %v", err)
+	}
+	broadcaster.Start()
+	defer broadcaster.Stop()
+
+	first := broadcaster.Subscribe()
+	second := broadcaster.Subscribe()
+	if first.After != 1 {
+		t.Errorf("Expected subscriptions after event 1, got %d", first.After)
+	}
+
+	// Every subscriber receives the events stored before a notification
+	push("alice")
+	push("bob")
+	broadcaster.Notify()
+	for _, subscription := range []*Subscription{first, second} {
+		for _, login := range []string{"alice", "bob"} {
+			if event, ok := receive(t, subscription); !ok || event.Actor != login {
+				t.Errorf("Expected %s's event, got %+v (%v)", login, event, ok)
+			}
+		}
+	}
+
+	// Unsubscribing closes the channel without affecting other subscribers
+	first.Unsubscribe()
+	if _, ok := receive(t, first); ok {
+		t.Errorf("Expected the channel of an unsubscribed subscription to be closed")
+	}
+	push("carol")
+	broadcaster.Notify()
+	if event, ok := receive(t, second); !ok || event.Actor != "carol" {
+		t.Errorf("Expected carol's event, got %+v (%v)", event, ok)
+	}
+
+	// Later subscriptions start after the events already delivered
+	if third := broadcaster.Subscribe(); third.After != 4 {
+		t.Errorf("Expected a new subscription after event 4, got %d", third.After)
+	}
+
+	// Stopping closes the remaining subscriptions
+	broadcaster.Stop()
+	if _, ok := receive(t, second); ok {
+		t.Errorf("Expected the channel to be closed by Stop")
+	}
+}
+
+func TestBroadcasterDropsSlowSubscribers(t *testing.T) {
+	eventStore := store.NewMemoryStore()
+	broadcaster, err := New(eventStore, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating broadcaster: %v", err)
+	}
+	subscription := broadcaster.Subscribe()
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", CreatedAt: time.Now()})
+	}
+	if err := broadcaster.deliver(); err != nil {
+		t.Fatalf("Error delivering events: %v", err)
+	}
+
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Errorf("Expected a full buffer of %d events before the drop, got %d", subscriptionBuffer, received)
+	}
+}
+
+// lateStore hides the events with the IDs in pending, as if their
+// transactions had not committed yet.
+type lateStore struct {
+	store.EventStore
+	pending map[int64]bool
+}
+
+func (s *lateStore) EventsAfter(filter models.EventFilter, afterID int64, limit int) ([]models.StoredEvent, error) {
+	events, err := s.EventStore.EventsAfter(filter, afterID, limit)
+	committed := events[:0]
+	for _, event := range events {
+		if !s.pending[event.ID] {
+			committed = append(committed, event)
+		}
+	}
+	return committed, err
+}
+
+func TestBroadcasterDeliversLateCommits(t *testing.T) {
+	eventStore := &lateStore{EventStore: store.NewMemoryStore(), pending: map[int64]bool{1: true}}
+	broadcaster, err := New(eventStore, time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating broadcaster: %v", err)
+	}
+	subscription := broadcaster.Subscribe()
+
+	// Event 1 commits after event 2 was delivered
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: "late"}, CreatedAt: time.Now()})
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: "early"}, CreatedAt: time.Now()})
+	if err := broadcaster.deliver(); err != nil {
+		t.Fatalf("Error delivering events: %v", err)
+	}
+	delete(eventStore.pending, 1)
+	if err := broadcaster.deliver(); err != nil {
+		t.Fatalf("Error delivering events: %v", err)
+	}
+	// Delivering again sends nothing new
+	if err := broadcaster.deliver(); err != nil {
+		t.Fatalf("Error delivering events: %v", err)
+	}
+	broadcaster.Stop()
+
+	var logins []string
+	for event := range subscription.Events {
+		logins = append(logins, event.Actor)
+	}
+	if len(logins) != 2 || logins[0] != "early" || logins[1] != "late" {
+		t.Errorf("Expected early and late events once, got %v", logins)
+	}
+}
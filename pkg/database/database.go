@@ -0,0 +1,143 @@
+// Package database builds the single PostgreSQL connection pool shared by
+// ingestion, the API and the background jobs.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Config holds the connection details and pool settings of the database.
+type Config struct {
+	User     string
+	Password string
+	Host     string
+	Port     string
+	Name     string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectTimeout bounds how long Open keeps retrying until Postgres is reachable.
+	ConnectTimeout time.Duration
+}
+
+// LoadConfig reads the PGSQL_* environment variables. Pool settings default to
+// 20 open and 10 idle connections recycled after 30 minutes, or 5 minutes idle,
+// and Open waits up to a minute for the database to come up.
+func LoadConfig() (Config, error) {
+	config := Config{
+		User:            os.Getenv("PGSQL_USER"),
+		Password:        os.Getenv("PGSQL_PASSWORD"),
+		Host:            os.Getenv("PGSQL_HOSTNAME"),
+		Port:            os.Getenv("PGSQL_PORT"),
+		Name:            os.Getenv("PGSQL_DATABASE"),
+		MaxOpenConns:    20,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+		ConnectTimeout:  time.Minute,
+	}
+
+	for name, target := range map[string]*int{
+		"PGSQL_MAX_OPEN_CONNS": &config.MaxOpenConns,
+		"PGSQL_MAX_IDLE_CONNS": &config.MaxIdleConns,
+	} {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Config{}, fmt.Errorf("%s must be a positive integer, got %q", name, value)
+			}
+			*target = n
+		}
+	}
+	for name, target := range map[string]*time.Duration{
+		"PGSQL_CONN_MAX_LIFETIME":  &config.ConnMaxLifetime,
+		"PGSQL_CONN_MAX_IDLE_TIME": &config.ConnMaxIdleTime,
+		"PGSQL_CONNECT_TIMEOUT":    &config.ConnectTimeout,
+	} {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("%s must be a positive duration, got %q", name, value)
+			}
+			*target = d
+		}
+	}
+
+	if config.MaxIdleConns > config.MaxOpenConns {
+		config.MaxIdleConns = config.MaxOpenConns
+	}
+	return config, nil
+}
+
+// ConnString returns the lib/pq connection string for the config.
+func (c Config) ConnString() string {
+	var parts []string
+	for _, kv := range [][2]string{
+		{"user", c.User}, {"password", c.Password}, {"host", c.Host}, {"port", c.Port}, {"dbname", c.Name},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+quote(kv[1]))
+		}
+	}
+	return strings.Join(append(parts, "sslmode=disable"), " ")
+}
+
+// quote quotes a connection string value so spaces and quotes survive.
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Open creates the connection pool and waits until the database answers a
+// ping, retrying with exponential backoff for up to ConnectTimeout.
+func Open(config Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", config.ConnString())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
+	if err := waitFor(ctx, db.PingContext, 500*time.Millisecond, 10*time.Second); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database not reachable: %w", err)
+	}
+	return db, nil
+}
+
+// waitFor calls ping until it succeeds, doubling the delay between attempts
+// from initial up to max, and gives up with the last error when ctx is done.
+func waitFor(ctx context.Context, ping func(context.Context) error, initial, max time.Duration) error {
+	delay := initial
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Database not ready (attempt %d): %v; retrying in %s", attempt, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+}
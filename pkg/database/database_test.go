@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("PGSQL_USER", "app")
+	t.Setenv("PGSQL_PASSWORD", "it's secret")
+	t.Setenv("PGSQL_HOSTNAME", "db")
+	t.Setenv("PGSQL_MAX_OPEN_CONNS", "4")
+	t.Setenv("PGSQL_CONN_MAX_LIFETIME", "1m")
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if config.MaxOpenConns != 4 || config.MaxIdleConns != 4 || config.ConnMaxLifetime != time.Minute {
+		t.Errorf("Unexpected pool settings: %+v", config)
+	}
+	expected := `user='app' password='it\'s secret' host='db' sslmode=disable`
+	if got := config.ConnString(); got != expected {
+		t.Errorf("Expected connection string %q, got %q", expected, got)
+	}
+
+	t.Setenv("PGSQL_MAX_OPEN_CONNS", "none")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for an invalid pool size")
+	}
+}
+
+func TestWaitForRetries(t *testing.T) {
+	attempts := 0
+	ping := func(context.Context) error {
+		if attempts++; attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	if err := waitFor(context.Background(), ping, time.Millisecond, 2*time.Millisecond); err != nil || attempts != 3 {
+		t.Errorf("Expected success on the third attempt, got %d attempts (err=%v)", attempts, err)
+	}
+
+	// Gives up with the last error once the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	err := waitFor(ctx, func(context.Context) error { return errors.New("down") }, time.Millisecond, time.Millisecond)
+	if err == nil || err.Error() != "down" {
+		t.Errorf("Expected the last ping error, got %v", err)
+	}
+}
@@ -0,0 +1,751 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// maxPlannedFields bounds the fields of a query after fragments are expanded,
+// so fragments spreading each other cannot blow up planning.
+const maxPlannedFields = 10000
+
+// enumLiteral is an enum value written in a query, told apart from strings so
+// it is not accepted for scalars.
+type enumLiteral string
+
+// inputTypes returns the scalar, enum and input object types of a schema's
+// arguments by name, for variable definitions.
+func inputTypes(s *Schema) map[string]Type {
+	types := map[string]Type{"String": String, "Int": Int, "Float": Float, "Boolean": Boolean, "ID": ID}
+	seen := make(map[*Object]bool)
+	var visitInput func(t Type)
+	visitInput = func(t Type) {
+		t = namedType(t)
+		if _, ok := types[t.String()]; ok {
+			return
+		}
+		types[t.String()] = t
+		if input, ok := t.(*InputObject); ok {
+			for _, f := range input.Fields {
+				visitInput(f.Type)
+			}
+		}
+	}
+	var visit func(t *Object)
+	visit = func(t *Object) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		for _, f := range t.Fields {
+			for _, a := range f.Args {
+				visitInput(a.Type)
+			}
+			if o, ok := namedType(f.Type).(*Object); ok {
+				visit(o)
+			}
+		}
+	}
+	visit(s.Query)
+	return types
+}
+
+// namedType strips list and non-null wrappers from a type.
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.OfType
+		case *NonNull:
+			t = w.OfType
+		default:
+			return t
+		}
+	}
+}
+
+// resolveTypeRef returns the schema type of a type written in a query.
+func resolveTypeRef(types map[string]Type, ref *typeRef) (Type, error) {
+	var t Type
+	if ref.ofType != nil {
+		of, err := resolveTypeRef(types, ref.ofType)
+		if err != nil {
+			return nil, err
+		}
+		t = ListOf(of)
+	} else {
+		named, ok := types[ref.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown input type %s", ref.name)
+		}
+		t = named
+	}
+	if ref.nonNull {
+		t = NonNullOf(t)
+	}
+	return t, nil
+}
+
+// coerceVariables checks the variable values of a request against the
+// operation's definitions and applies their defaults.
+func coerceVariables(s *Schema, op *operation, values map[string]interface{}) (map[string]interface{}, error) {
+	types := inputTypes(s)
+	coerced := make(map[string]interface{})
+	for _, def := range op.variables {
+		t, err := resolveTypeRef(types, def.typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.name, err)
+		}
+		raw, ok := values[def.name]
+		if !ok && def.defaultValue != nil {
+			raw, ok = literal(def.defaultValue, nil), true
+		}
+		if !ok {
+			if _, required := t.(*NonNull); required {
+				return nil, fmt.Errorf("variable $%s of type %s is required", def.name, t)
+			}
+			continue
+		}
+		v, err := coerceInput(t, raw)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.name, err)
+		}
+		coerced[def.name] = v
+	}
+	return coerced, nil
+}
+
+// literal converts a value of a query to an input value, reading variables
+// from variables. Variables that are not set are null.
+func literal(v *value, variables map[string]interface{}) interface{} {
+	switch v.kind {
+	case variableValue:
+		return variables[v.text]
+	case intValue, floatValue:
+		return json.Number(v.text)
+	case stringValue:
+		return v.text
+	case booleanValue:
+		return v.text == "true"
+	case enumValue:
+		return enumLiteral(v.text)
+	case listValue:
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			list[i] = literal(item, variables)
+		}
+		return list
+	case objectValue:
+		fields := make(map[string]interface{}, len(v.fields))
+		for _, f := range v.fields {
+			fields[f.name] = literal(f.value, variables)
+		}
+		return fields
+	}
+	return nil
+}
+
+// coerceInput converts an input value to the value passed to resolvers for a type.
+func coerceInput(t Type, v interface{}) (interface{}, error) {
+	if nonNull, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected a value of type %s, found null", t)
+		}
+		return coerceInput(nonNull.OfType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := v.([]interface{})
+		if !ok {
+			// A single value is a list of one
+			item, err := coerceInput(t.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerceInput(t.OfType, item)
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %v", i, err)
+			}
+			list[i] = c
+		}
+		return list, nil
+	case *Scalar:
+		return t.Parse(v)
+	case *Enum:
+		var s string
+		switch v := v.(type) {
+		case enumLiteral:
+			s = string(v)
+		case string:
+			s = v
+		}
+		for _, value := range t.Values {
+			if s != "" && value == s {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a value of %s", describe(v), t.Name)
+	case *InputObject:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s expects an object, found %s", t.Name, describe(v))
+		}
+		for name := range fields {
+			if inputField(t, name) == nil {
+				return nil, fmt.Errorf("%s has no field %q", t.Name, name)
+			}
+		}
+		coerced := make(map[string]interface{}, len(t.Fields))
+		for _, f := range t.Fields {
+			raw, ok := fields[f.Name]
+			if !ok {
+				if f.Default != nil {
+					coerced[f.Name] = f.Default
+				} else if _, required := f.Type.(*NonNull); required {
+					return nil, fmt.Errorf("%s.%s is required", t.Name, f.Name)
+				}
+				continue
+			}
+			c, err := coerceInput(f.Type, raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", t.Name, f.Name, err)
+			}
+			coerced[f.Name] = c
+		}
+		return coerced, nil
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// inputField returns the field of an input object with the given name, or nil.
+func inputField(t *InputObject, name string) *Argument {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// plannedField is a field to resolve, with its coerced arguments and the
+// fields selected below it, after fragments and directives are applied and
+// fields with the same response key are merged.
+type plannedField struct {
+	key    string
+	field  *Field // nil for __typename
+	args   map[string]interface{}
+	fields []*plannedField
+}
+
+// planner validates the selections of an operation against the schema.
+type planner struct {
+	schema    *Schema
+	doc       *document
+	variables map[string]interface{}
+	declared  map[string]bool
+	visiting  map[string]bool
+	count     int
+}
+
+// plan returns the fields selected on an object type.
+func (p *planner) plan(t *Object, selections []*selection) ([]*plannedField, error) {
+	var fields []*plannedField
+	if err := p.collect(t, selections, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// collect adds the fields of selections on an object type to fields.
+func (p *planner) collect(t *Object, selections []*selection, fields *[]*plannedField) error {
+	for _, sel := range selections {
+		include, err := p.included(sel.directives)
+		if err != nil {
+			return err
+		}
+		if !include {
+			continue
+		}
+
+		switch sel.kind {
+		case fragmentSpread:
+			frag, ok := p.doc.fragments[sel.fragment]
+			if !ok {
+				return fmt.Errorf("unknown fragment %q", sel.fragment)
+			}
+			if p.visiting[frag.name] {
+				return fmt.Errorf("fragment %q spreads itself", frag.name)
+			}
+			if frag.typeCondition != t.Name {
+				return fmt.Errorf("fragment %q on %s cannot be spread on %s", frag.name, frag.typeCondition, t.Name)
+			}
+			p.visiting[frag.name] = true
+			err := p.collect(t, frag.selections, fields)
+			delete(p.visiting, frag.name)
+			if err != nil {
+				return err
+			}
+		case inlineFragment:
+			if sel.typeCondition != "" && sel.typeCondition != t.Name {
+				return fmt.Errorf("a fragment on %s cannot be spread on %s", sel.typeCondition, t.Name)
+			}
+			if err := p.collect(t, sel.selections, fields); err != nil {
+				return err
+			}
+		default:
+			field, err := p.planField(t, sel)
+			if err != nil {
+				return err
+			}
+			if *fields, err = merge(*fields, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// planField validates a field selection and plans the fields below it.
+func (p *planner) planField(t *Object, sel *selection) (*plannedField, error) {
+	if p.count++; p.count > maxPlannedFields {
+		return nil, fmt.Errorf("the query selects more than %d fields", maxPlannedFields)
+	}
+	planned := &plannedField{key: sel.responseKey()}
+	if sel.name == "__typename" {
+		if sel.selections != nil {
+			return nil, fmt.Errorf("__typename cannot have a selection set")
+		}
+		return planned, nil
+	}
+
+	f := t.field(sel.name)
+	if t == p.schema.Query && sel.name == schemaField.Name {
+		f = schemaField
+	} else if t == p.schema.Query && sel.name == typeField.Name {
+		f = typeField
+	}
+	if f == nil {
+		return nil, fmt.Errorf("cannot query field %q on type %s", sel.name, t.Name)
+	}
+	planned.field = f
+	args, err := p.arguments(f.Args, sel.arguments, fmt.Sprintf("%s.%s", t.Name, f.Name))
+	if err != nil {
+		return nil, err
+	}
+	planned.args = args
+
+	object, isObject := namedType(f.Type).(*Object)
+	switch {
+	case isObject && sel.selections == nil:
+		return nil, fmt.Errorf("field %s.%s of type %s needs a selection of subfields", t.Name, f.Name, f.Type)
+	case !isObject && sel.selections != nil:
+		return nil, fmt.Errorf("field %s.%s of type %s cannot have a selection of subfields", t.Name, f.Name, f.Type)
+	case isObject:
+		if planned.fields, err = p.plan(object, sel.selections); err != nil {
+			return nil, err
+		}
+	}
+	return planned, nil
+}
+
+// arguments coerces the arguments given for a field or directive.
+func (p *planner) arguments(defs []*Argument, given []*argument, of string) (map[string]interface{}, error) {
+	values := make(map[string]*value, len(given))
+	for _, a := range given {
+		if _, ok := values[a.name]; ok {
+			return nil, fmt.Errorf("argument %q of %s is given more than once", a.name, of)
+		}
+		found := false
+		for _, def := range defs {
+			found = found || def.Name == a.name
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown argument %q of %s", a.name, of)
+		}
+		if err := p.checkVariables(a.value); err != nil {
+			return nil, err
+		}
+		values[a.name] = a.value
+	}
+
+	args := make(map[string]interface{}, len(defs))
+	for _, def := range defs {
+		v, ok := values[def.Name]
+		if ok && v.kind == variableValue {
+			// A variable without a value leaves the argument out
+			_, ok = p.variables[v.text]
+		}
+		if !ok {
+			if def.Default != nil {
+				args[def.Name] = def.Default
+			} else if _, required := def.Type.(*NonNull); required {
+				return nil, fmt.Errorf("argument %q of %s of type %s is required", def.Name, of, def.Type)
+			}
+			continue
+		}
+		c, err := coerceInput(def.Type, literal(v, p.variables))
+		if err != nil {
+			return nil, fmt.Errorf("argument %q of %s: %v", def.Name, of, err)
+		}
+		args[def.Name] = c
+	}
+	return args, nil
+}
+
+// checkVariables checks that the variables used in a value are defined by the operation.
+func (p *planner) checkVariables(v *value) error {
+	if v.kind == variableValue && !p.declared[v.text] {
+		return fmt.Errorf("variable $%s is not defined", v.text)
+	}
+	for _, item := range v.list {
+		if err := p.checkVariables(item); err != nil {
+			return err
+		}
+	}
+	for _, f := range v.fields {
+		if err := p.checkVariables(f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// directiveArgs are the arguments of @skip and @include.
+var directiveArgs = []*Argument{{Name: "if", Type: NonNullOf(Boolean)}}
+
+// included applies the @skip and @include directives of a selection.
+func (p *planner) included(directives []*directive) (bool, error) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		args, err := p.arguments(directiveArgs, d.arguments, "@"+d.name)
+		if err != nil {
+			return false, err
+		}
+		if args["if"].(bool) == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// merge adds a field to fields, merging it with a field of the same response key.
+func merge(fields []*plannedField, field *plannedField) ([]*plannedField, error) {
+	for _, existing := range fields {
+		if existing.key != field.key {
+			continue
+		}
+		if existing.field != field.field || !reflect.DeepEqual(existing.args, field.args) {
+			return nil, fmt.Errorf("fields selected as %q differ; use aliases to select both", field.key)
+		}
+		for _, child := range field.fields {
+			var err error
+			if existing.fields, err = merge(existing.fields, child); err != nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	}
+	return append(fields, field), nil
+}
+
+// checkLimits returns an error if fields are nested deeper than maxDepth or
+// their complexity exceeds maxComplexity. Zero means no limit.
+func checkLimits(fields []*plannedField, maxDepth, maxComplexity int) error {
+	if d := depth(fields); maxDepth > 0 && d > maxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", d, maxDepth)
+	}
+	if c := complexity(fields); maxComplexity > 0 && c > maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", c, maxComplexity)
+	}
+	return nil
+}
+
+// depth returns the nesting depth of fields.
+func depth(fields []*plannedField) int {
+	d := 0
+	for _, f := range fields {
+		if n := 1 + depth(f.fields); n > d {
+			d = n
+		}
+	}
+	return d
+}
+
+// complexity estimates the cost of resolving fields, see Schema.MaxComplexity.
+func complexity(fields []*plannedField) int {
+	total := 0
+	for _, f := range fields {
+		if f.field == nil {
+			continue
+		}
+		multiplier := 1
+		if first, ok := f.args["first"].(int); ok {
+			multiplier = first
+		} else if f.field.ListSize > 0 {
+			multiplier = f.field.ListSize
+		}
+		total += 1 + multiplier*complexity(f.fields)
+	}
+	return total
+}
+
+// executor resolves planned fields breadth first: each field is resolved once
+// for all the values of its parent type at the same place in the response.
+type executor struct {
+	errors []*Error
+}
+
+// fail records an error at a path of the response.
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: path})
+}
+
+// execute resolves the fields of an object type for each source. A result is
+// nil where a non-null field turned out null.
+func (e *executor) execute(ctx context.Context, t *Object, fields []*plannedField, sources []interface{}, paths [][]interface{}) []*resultMap {
+	results := make([]*resultMap, len(sources))
+	for i := range results {
+		results[i] = &resultMap{values: make(map[string]interface{}, len(fields))}
+	}
+	failed := make([]bool, len(sources))
+
+	for _, f := range fields {
+		fieldPaths := make([][]interface{}, len(sources))
+		for i := range sources {
+			fieldPaths[i] = appendPath(paths[i], f.key)
+		}
+		if f.field == nil {
+			for _, result := range results {
+				result.set(f.key, t.Name)
+			}
+			continue
+		}
+
+		values, err := f.field.Resolve(ctx, sources, f.args)
+		if err == nil && len(values) != len(sources) {
+			err = fmt.Errorf("%s.%s resolved %d values for %d sources", t.Name, f.field.Name, len(values), len(sources))
+		}
+		var completed []interface{}
+		var invalid []bool
+		if err != nil {
+			// The error of a batch is reported once, at its first value
+			e.fail(fieldPaths[0], err)
+			completed, invalid = make([]interface{}, len(sources)), make([]bool, len(sources))
+			if _, required := f.field.Type.(*NonNull); required {
+				for i := range invalid {
+					invalid[i] = true
+				}
+			}
+		} else {
+			completed, invalid = e.complete(ctx, f.field.Type, f.fields, values, fieldPaths)
+		}
+
+		for i, result := range results {
+			if invalid[i] {
+				failed[i] = true
+			}
+			result.set(f.key, completed[i])
+		}
+	}
+
+	for i := range results {
+		if failed[i] {
+			results[i] = nil
+		}
+	}
+	return results
+}
+
+// complete converts resolved values to the response values of a type. Values
+// are invalid where a non-null value is null, which makes the parent null.
+func (e *executor) complete(ctx context.Context, t Type, fields []*plannedField, values []interface{}, paths [][]interface{}) ([]interface{}, []bool) {
+	if nonNull, ok := t.(*NonNull); ok {
+		results, invalid := e.completeNullable(ctx, nonNull.OfType, fields, values, paths)
+		for i := range results {
+			if results[i] == nil && !invalid[i] {
+				e.fail(paths[i], fmt.Errorf("cannot return null for non-null type %s", t))
+				invalid[i] = true
+			}
+		}
+		return results, invalid
+	}
+
+	results, invalid := e.completeNullable(ctx, t, fields, values, paths)
+	for i := range invalid {
+		if invalid[i] {
+			results[i], invalid[i] = nil, false
+		}
+	}
+	return results, invalid
+}
+
+// completeNullable completes values of a type that is not non-null, leaving
+// invalid values for complete to absorb or pass on.
+func (e *executor) completeNullable(ctx context.Context, t Type, fields []*plannedField, values []interface{}, paths [][]interface{}) ([]interface{}, []bool) {
+	results := make([]interface{}, len(values))
+	invalid := make([]bool, len(values))
+
+	switch t := t.(type) {
+	case *Scalar:
+		for i, v := range values {
+			if isNil(v) {
+				continue
+			}
+			s, err := t.Serialize(v)
+			if err != nil {
+				e.fail(paths[i], err)
+				continue
+			}
+			results[i] = s
+		}
+	case *Enum:
+		for i, v := range values {
+			if isNil(v) {
+				continue
+			}
+			s := fmt.Sprint(v)
+			found := false
+			for _, value := range t.Values {
+				found = found || value == s
+			}
+			if !found {
+				e.fail(paths[i], fmt.Errorf("%s cannot represent %q", t.Name, s))
+				continue
+			}
+			results[i] = s
+		}
+	case *Object:
+		var sources []interface{}
+		var sourcePaths [][]interface{}
+		var positions []int
+		for i, v := range values {
+			if !isNil(v) {
+				sources = append(sources, v)
+				sourcePaths = append(sourcePaths, paths[i])
+				positions = append(positions, i)
+			}
+		}
+		if len(sources) == 0 {
+			break
+		}
+		for k, result := range e.execute(ctx, t, fields, sources, sourcePaths) {
+			if result == nil {
+				invalid[positions[k]] = true
+			} else {
+				results[positions[k]] = result
+			}
+		}
+	case *List:
+		// Complete the items of every list in one batch
+		var items []interface{}
+		var itemPaths [][]interface{}
+		offsets := make([]int, len(values)+1)
+		isList := make([]bool, len(values))
+		for i, v := range values {
+			offsets[i] = len(items)
+			if isNil(v) {
+				continue
+			}
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				e.fail(paths[i], fmt.Errorf("expected a list for %s, found %T", t, v))
+				continue
+			}
+			isList[i] = true
+			for j := 0; j < rv.Len(); j++ {
+				items = append(items, rv.Index(j).Interface())
+				itemPaths = append(itemPaths, appendPath(paths[i], j))
+			}
+		}
+		offsets[len(values)] = len(items)
+
+		completed, itemInvalid := e.complete(ctx, t.OfType, fields, items, itemPaths)
+		for i := range values {
+			if !isList[i] {
+				continue
+			}
+			list := make([]interface{}, 0, offsets[i+1]-offsets[i])
+			for j := offsets[i]; j < offsets[i+1]; j++ {
+				if itemInvalid[j] {
+					invalid[i] = true
+				}
+				list = append(list, completed[j])
+			}
+			if !invalid[i] {
+				results[i] = list
+			}
+		}
+	default:
+		for i := range values {
+			e.fail(paths[i], fmt.Errorf("%s is not an output type", t))
+		}
+	}
+	return results, invalid
+}
+
+// isNil reports whether v is nil or a nil pointer, slice or map.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// appendPath returns a copy of path with an element added.
+func appendPath(path []interface{}, element interface{}) []interface{} {
+	extended := make([]interface{}, len(path)+1)
+	copy(extended, path)
+	extended[len(path)] = element
+	return extended
+}
+
+// resultMap is a JSON object that keeps the order of its keys, which is the
+// order fields were selected in.
+type resultMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *resultMap) set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+func (m *resultMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(key))
+		b.WriteByte(':')
+		data, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(data)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
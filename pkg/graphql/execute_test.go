@@ -0,0 +1,262 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testUser is a user of the test schema, a ring of friends.
+type testUser struct {
+	name    string
+	friends []string
+}
+
+var testUsers = map[string]testUser{
+	"ada":   {"ada", []string{"brian", "grace"}},
+	"brian": {"brian", []string{"grace"}},
+	"grace": {"grace", []string{"ada"}},
+}
+
+// testSchema returns a schema over testUsers and a counter of the calls to
+// the friends resolver.
+func testSchema() (*Schema, *int) {
+	calls := new(int)
+	user := &Object{Name: "User"}
+	user.Fields = []*Field{
+		{Name: "name", Type: NonNullOf(String), Resolve: Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+			return source.(testUser).name, nil
+		})},
+		{Name: "friends", Type: NonNullOf(ListOf(NonNullOf(user))), ListSize: 3,
+			Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+				*calls++
+				values := make([]interface{}, len(sources))
+				for i, source := range sources {
+					var friends []testUser
+					for _, name := range source.(testUser).friends {
+						friends = append(friends, testUsers[name])
+					}
+					values[i] = friends
+				}
+				return values, nil
+			}},
+		{Name: "nemesis", Type: NonNullOf(user), Resolve: Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+			return nil, nil
+		})},
+	}
+	order := &Enum{Name: "Order", Values: []string{"ASC", "DESC"}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{Name: "users", Type: NonNullOf(ListOf(NonNullOf(user))),
+			Args: []*Argument{{Name: "first", Type: Int, Default: 2}, {Name: "order", Type: order, Default: "ASC"}},
+			Resolve: Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+				users := []testUser{testUsers["ada"], testUsers["brian"], testUsers["grace"]}
+				if args["order"] == "DESC" {
+					users[0], users[2] = users[2], users[0]
+				}
+				return users[:args["first"].(int)], nil
+			})},
+		{Name: "user", Type: user, Args: []*Argument{{Name: "name", Type: NonNullOf(String)}},
+			Resolve: Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+				if u, ok := testUsers[args["name"].(string)]; ok {
+					return u, nil
+				}
+				return nil, nil
+			})},
+	}}
+	return &Schema{Query: query, MaxDepth: 4, MaxComplexity: 100}, calls
+}
+
+// execute runs a query against the test schema and returns the response as JSON.
+func execute(t *testing.T, query string, variables map[string]interface{}) (string, int) {
+	t.Helper()
+	schema, calls := testSchema()
+	response := schema.Execute(context.Background(), Request{Query: query, Variables: variables})
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), *calls
+}
+
+func TestExecute(t *testing.T) {
+	got, _ := execute(t, `query($name: String!) {
+		first: user(name: $name) { name __typename }
+		users(order: DESC) { ...Name }
+		missing: user(name: "nobody") { name }
+	}
+	fragment Name on User { name }`, map[string]interface{}{"name": "brian"})
+
+	expected := `{"data":{"first":{"name":"brian","__typename":"User"},"users":[{"name":"grace"},{"name":"brian"}],"missing":null}}`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestExecuteBatchesNestedLists(t *testing.T) {
+	got, calls := execute(t, `{ users(first: 3) { name friends { name friends { name } } } }`, nil)
+
+	// One call per level of friends rather than one per user
+	if calls != 2 {
+		t.Errorf("Expected 2 calls of the friends resolver, got %d", calls)
+	}
+	if !strings.HasPrefix(got, `{"data":{"users":[{"name":"ada","friends":[{"name":"brian","friends":[{"name":"grace"}]},{"name":"grace","friends":[{"name":"ada"}]}]}`) {
+		t.Errorf("Unexpected response %s", got)
+	}
+}
+
+func TestExecuteMergesFields(t *testing.T) {
+	got, _ := execute(t, `{ user(name: "ada") { name } user(name: "ada") { friends { name } } }`, nil)
+	expected := `{"data":{"user":{"name":"ada","friends":[{"name":"brian"},{"name":"grace"}]}}}`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestExecuteDirectives(t *testing.T) {
+	got, _ := execute(t, `query($skip: Boolean!) { user(name: "ada") { name @skip(if: $skip) friends @include(if: false) { name } } }`,
+		map[string]interface{}{"skip": true})
+	if expected := `{"data":{"user":{}}}`; got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestExecuteNullPropagation(t *testing.T) {
+	got, _ := execute(t, `{ user(name: "ada") { name nemesis { name } } }`, nil)
+	expected := `{"data":{"user":null},"errors":[{"message":"cannot return null for non-null type User!","path":["user","nemesis"]}]}`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	got, _ = execute(t, `{ users { nemesis { name } } }`, nil)
+	if !strings.HasPrefix(got, `{"data":null,"errors":[`) {
+		t.Errorf("Expected null data, got %s", got)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	for _, tc := range []struct {
+		query     string
+		variables map[string]interface{}
+		error     string
+	}{
+		{`{ nobody }`, nil, `cannot query field \"nobody\" on type Query`},
+		{`{ user }`, nil, `argument \"name\" of Query.user of type String! is required`},
+		{`{ user(name: "ada") }`, nil, `field Query.user of type User needs a selection of subfields`},
+		{`{ users(first: "two") { name } }`, nil, `Int cannot represent \"two\"`},
+		{`{ users(first: 1.5) { name } }`, nil, `Int cannot represent 1.5`},
+		{`{ users(order: SIDEWAYS) { name } }`, nil, `SIDEWAYS is not a value of Order`},
+		{`{ users(first: $n) { name } }`, nil, `variable $n is not defined`},
+		{`query($n: Int!) { users(first: $n) { name } }`, nil, `variable $n of type Int! is required`},
+		{`{ ...F } fragment F on Query { ...F }`, nil, `fragment \"F\" spreads itself`},
+		{`{ user(name: "ada") { ...F } } fragment F on Query { users { name } }`, nil, `fragment \"F\" on Query cannot be spread on User`},
+		{`{ a: users { name } a: user(name: "ada") { name } }`, nil, `fields selected as \"a\" differ`},
+		{`mutation { users { name } }`, nil, `mutation operations are not supported`},
+		{`{ user(name: "ada") { friends { friends { friends { friends { name } } } } } }`, nil, `query depth 6 exceeds the limit of 4`},
+		{`{ users(first: 30) { friends { friends { name } } } }`, nil, `query complexity 391 exceeds the limit of 100`},
+		{`{ __type(name: "User") { ` + strings.Repeat("ofType { ", 14) + "name" + strings.Repeat(" }", 15) + ` }`,
+			nil, `introspection query depth 16 exceeds the limit of 15`},
+		{`{ __schema { types { fields { type { fields { type { fields { type { fields { name } } } } } } } } } }`,
+			nil, `introspection query complexity 2892802 exceeds the limit of 200000`},
+	} {
+		got, _ := execute(t, tc.query, tc.variables)
+		if !strings.Contains(got, tc.error) || strings.Contains(got, `"data"`) {
+			t.Errorf("%s: expected error %q, got %s", tc.query, tc.error, got)
+		}
+	}
+}
+
+func TestSDL(t *testing.T) {
+	schema, _ := testSchema()
+	sdl := schema.SDL()
+	for _, expected := range []string{
+		"type Query {\n  users(first: Int = 2, order: Order = ASC): [User!]!\n  user(name: String!): User\n}",
+		"enum Order {\n  ASC\n  DESC\n}",
+		"type User {\n  name: String!\n  friends: [User!]!\n  nemesis: User!\n}",
+	} {
+		if !strings.Contains(sdl, expected) {
+			t.Errorf("Expected %q in\n%s", expected, sdl)
+		}
+	}
+}
+
+// introspectionQuery is the introspection query of GraphiQL.
+const introspectionQuery = `query IntrospectionQuery {
+	__schema {
+		queryType { name } mutationType { name } subscriptionType { name }
+		types { ...FullType }
+		directives { name description locations args { ...InputValue } }
+	}
+}
+fragment FullType on __Type {
+	kind name description
+	fields(includeDeprecated: true) { name description args { ...InputValue } type { ...TypeRef } isDeprecated deprecationReason }
+	inputFields { ...InputValue }
+	interfaces { ...TypeRef }
+	enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+	possibleTypes { ...TypeRef }
+}
+fragment InputValue on __InputValue { name description type { ...TypeRef } defaultValue }
+fragment TypeRef on __Type {
+	kind name
+	ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } } }
+}`
+
+func TestIntrospection(t *testing.T) {
+	// The full query is deeper and more complex than the limits of the schema allow,
+	// but within the limits of introspection
+	got, _ := execute(t, introspectionQuery, nil)
+	var response struct {
+		Data struct {
+			Schema struct {
+				QueryType struct{ Name string }
+				Types     []struct {
+					Kind, Name string
+					Fields     []struct{ Name string }
+				}
+				Directives []struct{ Name string }
+			} `json:"__schema"`
+		}
+		Errors []interface{}
+	}
+	if err := json.Unmarshal([]byte(got), &response); err != nil || len(response.Errors) > 0 {
+		t.Fatalf("Expected the introspection query to succeed, got %s", got)
+	}
+	schema := response.Data.Schema
+	kinds := make(map[string]string)
+	for _, typ := range schema.Types {
+		kinds[typ.Name] = typ.Kind
+	}
+	for name, kind := range map[string]string{"Query": "OBJECT", "User": "OBJECT", "Order": "ENUM", "String": "SCALAR", "__Type": "OBJECT", "__TypeKind": "ENUM"} {
+		if kinds[name] != kind {
+			t.Errorf("Expected %s to be a %s, got %q", name, kind, kinds[name])
+		}
+	}
+	if schema.QueryType.Name != "Query" || len(schema.Directives) != 2 {
+		t.Errorf("Unexpected schema %+v", schema)
+	}
+
+	got, _ = execute(t, `{
+		order: __type(name: "Order") { kind enumValues { name } }
+		user: __type(name: "User") { fields { name type { kind name ofType { kind name } } } }
+		query: __type(name: "Query") { fields { name args { name type { name } defaultValue } } }
+		missing: __type(name: "Nope") { name }
+	}`, nil)
+	expected := `{"data":{` +
+		`"order":{"kind":"ENUM","enumValues":[{"name":"ASC"},{"name":"DESC"}]},` +
+		`"user":{"fields":[{"name":"name","type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"String"}}},` +
+		`{"name":"friends","type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"LIST","name":null}}},` +
+		`{"name":"nemesis","type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"OBJECT","name":"User"}}}]},` +
+		`"query":{"fields":[{"name":"users","args":[{"name":"first","type":{"name":"Int"},"defaultValue":"2"},{"name":"order","type":{"name":"Order"},"defaultValue":"ASC"}]},` +
+		`{"name":"user","args":[{"name":"name","type":{"name":null},"defaultValue":null}]}]},` +
+		`"missing":null}}`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	// Introspection fields belong to the query type only
+	got, _ = execute(t, `{ user(name: "ada") { __schema { types { name } } } }`, nil)
+	if !strings.Contains(got, `cannot query field \"__schema\" on type User`) {
+		t.Errorf("Expected __schema to be rejected below the query type, got %s", got)
+	}
+}
@@ -0,0 +1,233 @@
+package graphql
+
+import "context"
+
+// schemaKey is the context key of the schema a query is executed against.
+type schemaKey struct{}
+
+// schemaFrom returns the schema of the query being executed.
+func schemaFrom(ctx context.Context) *Schema {
+	return ctx.Value(schemaKey{}).(*Schema)
+}
+
+// directiveDef describes a directive for introspection.
+type directiveDef struct {
+	name        string
+	description string
+	locations   []string
+	args        []*Argument
+}
+
+// directives are the directives queries may use.
+var directives = []directiveDef{
+	{"skip", "Leaves the field or fragment out if the argument is true.", []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}, directiveArgs},
+	{"include", "Leaves the field or fragment out unless the argument is true.", []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}, directiveArgs},
+}
+
+// The introspection fields of the query type
+var schemaField, typeField = introspection()
+
+// introspection returns the __schema and __type fields of the query type,
+// which describe the schema being executed.
+func introspection() (*Field, *Field) {
+	typeKind := &Enum{Name: "__TypeKind", Description: "The kinds of types.",
+		Values: []string{"SCALAR", "OBJECT", "INTERFACE", "UNION", "ENUM", "INPUT_OBJECT", "LIST", "NON_NULL"}}
+	directiveLocation := &Enum{Name: "__DirectiveLocation", Description: "Where directives may be used.",
+		Values: []string{"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD", "INLINE_FRAGMENT", "VARIABLE_DEFINITION"}}
+	schema := &Object{Name: "__Schema", Description: "The types and directives of the schema."}
+	typ := &Object{Name: "__Type", Description: "A type of the schema."}
+	field := &Object{Name: "__Field", Description: "A field of an object type."}
+	inputValue := &Object{Name: "__InputValue", Description: "An argument or a field of an input object."}
+	enumValue := &Object{Name: "__EnumValue", Description: "A value of an enum."}
+	directive := &Object{Name: "__Directive", Description: "A directive queries may use."}
+
+	// Nothing is deprecated, so includeDeprecated changes nothing
+	includeDeprecated := []*Argument{{Name: "includeDeprecated", Type: Boolean, Default: false}}
+	notDeprecated := []*Field{
+		{Name: "isDeprecated", Type: NonNullOf(Boolean), Resolve: Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+			return false, nil
+		})},
+		{Name: "deprecationReason", Type: String, Resolve: Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+			return nil, nil
+		})},
+	}
+	resolve := func(get func(source interface{}) interface{}) Resolver {
+		return Each(func(source interface{}, args map[string]interface{}) (interface{}, error) {
+			return get(source), nil
+		})
+	}
+	description := func(d string) interface{} {
+		if d == "" {
+			return nil
+		}
+		return d
+	}
+
+	schema.Fields = []*Field{
+		{Name: "description", Type: String, Resolve: resolve(func(s interface{}) interface{} { return nil })},
+		{Name: "types", Type: NonNullOf(ListOf(NonNullOf(typ))), ListSize: 50, Resolve: resolve(func(s interface{}) interface{} {
+			return s.(*Schema).namedTypes(schema)
+		})},
+		{Name: "queryType", Type: NonNullOf(typ), Resolve: resolve(func(s interface{}) interface{} { return s.(*Schema).Query })},
+		{Name: "mutationType", Type: typ, Resolve: resolve(func(s interface{}) interface{} { return nil })},
+		{Name: "subscriptionType", Type: typ, Resolve: resolve(func(s interface{}) interface{} { return nil })},
+		{Name: "directives", Type: NonNullOf(ListOf(NonNullOf(directive))), ListSize: 2, Resolve: resolve(func(s interface{}) interface{} {
+			return directives
+		})},
+	}
+
+	typ.Fields = []*Field{
+		{Name: "kind", Type: NonNullOf(typeKind), Resolve: resolve(func(s interface{}) interface{} {
+			switch s.(type) {
+			case *Scalar:
+				return "SCALAR"
+			case *Enum:
+				return "ENUM"
+			case *Object:
+				return "OBJECT"
+			case *InputObject:
+				return "INPUT_OBJECT"
+			case *List:
+				return "LIST"
+			default:
+				return "NON_NULL"
+			}
+		})},
+		{Name: "name", Type: String, Resolve: resolve(func(s interface{}) interface{} {
+			switch s.(type) {
+			case *List, *NonNull:
+				return nil
+			}
+			return s.(Type).String()
+		})},
+		{Name: "description", Type: String, Resolve: resolve(func(s interface{}) interface{} {
+			switch t := s.(type) {
+			case *Scalar:
+				return description(t.Description)
+			case *Enum:
+				return description(t.Description)
+			case *Object:
+				return description(t.Description)
+			case *InputObject:
+				return description(t.Description)
+			}
+			return nil
+		})},
+		{Name: "specifiedByURL", Type: String, Resolve: resolve(func(s interface{}) interface{} { return nil })},
+		{Name: "fields", Type: ListOf(NonNullOf(field)), Args: includeDeprecated, ListSize: 15, Resolve: resolve(func(s interface{}) interface{} {
+			if t, ok := s.(*Object); ok {
+				return t.Fields
+			}
+			return nil
+		})},
+		{Name: "interfaces", Type: ListOf(NonNullOf(typ)), Resolve: resolve(func(s interface{}) interface{} {
+			if _, ok := s.(*Object); ok {
+				return []Type{}
+			}
+			return nil
+		})},
+		{Name: "possibleTypes", Type: ListOf(NonNullOf(typ)), Resolve: resolve(func(s interface{}) interface{} { return nil })},
+		{Name: "enumValues", Type: ListOf(NonNullOf(enumValue)), Args: includeDeprecated, ListSize: 10, Resolve: resolve(func(s interface{}) interface{} {
+			if t, ok := s.(*Enum); ok {
+				return t.Values
+			}
+			return nil
+		})},
+		{Name: "inputFields", Type: ListOf(NonNullOf(inputValue)), Args: includeDeprecated, ListSize: 10, Resolve: resolve(func(s interface{}) interface{} {
+			if t, ok := s.(*InputObject); ok {
+				return t.Fields
+			}
+			return nil
+		})},
+		{Name: "ofType", Type: typ, Resolve: resolve(func(s interface{}) interface{} {
+			switch t := s.(type) {
+			case *List:
+				return t.OfType
+			case *NonNull:
+				return t.OfType
+			}
+			return nil
+		})},
+	}
+
+	field.Fields = append([]*Field{
+		{Name: "name", Type: NonNullOf(String), Resolve: resolve(func(s interface{}) interface{} { return s.(*Field).Name })},
+		{Name: "description", Type: String, Resolve: resolve(func(s interface{}) interface{} { return description(s.(*Field).Description) })},
+		{Name: "args", Type: NonNullOf(ListOf(NonNullOf(inputValue))), Args: includeDeprecated, ListSize: 5, Resolve: resolve(func(s interface{}) interface{} {
+			if args := s.(*Field).Args; args != nil {
+				return args
+			}
+			return []*Argument{}
+		})},
+		{Name: "type", Type: NonNullOf(typ), Resolve: resolve(func(s interface{}) interface{} { return s.(*Field).Type })},
+	}, notDeprecated...)
+
+	inputValue.Fields = append([]*Field{
+		{Name: "name", Type: NonNullOf(String), Resolve: resolve(func(s interface{}) interface{} { return s.(*Argument).Name })},
+		{Name: "description", Type: String, Resolve: resolve(func(s interface{}) interface{} { return description(s.(*Argument).Description) })},
+		{Name: "type", Type: NonNullOf(typ), Resolve: resolve(func(s interface{}) interface{} { return s.(*Argument).Type })},
+		{Name: "defaultValue", Type: String, Description: "The default value as a GraphQL literal.", Resolve: resolve(func(s interface{}) interface{} {
+			if a := s.(*Argument); a.Default != nil {
+				return defaultLiteral(a)
+			}
+			return nil
+		})},
+	}, notDeprecated...)
+
+	enumValue.Fields = append([]*Field{
+		{Name: "name", Type: NonNullOf(String), Resolve: resolve(func(s interface{}) interface{} { return s })},
+		{Name: "description", Type: String, Resolve: resolve(func(s interface{}) interface{} { return nil })},
+	}, notDeprecated...)
+
+	directive.Fields = []*Field{
+		{Name: "name", Type: NonNullOf(String), Resolve: resolve(func(s interface{}) interface{} { return s.(directiveDef).name })},
+		{Name: "description", Type: String, Resolve: resolve(func(s interface{}) interface{} { return s.(directiveDef).description })},
+		{Name: "locations", Type: NonNullOf(ListOf(NonNullOf(directiveLocation))), Resolve: resolve(func(s interface{}) interface{} {
+			return s.(directiveDef).locations
+		})},
+		{Name: "args", Type: NonNullOf(ListOf(NonNullOf(inputValue))), Args: includeDeprecated, Resolve: resolve(func(s interface{}) interface{} {
+			return s.(directiveDef).args
+		})},
+		{Name: "isRepeatable", Type: NonNullOf(Boolean), Resolve: resolve(func(s interface{}) interface{} { return false })},
+	}
+
+	schemaField := &Field{Name: "__schema", Type: NonNullOf(schema), Description: "Describes the schema.",
+		Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			return []interface{}{schemaFrom(ctx)}, nil
+		}}
+	typeField := &Field{Name: "__type", Type: typ, Description: "The named type, or null if the schema has none.",
+		Args: []*Argument{{Name: "name", Type: NonNullOf(String)}},
+		Resolve: func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			for _, t := range schemaFrom(ctx).namedTypes(schema) {
+				if t.String() == args["name"] {
+					return []interface{}{t}, nil
+				}
+			}
+			return []interface{}{nil}, nil
+		}}
+	return schemaField, typeField
+}
+
+// The full introspection query of tools such as GraphiQL nests type
+// references deeper, and selects more of the schema, than the limits of a
+// schema typically allow for its own fields, so the fields under __schema and
+// __type are limited separately. The ListSize of the introspection list fields
+// bounds the schemas they describe well, so the complexity bounds the size of
+// the response.
+const (
+	maxIntrospectionDepth      = 15
+	maxIntrospectionComplexity = 200000
+)
+
+// splitIntrospection separates the planned fields of the query type into the
+// fields of the schema and the introspection fields.
+func splitIntrospection(fields []*plannedField) (schemaFields, introspectionFields []*plannedField) {
+	for _, f := range fields {
+		if f.field == schemaField || f.field == typeField {
+			introspectionFields = append(introspectionFields, f)
+		} else {
+			schemaFields = append(schemaFields, f)
+		}
+	}
+	return schemaFields, introspectionFields
+}
@@ -0,0 +1,615 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL request document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is an operation definition of a document.
+type operation struct {
+	kind       string
+	name       string
+	variables  []*variableDefinition
+	selections []*selection
+}
+
+// variableDefinition declares a variable of an operation.
+type variableDefinition struct {
+	name         string
+	typ          *typeRef
+	defaultValue *value
+}
+
+// typeRef is a type as written in a variable definition.
+type typeRef struct {
+	name    string
+	ofType  *typeRef // set for list types
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.ofType != nil {
+		s = "[" + t.ofType.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// fragment is a named fragment definition.
+type fragment struct {
+	name          string
+	typeCondition string
+	selections    []*selection
+}
+
+// Kinds of selection
+const (
+	fieldSelection = iota
+	fragmentSpread
+	inlineFragment
+)
+
+// selection is a field, a fragment spread or an inline fragment.
+type selection struct {
+	kind int
+	pos  int
+
+	// fields
+	alias     string
+	name      string
+	arguments []*argument
+
+	// fragment spreads refer to a fragment by name, inline fragments may
+	// have a type condition
+	fragment      string
+	typeCondition string
+
+	directives []*directive
+	selections []*selection
+}
+
+// responseKey is the key of a field in the response.
+func (s *selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// argument is a named value passed to a field, directive or input object.
+type argument struct {
+	name  string
+	value *value
+}
+
+// directive is a directive such as @skip(if: true).
+type directive struct {
+	name      string
+	arguments []*argument
+}
+
+// Kinds of value
+const (
+	variableValue = iota
+	intValue
+	floatValue
+	stringValue
+	booleanValue
+	nullValue
+	enumValue
+	listValue
+	objectValue
+)
+
+// value is a literal or variable in a document.
+type value struct {
+	kind   int
+	text   string
+	list   []*value
+	fields []*argument
+}
+
+// Kinds of token
+const (
+	eofToken = iota
+	punctuatorToken
+	nameToken
+	intToken
+	floatToken
+	stringToken
+)
+
+// token is a lexical token of a document.
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// SyntaxError reports a document that is not valid GraphQL.
+type SyntaxError struct {
+	Line, Column int
+	Message      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// parser reads a document token by token.
+type parser struct {
+	source string
+	pos    int
+	tok    token
+}
+
+// parse parses a request document.
+func parse(source string) (doc *document, err error) {
+	p := &parser{source: source}
+	defer func() {
+		// Syntax errors unwind the recursive descent
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, syntaxErr
+		}
+	}()
+
+	p.advance()
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != eofToken {
+		switch {
+		case p.peek("{"), p.peek("query"), p.peek("mutation"), p.peek("subscription"):
+			doc.operations = append(doc.operations, p.parseOperation())
+		case p.peek("fragment"):
+			f := p.parseFragment()
+			if _, ok := doc.fragments[f.name]; ok {
+				p.fail(p.tok.pos, "fragment %q is defined more than once", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.fail(p.tok.pos, "the document has no operation")
+	}
+	return doc, nil
+}
+
+// fail aborts parsing with an error at a byte offset of the source.
+func (p *parser) fail(pos int, format string, args ...interface{}) {
+	line, column := 1, 1
+	for _, r := range p.source[:pos] {
+		if r == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	panic(&SyntaxError{Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// unexpected fails at the current token.
+func (p *parser) unexpected() {
+	if p.tok.kind == eofToken {
+		p.fail(p.tok.pos, "unexpected end of document")
+	}
+	p.fail(p.tok.pos, "unexpected %q", p.tok.text)
+}
+
+// peek reports whether the current token is a punctuator or name with the given text.
+func (p *parser) peek(text string) bool {
+	return (p.tok.kind == punctuatorToken || p.tok.kind == nameToken) && p.tok.text == text
+}
+
+// skip consumes the current token if it has the given text.
+func (p *parser) skip(text string) bool {
+	if p.peek(text) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// expect consumes the current token, which must have the given text.
+func (p *parser) expect(text string) {
+	if !p.skip(text) {
+		if p.tok.kind == eofToken {
+			p.fail(p.tok.pos, "expected %q, found end of document", text)
+		}
+		p.fail(p.tok.pos, "expected %q, found %q", text, p.tok.text)
+	}
+}
+
+// name consumes a name token.
+func (p *parser) name() string {
+	if p.tok.kind != nameToken {
+		if p.tok.kind == eofToken {
+			p.fail(p.tok.pos, "expected a name, found end of document")
+		}
+		p.fail(p.tok.pos, "expected a name, found %q", p.tok.text)
+	}
+	name := p.tok.text
+	p.advance()
+	return name
+}
+
+func (p *parser) parseOperation() *operation {
+	op := &operation{kind: "query"}
+	if p.peek("{") {
+		op.selections = p.parseSelectionSet()
+		return op
+	}
+	op.kind = p.name()
+	if p.tok.kind == nameToken {
+		op.name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			v := &variableDefinition{name: p.name()}
+			p.expect(":")
+			v.typ = p.parseType()
+			if p.skip("=") {
+				v.defaultValue = p.parseValue(true)
+			}
+			op.variables = append(op.variables, v)
+		}
+	}
+	p.parseDirectives()
+	op.selections = p.parseSelectionSet()
+	return op
+}
+
+func (p *parser) parseFragment() *fragment {
+	p.expect("fragment")
+	pos := p.tok.pos
+	f := &fragment{name: p.name()}
+	if f.name == "on" {
+		p.fail(pos, "a fragment cannot be named \"on\"")
+	}
+	p.expect("on")
+	f.typeCondition = p.name()
+	p.parseDirectives()
+	f.selections = p.parseSelectionSet()
+	return f
+}
+
+func (p *parser) parseType() *typeRef {
+	var t *typeRef
+	if p.skip("[") {
+		t = &typeRef{ofType: p.parseType()}
+		p.expect("]")
+	} else {
+		t = &typeRef{name: p.name()}
+	}
+	t.nonNull = p.skip("!")
+	return t
+}
+
+func (p *parser) parseSelectionSet() []*selection {
+	p.expect("{")
+	var selections []*selection
+	for !p.skip("}") {
+		selections = append(selections, p.parseSelection())
+	}
+	if len(selections) == 0 {
+		p.fail(p.tok.pos, "a selection set cannot be empty")
+	}
+	return selections
+}
+
+func (p *parser) parseSelection() *selection {
+	s := &selection{pos: p.tok.pos}
+	if p.skip("...") {
+		if p.tok.kind == nameToken && p.tok.text != "on" {
+			s.kind = fragmentSpread
+			s.fragment = p.name()
+			s.directives = p.parseDirectives()
+			return s
+		}
+		s.kind = inlineFragment
+		if p.skip("on") {
+			s.typeCondition = p.name()
+		}
+		s.directives = p.parseDirectives()
+		s.selections = p.parseSelectionSet()
+		return s
+	}
+
+	s.kind = fieldSelection
+	s.name = p.name()
+	if p.skip(":") {
+		s.alias, s.name = s.name, p.name()
+	}
+	s.arguments = p.parseArguments(false)
+	s.directives = p.parseDirectives()
+	if p.peek("{") {
+		s.selections = p.parseSelectionSet()
+	}
+	return s
+}
+
+func (p *parser) parseArguments(constant bool) []*argument {
+	var arguments []*argument
+	if p.skip("(") {
+		for !p.skip(")") {
+			a := &argument{name: p.name()}
+			p.expect(":")
+			a.value = p.parseValue(constant)
+			arguments = append(arguments, a)
+		}
+	}
+	return arguments
+}
+
+func (p *parser) parseDirectives() []*directive {
+	var directives []*directive
+	for p.skip("@") {
+		directives = append(directives, &directive{name: p.name(), arguments: p.parseArguments(false)})
+	}
+	return directives
+}
+
+// parseValue parses a value, which may not contain variables if constant.
+func (p *parser) parseValue(constant bool) *value {
+	tok := p.tok
+	switch {
+	case tok.kind == intToken:
+		p.advance()
+		return &value{kind: intValue, text: tok.text}
+	case tok.kind == floatToken:
+		p.advance()
+		return &value{kind: floatValue, text: tok.text}
+	case tok.kind == stringToken:
+		p.advance()
+		return &value{kind: stringValue, text: tok.text}
+	case tok.kind == nameToken:
+		p.advance()
+		switch tok.text {
+		case "true", "false":
+			return &value{kind: booleanValue, text: tok.text}
+		case "null":
+			return &value{kind: nullValue}
+		}
+		return &value{kind: enumValue, text: tok.text}
+	case p.skip("$"):
+		if constant {
+			p.fail(tok.pos, "variables are not allowed here")
+		}
+		return &value{kind: variableValue, text: p.name()}
+	case p.skip("["):
+		v := &value{kind: listValue}
+		for !p.skip("]") {
+			v.list = append(v.list, p.parseValue(constant))
+		}
+		return v
+	case p.skip("{"):
+		v := &value{kind: objectValue}
+		for !p.skip("}") {
+			f := &argument{name: p.name()}
+			p.expect(":")
+			f.value = p.parseValue(constant)
+			v.fields = append(v.fields, f)
+		}
+		return v
+	}
+	p.unexpected()
+	return nil
+}
+
+// advance reads the next token, skipping whitespace, commas and comments.
+func (p *parser) advance() {
+	src := p.source
+	for p.pos < len(src) {
+		c := src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if strings.HasPrefix(src[p.pos:], "\uFEFF") {
+			p.pos += len("\uFEFF")
+		} else if c == '#' {
+			for p.pos < len(src) && src[p.pos] != '\n' && src[p.pos] != '\r' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	start := p.pos
+	if p.pos == len(src) {
+		p.tok = token{kind: eofToken, pos: start}
+		return
+	}
+	c := src[p.pos]
+	switch {
+	case strings.HasPrefix(src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: punctuatorToken, text: "...", pos: start}
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = token{kind: punctuatorToken, text: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(src) && (src[p.pos] == '_' || isLetter(src[p.pos]) || isDigit(src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: nameToken, text: src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.tok = p.number()
+	case c == '"':
+		if strings.HasPrefix(src[p.pos:], `"""`) {
+			p.tok = p.blockString()
+		} else {
+			p.tok = p.string()
+		}
+	default:
+		r, _ := utf8.DecodeRuneInString(src[p.pos:])
+		p.fail(start, "unexpected character %q", r)
+	}
+}
+
+// number reads an int or float token.
+func (p *parser) number() token {
+	src, start := p.source, p.pos
+	digits := func() {
+		from := p.pos
+		for p.pos < len(src) && isDigit(src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == from {
+			p.fail(p.pos, "expected a digit")
+		}
+	}
+
+	kind := intToken
+	if src[p.pos] == '-' {
+		p.pos++
+	}
+	if p.pos < len(src) && src[p.pos] == '0' {
+		p.pos++
+		if p.pos < len(src) && isDigit(src[p.pos]) {
+			p.fail(p.pos, "a number cannot have leading zeros")
+		}
+	} else {
+		digits()
+	}
+	if p.pos < len(src) && src[p.pos] == '.' {
+		kind = floatToken
+		p.pos++
+		digits()
+	}
+	if p.pos < len(src) && (src[p.pos] == 'e' || src[p.pos] == 'E') {
+		kind = floatToken
+		p.pos++
+		if p.pos < len(src) && (src[p.pos] == '+' || src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	if p.pos < len(src) && (src[p.pos] == '_' || src[p.pos] == '.' || isLetter(src[p.pos])) {
+		p.fail(p.pos, "invalid number")
+	}
+	return token{kind: kind, text: src[start:p.pos], pos: start}
+}
+
+// string reads a quoted string token with its escape sequences.
+func (p *parser) string() token {
+	src, start := p.source, p.pos
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(src) || src[p.pos] == '\n' || src[p.pos] == '\r' {
+			p.fail(start, "unterminated string")
+		}
+		c := src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return token{kind: stringToken, text: b.String(), pos: start}
+		case c == '\\':
+			if p.pos+1 >= len(src) {
+				p.fail(start, "unterminated string")
+			}
+			escape := src[p.pos+1]
+			p.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(src) {
+					p.fail(p.pos-2, "invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.fail(p.pos-2, "invalid unicode escape")
+				}
+				b.WriteRune(rune(n))
+				p.pos += 4
+			default:
+				p.fail(p.pos-2, "invalid escape sequence \\%c", escape)
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// blockString reads a triple-quoted string token, removing the common
+// indentation and the blank first and last lines.
+func (p *parser) blockString() token {
+	src, start := p.source, p.pos
+	p.pos += 3
+	var b strings.Builder
+	for {
+		if p.pos >= len(src) {
+			p.fail(start, "unterminated string")
+		}
+		if strings.HasPrefix(src[p.pos:], `"""`) {
+			p.pos += 3
+			break
+		}
+		if strings.HasPrefix(src[p.pos:], `\"""`) {
+			b.WriteString(`"""`)
+			p.pos += 4
+			continue
+		}
+		b.WriteByte(src[p.pos])
+		p.pos++
+	}
+
+	lines := strings.Split(strings.ReplaceAll(b.String(), "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = ""
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return token{kind: stringToken, text: strings.Join(lines, "\n"), pos: start}
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,82 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# Named query with variables, aliases, fragments and directives
+		query Recent($first: Int = 5, $types: [String!]!) {
+			latest: events(first: $first, filter: {types: $types, note: "a \"b\"\n"}) {
+				...EventFields
+				... on Event @include(if: true) { id }
+			}
+		}
+		fragment EventFields on Event { type, createdAt }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 1 || len(doc.fragments) != 1 {
+		t.Fatalf("Unexpected document %+v", doc)
+	}
+
+	op := doc.operations[0]
+	if op.name != "Recent" || len(op.variables) != 2 || op.variables[1].typ.String() != "[String!]!" {
+		t.Errorf("Unexpected operation %+v", op)
+	}
+	if op.variables[0].defaultValue.text != "5" {
+		t.Errorf("Unexpected default %+v", op.variables[0].defaultValue)
+	}
+
+	field := op.selections[0]
+	if field.responseKey() != "latest" || field.name != "events" || len(field.arguments) != 2 {
+		t.Fatalf("Unexpected field %+v", field)
+	}
+	filter := field.arguments[1].value
+	if filter.kind != objectValue || filter.fields[1].value.text != "a \"b\"\n" {
+		t.Errorf("Unexpected filter %+v", filter)
+	}
+	if field.selections[0].kind != fragmentSpread || field.selections[1].kind != inlineFragment ||
+		field.selections[1].typeCondition != "Event" || len(field.selections[1].directives) != 1 {
+		t.Errorf("Unexpected selections %+v", field.selections)
+	}
+	if frag := doc.fragments["EventFields"]; frag.typeCondition != "Event" || len(frag.selections) != 2 {
+		t.Errorf("Unexpected fragment %+v", frag)
+	}
+}
+
+func TestParseBlockString(t *testing.T) {
+	doc, err := parse(`{ f(s: """
+		first
+		  second
+	""") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.operations[0].selections[0].arguments[0].value.text; got != "first\n  second" {
+		t.Errorf("Unexpected block string %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		error string
+	}{
+		{"", "1:1: the document has no operation"},
+		{"{ a", "1:4: expected a name, found end of document"},
+		{"{ a(b: ) }", "1:8: unexpected \")\""},
+		{"{\n  a(b: 01) }", "2:9: a number cannot have leading zeros"},
+		{`{ a(b: "x) }`, "1:8: unterminated string"},
+		{"{ a } fragment F on T { a } fragment F on T { b }", "fragment \"F\" is defined more than once"},
+		{"{ }", "a selection set cannot be empty"},
+		{"{ a % }", "unexpected character '%'"},
+	} {
+		_, err := parse(tc.query)
+		if err == nil || !strings.Contains(err.Error(), tc.error) {
+			t.Errorf("Parsing %q: expected error %q, got %v", tc.query, tc.error, err)
+		}
+	}
+}
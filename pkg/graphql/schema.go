@@ -0,0 +1,513 @@
+// Package graphql executes GraphQL queries (https://spec.graphql.org) against
+// a schema of object types defined in Go. Resolvers receive every parent value
+// of a field at once, so a field selected below a list is resolved with one
+// call, typically one store query, instead of one call per item. Queries are
+// checked against the schema and limits on their depth and estimated
+// complexity before anything is resolved. The schema can be introspected
+// with __schema and __type, or read with Schema.SDL. Mutations,
+// subscriptions, interfaces and unions are not supported.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a GraphQL type: a *Scalar, *Enum, *Object, *InputObject, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type.
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize converts a resolved value to its JSON representation.
+	Serialize func(v interface{}) (interface{}, error)
+	// Parse converts an input value, as decoded from JSON with UseNumber or
+	// written in the query, to the value passed to resolvers.
+	Parse func(v interface{}) (interface{}, error)
+}
+
+func (t *Scalar) String() string { return t.Name }
+
+// Enum is a leaf type with a fixed set of string values.
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+func (t *Enum) String() string { return t.Name }
+
+// Object is a type with fields.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (t *Object) String() string { return t.Name }
+
+// field returns the field with the given name, or nil.
+func (t *Object) field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Field is a field of an object type.
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	Resolve     Resolver
+	// ListSize estimates the length of a list field without a "first"
+	// argument for the complexity of queries; lists count once if zero.
+	ListSize int
+}
+
+// Argument is an argument of a field or a field of an input object.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	// Default is the value used when the argument is omitted, if not nil.
+	Default interface{}
+}
+
+// InputObject is a structured argument type, passed to resolvers as a
+// map[string]interface{}.
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      []*Argument
+}
+
+func (t *InputObject) String() string { return t.Name }
+
+// List is a list of another type. Resolvers may return any slice for a list.
+type List struct {
+	OfType Type
+}
+
+func (t *List) String() string { return "[" + t.OfType.String() + "]" }
+
+// NonNull is a type that cannot be null.
+type NonNull struct {
+	OfType Type
+}
+
+func (t *NonNull) String() string { return t.OfType.String() + "!" }
+
+// ListOf returns the list type of t.
+func ListOf(t Type) *List { return &List{OfType: t} }
+
+// NonNullOf returns the non-null type of t.
+func NonNullOf(t Type) *NonNull { return &NonNull{OfType: t} }
+
+// Resolver resolves a field for a batch of parent values, returning one value
+// per source in the same order. args holds the field's arguments, coerced to
+// int, float64, string, bool, []interface{} and map[string]interface{}; an
+// argument that is omitted and has no default is missing.
+type Resolver func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error)
+
+// Each returns a Resolver that resolves every source on its own, for fields
+// that need no I/O.
+func Each(resolve func(source interface{}, args map[string]interface{}) (interface{}, error)) Resolver {
+	return func(ctx context.Context, sources []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		values := make([]interface{}, len(sources))
+		for i, source := range sources {
+			v, err := resolve(source, args)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+}
+
+// Built-in scalars
+var (
+	String = &Scalar{
+		Name:        "String",
+		Description: "UTF-8 text.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			switch v := v.(type) {
+			case string:
+				return v, nil
+			case fmt.Stringer:
+				return v.String(), nil
+			}
+			return nil, fmt.Errorf("String cannot represent %T", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %s", describe(v))
+		},
+	}
+	Int = &Scalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize:   func(v interface{}) (interface{}, error) { return toInt(v) },
+		Parse:       func(v interface{}) (interface{}, error) { return toInt(v) },
+	}
+	Float = &Scalar{
+		Name:        "Float",
+		Description: "A double-precision floating-point number.",
+		Serialize:   func(v interface{}) (interface{}, error) { return toFloat(v) },
+		Parse:       func(v interface{}) (interface{}, error) { return toFloat(v) },
+	}
+	Boolean = &Scalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %T", v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %s", describe(v))
+		},
+	}
+	ID = &Scalar{
+		Name:        "ID",
+		Description: "A unique identifier, serialized as a string.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			n, err := toInt64(v)
+			if err != nil {
+				return nil, fmt.Errorf("ID cannot represent %T", v)
+			}
+			return strconv.FormatInt(n, 10), nil
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			n, err := toInt64(v)
+			if err != nil {
+				return nil, fmt.Errorf("ID cannot represent %s", describe(v))
+			}
+			return strconv.FormatInt(n, 10), nil
+		},
+	}
+)
+
+// toInt converts an integer that fits in 32 bits to int.
+func toInt(v interface{}) (interface{}, error) {
+	n, err := toInt64(v)
+	if err != nil {
+		return nil, fmt.Errorf("Int cannot represent %s", describe(v))
+	}
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return nil, fmt.Errorf("Int cannot represent %d, which needs more than 32 bits", n)
+	}
+	return int(n), nil
+}
+
+// toInt64 converts Go and JSON integers, including integral floats.
+func toInt64(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case json.Number:
+		return v.Int64()
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v), nil
+		}
+	}
+	return 0, fmt.Errorf("not an integer: %s", describe(v))
+}
+
+// toFloat converts Go and JSON numbers to float64.
+func toFloat(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err == nil {
+			return f, nil
+		}
+	default:
+		if n, err := toInt64(v); err == nil {
+			return float64(n), nil
+		}
+	}
+	return nil, fmt.Errorf("Float cannot represent %s", describe(v))
+}
+
+// describe formats an input value for error messages.
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case enumLiteral:
+		return string(v)
+	case json.Number:
+		return v.String()
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(v)
+}
+
+// Schema is the type system queries are executed against.
+type Schema struct {
+	Query *Object
+	// MaxDepth limits the nesting of fields, where the fields of the query
+	// are at depth 1. Zero means no limit. Introspection has its own limits,
+	// see maxIntrospectionDepth.
+	MaxDepth int
+	// MaxComplexity limits the estimated cost of a query: each field costs
+	// 1, and the fields below a field with a "first" argument, or with a
+	// ListSize, count that many times. Zero means no limit.
+	MaxComplexity int
+}
+
+// Request is a GraphQL request as sent in the body of a POST.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a request. Data is omitted if the request was
+// rejected before execution.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error of a request or of resolving a field.
+type Error struct {
+	Message string `json:"message"`
+	// Path locates the field that failed in the response.
+	Path []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Execute parses, validates and executes a query.
+func (s *Schema) Execute(ctx context.Context, request Request) Response {
+	doc, err := parse(request.Query)
+	if err != nil {
+		return Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	op, err := selectOperation(doc, request.OperationName)
+	if err != nil {
+		return Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	variables, err := coerceVariables(s, op, request.Variables)
+	if err != nil {
+		return Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	p := &planner{schema: s, doc: doc, variables: variables, declared: make(map[string]bool), visiting: make(map[string]bool)}
+	for _, def := range op.variables {
+		p.declared[def.name] = true
+	}
+	fields, err := p.plan(s.Query, op.selections)
+	if err != nil {
+		return Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	schemaFields, introspectionFields := splitIntrospection(fields)
+	if err := checkLimits(schemaFields, s.MaxDepth, s.MaxComplexity); err != nil {
+		return Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if err := checkLimits(introspectionFields, maxIntrospectionDepth, maxIntrospectionComplexity); err != nil {
+		return Response{Errors: []*Error{{Message: "introspection " + err.Error()}}}
+	}
+
+	e := &executor{}
+	ctx = context.WithValue(ctx, schemaKey{}, s)
+	results := e.execute(ctx, s.Query, fields, []interface{}{nil}, [][]interface{}{nil})
+	response := Response{Data: results[0], Errors: e.errors}
+	if results[0] == nil {
+		response.Data = json.RawMessage("null")
+	}
+	return response
+}
+
+// selectOperation returns the operation to execute.
+func selectOperation(doc *document, name string) (*operation, error) {
+	var op *operation
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		op = doc.operations[0]
+	} else {
+		for _, o := range doc.operations {
+			if o.name == name {
+				op = o
+			}
+		}
+		if op == nil {
+			return nil, fmt.Errorf("no operation named %q", name)
+		}
+	}
+	if op.kind != "query" {
+		return nil, fmt.Errorf("%s operations are not supported", op.kind)
+	}
+	return op, nil
+}
+
+// namedTypes returns the named types used by the query type, which comes
+// first, and by the given roots, in order of name.
+func (s *Schema) namedTypes(roots ...Type) []Type {
+	var named []Type
+	seen := make(map[string]bool)
+	var visit func(t Type)
+	visit = func(t Type) {
+		switch t := t.(type) {
+		case *List:
+			visit(t.OfType)
+			return
+		case *NonNull:
+			visit(t.OfType)
+			return
+		}
+		if seen[t.String()] {
+			return
+		}
+		seen[t.String()] = true
+		named = append(named, t)
+		switch t := t.(type) {
+		case *Object:
+			for _, f := range t.Fields {
+				for _, a := range f.Args {
+					visit(a.Type)
+				}
+				visit(f.Type)
+			}
+		case *InputObject:
+			for _, f := range t.Fields {
+				visit(f.Type)
+			}
+		}
+	}
+	visit(s.Query)
+	for _, root := range roots {
+		visit(root)
+	}
+	sort.SliceStable(named[1:], func(i, j int) bool { return named[i+1].String() < named[j+1].String() })
+	return named
+}
+
+// SDL describes the schema in the GraphQL schema definition language.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	for _, t := range s.namedTypes() {
+		switch t := t.(type) {
+		case *Scalar:
+			if t == String || t == Int || t == Float || t == Boolean || t == ID {
+				continue
+			}
+			writeDescription(&b, "", t.Description)
+			fmt.Fprintf(&b, "scalar %s\n\n", t.Name)
+		case *Enum:
+			writeDescription(&b, "", t.Description)
+			fmt.Fprintf(&b, "enum %s {\n  %s\n}\n\n", t.Name, strings.Join(t.Values, "\n  "))
+		case *Object:
+			writeDescription(&b, "", t.Description)
+			fmt.Fprintf(&b, "type %s {\n", t.Name)
+			for _, f := range t.Fields {
+				writeDescription(&b, "  ", f.Description)
+				fmt.Fprintf(&b, "  %s%s: %s\n", f.Name, sdlArguments(f.Args), f.Type)
+			}
+			b.WriteString("}\n\n")
+		case *InputObject:
+			writeDescription(&b, "", t.Description)
+			fmt.Fprintf(&b, "input %s {\n", t.Name)
+			for _, f := range t.Fields {
+				writeDescription(&b, "  ", f.Description)
+				fmt.Fprintf(&b, "  %s\n", sdlArgument(f))
+			}
+			b.WriteString("}\n\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeDescription writes a description as a block string.
+func writeDescription(b *strings.Builder, indent, description string) {
+	if description == "" {
+		return
+	}
+	fmt.Fprintf(b, "%s\"\"\"%s\"\"\"\n", indent, strings.ReplaceAll(description, `"""`, `\"""`))
+}
+
+func sdlArguments(args []*Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = sdlArgument(a)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func sdlArgument(a *Argument) string {
+	s := a.Name + ": " + a.Type.String()
+	if a.Default != nil {
+		s += " = " + defaultLiteral(a)
+	}
+	return s
+}
+
+// defaultLiteral formats the default value of an argument as a GraphQL literal.
+func defaultLiteral(a *Argument) string {
+	if _, ok := namedType(a.Type).(*Enum); ok {
+		return fmt.Sprint(a.Default)
+	}
+	return sdlValue(a.Default)
+}
+
+// sdlValue formats a default value as a GraphQL literal.
+func sdlValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case enumLiteral:
+		return string(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = sdlValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected at least one migration")
+	}
+
+	// Versions must start at 1 and have no gaps so they apply in a predictable order
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d (%s)", i, i+1, m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("Expected migration %04d_%s to have up and down scripts", m.Version, m.Name)
+		}
+	}
+
+	// The first migration creates the events table
+	if !strings.Contains(migrations[0].Up, "CREATE TABLE IF NOT EXISTS github (") {
+		t.Errorf("Expected first migration to create the github table, got %q", migrations[0].Up)
+	}
+}
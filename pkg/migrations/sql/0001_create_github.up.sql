@@ -0,0 +1,11 @@
+-- Events collected from the GitHub Events API
+CREATE TABLE IF NOT EXISTS github (
+    id serial PRIMARY KEY,
+    event_type varchar(255),
+    actor varchar(255),
+    repo_url varchar(255),
+    created_at timestamp NOT NULL
+);
+
+-- Index on 'created_at' for retention cleanup and time-window queries
+CREATE INDEX IF NOT EXISTS idx_github_created_at ON github(created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS github_issue_transitions;
+DROP TABLE IF EXISTS github_issues;
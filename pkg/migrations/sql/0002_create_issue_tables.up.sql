@@ -0,0 +1,22 @@
+-- Current state of every issue seen in IssuesEvent and IssueCommentEvent payloads
+CREATE TABLE IF NOT EXISTS github_issues (
+    repo_url varchar(255) NOT NULL,
+    number integer NOT NULL,
+    author varchar(255),
+    state varchar(16) NOT NULL,
+    opened_at timestamp NOT NULL,
+    closed_at timestamp,
+    first_response_at timestamp,
+    reopen_count integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (repo_url, number)
+);
+
+-- Open/close/reopen history of every issue
+CREATE TABLE IF NOT EXISTS github_issue_transitions (
+    id serial PRIMARY KEY,
+    repo_url varchar(255) NOT NULL,
+    number integer NOT NULL,
+    action varchar(16) NOT NULL,
+    actor varchar(255),
+    occurred_at timestamp NOT NULL
+);
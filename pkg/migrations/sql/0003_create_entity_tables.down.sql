@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS github_emails;
+DROP TABLE IF EXISTS github_repositories;
+DROP TABLE IF EXISTS github_actors;
@@ -0,0 +1,17 @@
+-- Actors (GitHub users)
+CREATE TABLE IF NOT EXISTS github_actors (
+    id serial PRIMARY KEY,
+    login varchar(255) UNIQUE
+);
+
+-- Repositories
+CREATE TABLE IF NOT EXISTS github_repositories (
+    id serial PRIMARY KEY,
+    url varchar(255) UNIQUE
+);
+
+-- Commit author emails
+CREATE TABLE IF NOT EXISTS github_emails (
+    id serial PRIMARY KEY,
+    email varchar(255) UNIQUE
+);
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS github_event_emails;
+
+ALTER TABLE github
+    DROP COLUMN IF EXISTS actor_id,
+    DROP COLUMN IF EXISTS repo_id;
+
+ALTER TABLE github_emails
+    DROP COLUMN IF EXISTS first_seen,
+    DROP COLUMN IF EXISTS last_seen;
+ALTER TABLE github_repositories
+    DROP COLUMN IF EXISTS first_seen,
+    DROP COLUMN IF EXISTS last_seen;
+ALTER TABLE github_actors
+    DROP COLUMN IF EXISTS first_seen,
+    DROP COLUMN IF EXISTS last_seen;
@@ -0,0 +1,41 @@
+-- Track when each actor, repository and email was first and last seen
+ALTER TABLE github_actors
+    ADD COLUMN first_seen timestamp,
+    ADD COLUMN last_seen timestamp;
+ALTER TABLE github_repositories
+    ADD COLUMN first_seen timestamp,
+    ADD COLUMN last_seen timestamp;
+ALTER TABLE github_emails
+    ADD COLUMN first_seen timestamp,
+    ADD COLUMN last_seen timestamp;
+
+-- Link events to their actor and repository
+ALTER TABLE github
+    ADD COLUMN actor_id integer REFERENCES github_actors(id),
+    ADD COLUMN repo_id integer REFERENCES github_repositories(id);
+
+-- Link events to the commit author emails in their payload
+CREATE TABLE github_event_emails (
+    event_id integer NOT NULL REFERENCES github(id) ON DELETE CASCADE,
+    email_id integer NOT NULL REFERENCES github_emails(id),
+    PRIMARY KEY (event_id, email_id)
+);
+
+-- Backfill entities from events collected before this migration
+INSERT INTO github_actors (login, first_seen, last_seen)
+SELECT actor, MIN(created_at), MAX(created_at) FROM github WHERE actor IS NOT NULL GROUP BY actor
+ON CONFLICT (login) DO UPDATE SET first_seen = EXCLUDED.first_seen, last_seen = EXCLUDED.last_seen;
+
+INSERT INTO github_repositories (url, first_seen, last_seen)
+SELECT repo_url, MIN(created_at), MAX(created_at) FROM github WHERE repo_url IS NOT NULL GROUP BY repo_url
+ON CONFLICT (url) DO UPDATE SET first_seen = EXCLUDED.first_seen, last_seen = EXCLUDED.last_seen;
+
+UPDATE github SET actor_id = a.id FROM github_actors a WHERE a.login = github.actor;
+UPDATE github SET repo_id = r.id FROM github_repositories r WHERE r.url = github.repo_url;
+
+CREATE INDEX idx_github_actor_id ON github(actor_id);
+CREATE INDEX idx_github_repo_id ON github(repo_id);
+CREATE INDEX idx_github_event_emails_email_id ON github_event_emails(email_id);
+CREATE INDEX idx_github_actors_last_seen ON github_actors(last_seen);
+CREATE INDEX idx_github_repositories_last_seen ON github_repositories(last_seen);
+CREATE INDEX idx_github_emails_last_seen ON github_emails(last_seen);
@@ -0,0 +1,37 @@
+ALTER TABLE github RENAME TO github_partitioned;
+ALTER TABLE github_event_emails RENAME TO github_event_emails_partitioned;
+ALTER TABLE github_partitioned RENAME CONSTRAINT github_pkey TO github_partitioned_pkey;
+ALTER TABLE github_event_emails_partitioned RENAME CONSTRAINT github_event_emails_pkey TO github_event_emails_partitioned_pkey;
+ALTER SEQUENCE github_id_seq OWNED BY NONE;
+
+CREATE TABLE github (
+    id integer PRIMARY KEY DEFAULT nextval('github_id_seq'),
+    event_type varchar(255),
+    actor varchar(255),
+    repo_url varchar(255),
+    created_at timestamp NOT NULL,
+    actor_id integer REFERENCES github_actors(id),
+    repo_id integer REFERENCES github_repositories(id)
+);
+
+CREATE TABLE github_event_emails (
+    event_id integer NOT NULL REFERENCES github(id) ON DELETE CASCADE,
+    email_id integer NOT NULL REFERENCES github_emails(id),
+    PRIMARY KEY (event_id, email_id)
+);
+
+INSERT INTO github (id, event_type, actor, repo_url, created_at, actor_id, repo_id)
+SELECT id, event_type, actor, repo_url, created_at, actor_id, repo_id FROM github_partitioned;
+
+INSERT INTO github_event_emails (event_id, email_id)
+SELECT event_id, email_id FROM github_event_emails_partitioned;
+
+DROP TABLE github_event_emails_partitioned;
+DROP TABLE github_partitioned;
+DROP FUNCTION IF EXISTS github_create_partitions(date, date);
+ALTER SEQUENCE github_id_seq OWNED BY github.id;
+
+CREATE INDEX idx_github_created_at ON github(created_at);
+CREATE INDEX idx_github_actor_id ON github(actor_id);
+CREATE INDEX idx_github_repo_id ON github(repo_id);
+CREATE INDEX idx_github_event_emails_email_id ON github_event_emails(email_id);
@@ -0,0 +1,62 @@
+-- Move events into a table range-partitioned by day so that retention can drop
+-- whole partitions instead of deleting rows. The commit email links are
+-- partitioned the same way and dropped together with the events they belong to.
+ALTER TABLE github RENAME TO github_unpartitioned;
+ALTER TABLE github_event_emails RENAME TO github_event_emails_unpartitioned;
+ALTER TABLE github_unpartitioned RENAME CONSTRAINT github_pkey TO github_unpartitioned_pkey;
+ALTER TABLE github_event_emails_unpartitioned RENAME CONSTRAINT github_event_emails_pkey TO github_event_emails_unpartitioned_pkey;
+ALTER SEQUENCE github_id_seq OWNED BY NONE;
+
+CREATE TABLE github (
+    id integer NOT NULL DEFAULT nextval('github_id_seq'),
+    event_type varchar(255),
+    actor varchar(255),
+    repo_url varchar(255),
+    created_at timestamp NOT NULL,
+    actor_id integer REFERENCES github_actors(id),
+    repo_id integer REFERENCES github_repositories(id),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE github_event_emails (
+    event_id integer NOT NULL,
+    created_at timestamp NOT NULL,
+    email_id integer NOT NULL REFERENCES github_emails(id),
+    PRIMARY KEY (event_id, email_id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- Creates the github and github_event_emails partitions for every day in the range
+CREATE OR REPLACE FUNCTION github_create_partitions(from_day date, to_day date) RETURNS void AS $$
+DECLARE
+    day date;
+BEGIN
+    FOR day IN SELECT generate_series(from_day, to_day, interval '1 day')::date LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF github FOR VALUES FROM (%L) TO (%L)',
+            'github_p' || to_char(day, 'YYYYMMDD'), day, day + 1);
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF github_event_emails FOR VALUES FROM (%L) TO (%L)',
+            'github_event_emails_p' || to_char(day, 'YYYYMMDD'), day, day + 1);
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Create partitions for existing data and the coming week, then copy the data over
+SELECT github_create_partitions(
+    LEAST((SELECT MIN(created_at)::date FROM github_unpartitioned), current_date),
+    current_date + 7
+);
+
+INSERT INTO github (id, event_type, actor, repo_url, created_at, actor_id, repo_id)
+SELECT id, event_type, actor, repo_url, created_at, actor_id, repo_id FROM github_unpartitioned;
+
+INSERT INTO github_event_emails (event_id, created_at, email_id)
+SELECT l.event_id, g.created_at, l.email_id
+FROM github_event_emails_unpartitioned l JOIN github_unpartitioned g ON g.id = l.event_id;
+
+DROP TABLE github_event_emails_unpartitioned;
+DROP TABLE github_unpartitioned;
+ALTER SEQUENCE github_id_seq OWNED BY github.id;
+
+CREATE INDEX idx_github_created_at ON github(created_at);
+CREATE INDEX idx_github_actor_id ON github(actor_id);
+CREATE INDEX idx_github_repo_id ON github(repo_id);
+CREATE INDEX idx_github_event_emails_email_id ON github_event_emails(email_id);
@@ -0,0 +1,9 @@
+-- One row per retention purge, recording the policy applied and what was removed
+CREATE TABLE retention_audit (
+    id serial PRIMARY KEY,
+    started_at timestamp NOT NULL,
+    finished_at timestamp NOT NULL DEFAULT now(),
+    total_events bigint NOT NULL,
+    held_events bigint NOT NULL,
+    report jsonb NOT NULL
+);
@@ -0,0 +1,20 @@
+-- Event counts per event type, repository and actor, bucketed by minute, hour
+-- or day. Minute rows are written at ingestion and compacted into coarser
+-- resolutions as they age (see pkg/rollup).
+CREATE TABLE event_rollups (
+    resolution varchar(8) NOT NULL,
+    bucket timestamp NOT NULL,
+    event_type varchar(255) NOT NULL,
+    repo_url varchar(255) NOT NULL,
+    actor varchar(255) NOT NULL,
+    count bigint NOT NULL,
+    PRIMARY KEY (resolution, bucket, event_type, repo_url, actor)
+);
+
+CREATE INDEX idx_event_rollups_bucket ON event_rollups(bucket);
+
+-- Backfill from the events collected so far
+INSERT INTO event_rollups (resolution, bucket, event_type, repo_url, actor, count)
+SELECT 'minute', date_trunc('minute', created_at), COALESCE(event_type, ''), COALESCE(repo_url, ''), COALESCE(actor, ''), COUNT(*)
+FROM github
+GROUP BY 2, 3, 4, 5;
@@ -0,0 +1,14 @@
+-- Encrypted emails cannot be decrypted in SQL, so refuse to lose them
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM github_emails WHERE email IS NULL) THEN
+        RAISE EXCEPTION 'github_emails contains encrypted emails that cannot be reverted';
+    END IF;
+END
+$$;
+
+DROP INDEX github_emails_email_hash_key;
+
+ALTER TABLE github_emails
+    DROP COLUMN email_hash,
+    DROP COLUMN email_encrypted;
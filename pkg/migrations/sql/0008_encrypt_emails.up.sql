@@ -0,0 +1,8 @@
+-- Commit author emails are stored encrypted (email_encrypted) with a keyed
+-- hash (email_hash) for lookups and deduplication. Existing plaintext emails
+-- are encrypted by the service on startup, which then clears the email column.
+ALTER TABLE github_emails
+    ADD COLUMN email_hash bytea,
+    ADD COLUMN email_encrypted text;
+
+CREATE UNIQUE INDEX github_emails_email_hash_key ON github_emails(email_hash);
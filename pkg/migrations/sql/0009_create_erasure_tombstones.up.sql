@@ -0,0 +1,11 @@
+-- Identities erased on request. Only the keyed hash of the login or email is
+-- kept, so ingestion can recognise and scrub the identity without storing it.
+CREATE TABLE erasure_tombstones (
+    id serial PRIMARY KEY,
+    kind varchar(16) NOT NULL,
+    value_hash bytea NOT NULL,
+    pseudonym varchar(64) NOT NULL,
+    erased_at timestamp NOT NULL,
+    report jsonb NOT NULL,
+    UNIQUE (kind, value_hash)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS github_external_id_key;
+
+ALTER TABLE github DROP COLUMN external_id;
@@ -0,0 +1,7 @@
+-- The GitHub event ID, used to store each event once across repeated fetches
+-- and imports. Imported events without an ID are keyed by the fingerprint
+-- computed by models.GitHubEvent.Key. Rows collected before IDs were kept
+-- have no key.
+ALTER TABLE github ADD COLUMN external_id varchar(64);
+
+CREATE UNIQUE INDEX github_external_id_key ON github(external_id, created_at);
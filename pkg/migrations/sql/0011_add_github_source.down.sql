@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_github_actor;
+
+ALTER TABLE github DROP COLUMN source;
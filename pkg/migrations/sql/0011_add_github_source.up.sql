@@ -0,0 +1,5 @@
+-- Where each event came from: 'github' for events fetched from the GitHub API,
+-- 'import' for events loaded from an archive or export
+ALTER TABLE github ADD COLUMN source varchar(16) NOT NULL DEFAULT 'github';
+
+CREATE INDEX idx_github_actor ON github(actor);
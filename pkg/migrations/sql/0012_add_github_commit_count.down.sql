@@ -0,0 +1 @@
+ALTER TABLE github DROP COLUMN commit_count;
@@ -0,0 +1,8 @@
+-- Number of commits pushed by an event. Rows collected before commits were
+-- counted get the number of distinct commit author emails linked to them, a
+-- lower bound.
+ALTER TABLE github ADD COLUMN commit_count integer NOT NULL DEFAULT 0;
+
+UPDATE github g SET commit_count = e.emails
+FROM (SELECT event_id, created_at, COUNT(*) AS emails FROM github_event_emails GROUP BY 1, 2) e
+WHERE e.event_id = g.id AND e.created_at = g.created_at;
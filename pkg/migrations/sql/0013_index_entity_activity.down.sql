@@ -0,0 +1,5 @@
+CREATE INDEX idx_github_actors_last_seen ON github_actors(last_seen);
+CREATE INDEX idx_github_repositories_last_seen ON github_repositories(last_seen);
+
+DROP INDEX idx_github_actors_activity;
+DROP INDEX idx_github_repositories_activity;
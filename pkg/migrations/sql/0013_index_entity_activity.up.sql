@@ -0,0 +1,6 @@
+-- Keyset order of the most recently active actors and repositories
+CREATE INDEX idx_github_actors_activity ON github_actors(last_seen DESC, login);
+CREATE INDEX idx_github_repositories_activity ON github_repositories(last_seen DESC, url);
+
+DROP INDEX idx_github_actors_last_seen;
+DROP INDEX idx_github_repositories_last_seen;
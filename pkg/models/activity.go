@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ActorActivity is an actor with the time of its latest event and its number of events.
+type ActorActivity struct {
+	Login    string    `json:"login"`
+	LastSeen time.Time `json:"last_seen"`
+	Events   int64     `json:"events"`
+}
+
+// RepoActivity is a repository with the time of its latest event and its number of events.
+type RepoActivity struct {
+	URL      string    `json:"url"`
+	LastSeen time.Time `json:"last_seen"`
+	Events   int64     `json:"events"`
+}
+
+// ActorRank is an actor's place on a leaderboard by number of events. Actors
+// with equal counts share a rank.
+type ActorRank struct {
+	Rank   int    `json:"rank"`
+	Login  string `json:"login"`
+	Events int64  `json:"events"`
+}
+
+// RepoRank is a repository's place on a leaderboard by number of events.
+// Repositories with equal counts share a rank.
+type RepoRank struct {
+	Rank   int    `json:"rank"`
+	URL    string `json:"url"`
+	Events int64  `json:"events"`
+}
+
+// BotSuffix ends the logins of GitHub App bot accounts.
+const BotSuffix = "[bot]"
+
+// IsBot reports whether a login belongs to a GitHub App bot account.
+func IsBot(login string) bool {
+	return strings.HasSuffix(login, BotSuffix)
+}
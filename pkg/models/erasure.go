@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Kinds of identity that can be erased
+const (
+	ErasureActor = "actor"
+	ErasureEmail = "email"
+)
+
+// ErasureRequest names the actor login or the commit author email to erase.
+type ErasureRequest struct {
+	Login string `json:"login,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Subject returns the kind and value of the identity to erase. Exactly one
+// of Login and Email must be set. GitHub logins are case-insensitive, so the
+// login is lower-cased.
+func (r ErasureRequest) Subject() (kind, value string, err error) {
+	switch {
+	case r.Login != "" && r.Email != "":
+		return "", "", errors.New("specify either login or email, not both")
+	case r.Login != "":
+		return ErasureActor, strings.ToLower(r.Login), nil
+	case r.Email != "":
+		return ErasureEmail, r.Email, nil
+	default:
+		return "", "", errors.New("login or email is required")
+	}
+}
+
+// ErasureReport describes the rows an erasure removed or pseudonymized. It
+// never contains the erased identity itself.
+type ErasureReport struct {
+	Kind      string    `json:"kind"`
+	ErasedAt  time.Time `json:"erased_at"`
+	Pseudonym string    `json:"pseudonym,omitempty"`
+	// Rows is the number of affected rows per table.
+	Rows      map[string]int64 `json:"rows"`
+	TotalRows int64            `json:"total_rows"`
+	// Retained lists the copies of the identity that the erasure does not rewrite.
+	Retained []string `json:"retained"`
+}
+
+// Add records count affected rows of a table in the report.
+func (r *ErasureReport) Add(table string, count int64) {
+	if r.Rows == nil {
+		r.Rows = make(map[string]int64)
+	}
+	r.Rows[table] += count
+	r.TotalRows += count
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// TimeRange bounds event times to [Since, Until). A zero bound is open.
+type TimeRange struct {
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+}
+
+// Contains reports whether t lies within the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.Since.IsZero() || !t.Before(r.Since)) && (r.Until.IsZero() || t.Before(r.Until))
+}
+
+// EventFilter selects stored events. Zero fields do not filter.
+type EventFilter struct {
+	TimeRange
+	// Types, Repos, Actors and Sources restrict events to any of the given
+	// event types, repository URLs, actor logins and sources.
+	Types   []string `json:"types,omitempty"`
+	Repos   []string `json:"repos,omitempty"`
+	Actors  []string `json:"actors,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Match reports whether an event passes the filter.
+func (f EventFilter) Match(event GitHubEvent) bool {
+	source := event.Source
+	if source == "" {
+		source = SourceGitHub
+	}
+	return f.match(event.CreatedAt, event.Type, event.Repo.URL, event.Actor.Login, source)
+}
+
+// MatchStored reports whether a stored event passes the filter.
+func (f EventFilter) MatchStored(event StoredEvent) bool {
+	return f.match(event.CreatedAt, event.Type, event.Repo, event.Actor, event.Source)
+}
+
+func (f EventFilter) match(createdAt time.Time, eventType, repo, actor, source string) bool {
+	return f.Contains(createdAt) && contains(f.Types, eventType) && contains(f.Repos, repo) &&
+		contains(f.Actors, actor) && contains(f.Sources, source)
+}
+
+// contains reports whether value is in values, treating an empty list as matching everything.
+func contains(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Page requests up to Limit items, which must be positive, following the item whose key is After.
+// Keys are defined by the store serving the list; an empty After starts at
+// the first item.
+type Page struct {
+	Limit int
+	After string
+}
@@ -1,14 +1,53 @@
 package models
 
-import "time"
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
 
 // GitHubEvent represents a GitHub event
 type GitHubEvent struct {
+	ID        string    `json:"id,omitempty"`
 	Type      string    `json:"type"`
 	Actor     Actor     `json:"actor"`
 	Repo      Repo      `json:"repo"`
 	Payload   Payload   `json:"payload"`
 	CreatedAt time.Time `json:"created_at"`
+	// Source is where the event entered the store, SourceGitHub if empty.
+	Source string `json:"source,omitempty"`
+}
+
+// Event sources
+const (
+	SourceGitHub = "github"
+	SourceImport = "import"
+)
+
+// StoredEvent is an event as kept by the store, identified by its store ID.
+type StoredEvent struct {
+	ID        int64     `json:"id"`
+	GitHubID  string    `json:"github_id,omitempty"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	Repo      string    `json:"repo"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source"`
+	// Emails are the commit author emails of the event. API responses only
+	// list them on request.
+	Emails []string `json:"emails,omitempty"`
+}
+
+// Key identifies an event for idempotent imports: its GitHub ID, or for
+// events without one a fingerprint of its type, actor, repository and
+// creation second.
+func (e GitHubEvent) Key() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%s|%s|%s|%d", e.Type, e.Actor.Login, e.Repo.URL, e.CreatedAt.Unix())))
+	return "fp-" + hex.EncodeToString(sum[:])
 }
 
 // Actor represents the actor of a GitHub event
@@ -23,7 +62,20 @@ type Repo struct {
 
 // Payload represents the payload of a GitHub event
 type Payload struct {
+	Action string `json:"action"`
+	// Size is the number of commits of a push, of which Commits lists at most 20.
+	Size    int      `json:"size,omitempty"`
 	Commits []Commit `json:"commits"`
+	Issue   *Issue   `json:"issue,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// CommitCount returns the number of commits of a push.
+func (p Payload) CommitCount() int {
+	if p.Size > len(p.Commits) {
+		return p.Size
+	}
+	return len(p.Commits)
 }
 
 // Commit represents a commit in the payload of a GitHub event
@@ -35,3 +87,47 @@ type Commit struct {
 type Author struct {
 	Email string `json:"email"`
 }
+
+// Issue represents the issue carried by IssuesEvent and IssueCommentEvent payloads
+type Issue struct {
+	Number    int        `json:"number"`
+	User      Actor      `json:"user"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+}
+
+// Comment represents the comment carried by an IssueCommentEvent payload
+type Comment struct {
+	User      Actor     `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IssueUpdate describes the change a single IssuesEvent or IssueCommentEvent
+// makes to the tracked state of an issue.
+type IssueUpdate struct {
+	RepoURL    string
+	Number     int
+	Author     string
+	Action     string // opened, closed, reopened or commented
+	Actor      string
+	OpenedAt   time.Time
+	OccurredAt time.Time
+	// FirstResponse is set when the event is a comment by someone other than the issue author.
+	FirstResponse *time.Time
+}
+
+// IssueMetrics summarizes issue handling for a single repository.
+type IssueMetrics struct {
+	RepoURL                    string   `json:"repo_url"`
+	OpenIssues                 int      `json:"open_issues"`
+	ClosedIssues               int      `json:"closed_issues"`
+	MedianFirstResponseSeconds *float64 `json:"median_first_response_seconds"`
+	MedianTimeToCloseSeconds   *float64 `json:"median_time_to_close_seconds"`
+}
+
+// BacklogPoint is the number of open issues in a repository at the end of a day.
+type BacklogPoint struct {
+	RepoURL    string    `json:"repo_url"`
+	Day        time.Time `json:"day"`
+	OpenIssues int       `json:"open_issues"`
+}
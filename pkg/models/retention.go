@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// RetentionPolicy says how many days events are kept. A repository rule takes
+// precedence over an event type rule, which takes precedence over the default.
+// A value of 0 days keeps matching events forever. Events of repositories under
+// legal hold are never purged.
+type RetentionPolicy struct {
+	DefaultDays int            `json:"default_days"`
+	EventTypes  map[string]int `json:"event_types,omitempty"`
+	Repos       map[string]int `json:"repos,omitempty"`
+	LegalHold   []string       `json:"legal_hold,omitempty"`
+}
+
+// Retention rule names used in purge reports
+const (
+	RuleDefault   = "default"
+	RuleEventType = "event_type"
+	RuleRepo      = "repo"
+	RuleLegalHold = "legal_hold"
+)
+
+// Classify returns the rule that governs an event, the event type or repository
+// it matched and the number of days it is kept. Events of held repositories
+// report RuleLegalHold with the days of the rule they would otherwise fall under.
+func (p RetentionPolicy) Classify(eventType, repoURL string) (rule string, match string, days int) {
+	if d, ok := p.Repos[repoURL]; ok {
+		rule, match, days = RuleRepo, repoURL, d
+	} else if d, ok := p.EventTypes[eventType]; ok {
+		rule, match, days = RuleEventType, eventType, d
+	} else {
+		rule, days = RuleDefault, p.DefaultDays
+	}
+
+	for _, held := range p.LegalHold {
+		if held == repoURL {
+			return RuleLegalHold, repoURL, days
+		}
+	}
+	return rule, match, days
+}
+
+// Cutoff returns the time before which events kept for the given number of
+// days expire, or the zero time if they are kept forever.
+func Cutoff(now time.Time, days int) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// MaxDays returns the longest retention of any rule, or 0 if any rule keeps events forever.
+func (p RetentionPolicy) MaxDays() int {
+	max := p.DefaultDays
+	for _, rules := range []map[string]int{p.EventTypes, p.Repos} {
+		for _, days := range rules {
+			if days > max {
+				max = days
+			}
+			if days == 0 {
+				return 0
+			}
+		}
+	}
+	if p.DefaultDays == 0 {
+		return 0
+	}
+	return max
+}
+
+// PurgeReport describes what a retention run deleted, or would delete in a dry run.
+type PurgeReport struct {
+	StartedAt         time.Time       `json:"started_at"`
+	DryRun            bool            `json:"dry_run"`
+	Policy            RetentionPolicy `json:"policy"`
+	Rules             []PurgeRule     `json:"rules"`
+	TotalEvents       int64           `json:"total_events"`
+	HeldEvents        int64           `json:"held_events"`
+	PartitionsDropped []string        `json:"partitions_dropped,omitempty"`
+	// Archive is the directory the purged events were archived to, if any.
+	Archive string `json:"archive,omitempty"`
+}
+
+// PurgeRule is the number of expired events matched by a single retention rule.
+// For RuleLegalHold the events were expired but kept, and Cutoff is not set.
+type PurgeRule struct {
+	Rule   string     `json:"rule"`
+	Match  string     `json:"match,omitempty"`
+	Cutoff *time.Time `json:"cutoff,omitempty"`
+	Events int64      `json:"events"`
+}
+
+// Add records count expired events for a rule in the report.
+func (r *PurgeReport) Add(rule, match string, days int, count int64) {
+	entry := PurgeRule{Rule: rule, Match: match, Events: count}
+	if rule == RuleLegalHold {
+		r.HeldEvents += count
+	} else {
+		cutoff := Cutoff(r.StartedAt, days)
+		entry.Cutoff = &cutoff
+		r.TotalEvents += count
+	}
+	r.Rules = append(r.Rules, entry)
+}
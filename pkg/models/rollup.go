@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Rollup resolutions, from finest to coarsest
+const (
+	ResolutionMinute = "minute"
+	ResolutionHour   = "hour"
+	ResolutionDay    = "day"
+)
+
+// ResolutionWidths maps each rollup resolution to the width of its buckets.
+var ResolutionWidths = map[string]time.Duration{
+	ResolutionMinute: time.Minute,
+	ResolutionHour:   time.Hour,
+	ResolutionDay:    24 * time.Hour,
+}
+
+// CountPoint is the number of events of one type in a time bucket.
+// Resolution is the coarsest resolution of the rollups counted in it.
+type CountPoint struct {
+	Bucket     time.Time `json:"bucket"`
+	EventType  string    `json:"event_type"`
+	Count      int64     `json:"count"`
+	Resolution string    `json:"resolution"`
+}
+
+// RollupTiers says how long rollups are kept at each resolution. Minute rows
+// older than Minute are compacted into hour rows, hour rows older than Hour
+// into day rows, and day rows older than Day are deleted. A zero Day keeps day
+// rows forever.
+type RollupTiers struct {
+	Minute time.Duration `json:"minute"`
+	Hour   time.Duration `json:"hour"`
+	Day    time.Duration `json:"day"`
+}
+
+// CompactionReport is the number of rollup rows written or deleted by a compaction.
+type CompactionReport struct {
+	HourRows    int64 `json:"hour_rows"`
+	DayRows     int64 `json:"day_rows"`
+	DeletedDays int64 `json:"deleted_days"`
+}
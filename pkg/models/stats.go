@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// RepoStats summarizes the events of a repository in a time window.
+type RepoStats struct {
+	URL          string           `json:"url"`
+	Events       int64            `json:"events"`
+	EventsByType map[string]int64 `json:"events_by_type"`
+	Contributors int64            `json:"contributors"`
+	Commits      int64            `json:"commits"`
+	// FirstActivity and LastActivity are nil without events in the window.
+	FirstActivity *time.Time `json:"first_activity"`
+	LastActivity  *time.Time `json:"last_activity"`
+	// BusiestHours are the UTC hours of the day with the most events, busiest first.
+	BusiestHours []HourCount `json:"busiest_hours"`
+	TopActors    []ActorRank `json:"top_actors"`
+}
+
+// HourCount is the number of events in an hour of the day.
+type HourCount struct {
+	Hour   int   `json:"hour"`
+	Events int64 `json:"events"`
+}
+
+// ActorProfile summarizes the events of an actor in a time window.
+type ActorProfile struct {
+	Login        string           `json:"login"`
+	Events       int64            `json:"events"`
+	EventsByType map[string]int64 `json:"events_by_type"`
+	// FirstSeen and LastSeen are nil without events in the window.
+	FirstSeen *time.Time `json:"first_seen"`
+	LastSeen  *time.Time `json:"last_seen"`
+	// Repos are the repositories the actor had events in, most recent first.
+	Repos []RepoActivity `json:"repos"`
+	// Emails are the commit author emails of the actor's pushes, sorted.
+	Emails []string `json:"emails"`
+}
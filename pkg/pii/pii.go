@@ -0,0 +1,159 @@
+// Package pii encrypts personal data such as commit author emails at rest.
+//
+// Values are sealed with AES-256-GCM under the current key of a Keyring and
+// prefixed with the key id, so older keys can stay in the keyring for
+// decryption while rows are re-encrypted. A separate HMAC-SHA256 key yields a
+// deterministic hash used for equality lookups and deduplication; it does not
+// rotate, since every stored hash would change with it.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the length in bytes of encryption and hash keys.
+const KeySize = 32
+
+// Keyring holds the encryption keys by id and the hash key.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+	hashKey []byte
+}
+
+// LoadKeyring reads the EMAIL_ENCRYPTION_KEYS and EMAIL_HASH_KEY environment
+// variables (see ParseKeyring).
+func LoadKeyring() (*Keyring, error) {
+	return ParseKeyring(os.Getenv("EMAIL_ENCRYPTION_KEYS"), os.Getenv("EMAIL_HASH_KEY"))
+}
+
+// ParseKeyring builds a keyring from a comma separated list of id:base64key
+// encryption keys, the first of which is current, and a base64 hash key.
+// Every key must decode to KeySize bytes.
+func ParseKeyring(encryptionKeys, hashKey string) (*Keyring, error) {
+	if encryptionKeys == "" || hashKey == "" {
+		return nil, errors.New("EMAIL_ENCRYPTION_KEYS and EMAIL_HASH_KEY must be set")
+	}
+
+	k := &Keyring{aeads: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(encryptionKeys, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("encryption key %q must have the form id:base64key", entry)
+		}
+		if _, exists := k.aeads[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+
+	key, err := decodeKey(hashKey)
+	if err != nil {
+		return nil, fmt.Errorf("hash key: %w", err)
+	}
+	k.hashKey = key
+	return k, nil
+}
+
+// decodeKey decodes a base64 key and checks its length.
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("not valid base64")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Encrypt seals a value under the current key as id:base64(nonce|ciphertext).
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.current))
+	return k.current + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt under any key of the keyring.
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	id, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return "", errors.New("malformed ciphertext")
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key %q", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed ciphertext")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decrypting with key %q: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether a ciphertext was sealed under a key other than the current one.
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	return !strings.HasPrefix(ciphertext, k.current+":")
+}
+
+// HasKey reports whether the keyring holds the encryption key with the given id.
+func (k *Keyring) HasKey(id string) bool {
+	_, ok := k.aeads[id]
+	return ok
+}
+
+// CurrentKey returns the id of the key new values are encrypted with.
+func (k *Keyring) CurrentKey() string {
+	return k.current
+}
+
+// Hash returns the keyed HMAC-SHA256 of a value.
+func (k *Keyring) Hash(value string) []byte {
+	mac := hmac.New(sha256.New, k.hashKey)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Pseudonym returns the stable replacement for an erased value: a short,
+// non-reversible token derived from its keyed hash.
+func (k *Keyring) Pseudonym(value string) string {
+	return Pseudonym(k.Hash(value))
+}
+
+// Pseudonym formats a hash as the replacement for an erased value.
+func Pseudonym(hash []byte) string {
+	return "erased-" + hex.EncodeToString(hash[:8])
+}
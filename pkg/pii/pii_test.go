@@ -0,0 +1,74 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, KeySize))
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	k, err := ParseKeyring("v1:"+testKey(1), testKey(9))
+	if err != nil {
+		t.Fatalf("Error parsing keyring: %v", err)
+	}
+
+	a, _ := k.Encrypt("alice@example.com")
+	b, _ := k.Encrypt("alice@example.com")
+	if a == b || strings.Contains(a, "alice") || !strings.HasPrefix(a, "v1:") {
+		t.Errorf("Expected distinct, opaque ciphertexts under v1, got %q and %q", a, b)
+	}
+	if plaintext, err := k.Decrypt(a); err != nil || plaintext != "alice@example.com" {
+		t.Errorf("Expected the email back, got %q (err=%v)", plaintext, err)
+	}
+
+	// Hashes are deterministic for lookups and depend on the hash key
+	other, _ := ParseKeyring("v1:"+testKey(1), testKey(8))
+	if !bytes.Equal(k.Hash("alice@example.com"), k.Hash("alice@example.com")) || bytes.Equal(k.Hash("alice@example.com"), other.Hash("alice@example.com")) {
+		t.Errorf("Expected a deterministic keyed hash")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, _ := ParseKeyring("v1:"+testKey(1), testKey(9))
+	sealed, _ := old.Encrypt("bob@example.com")
+
+	rotated, err := ParseKeyring("v2:"+testKey(2)+",v1:"+testKey(1), testKey(9))
+	if err != nil {
+		t.Fatalf("Error parsing keyring: %v", err)
+	}
+	if !rotated.NeedsRotation(sealed) {
+		t.Errorf("Expected a v1 ciphertext to need rotation")
+	}
+	if plaintext, err := rotated.Decrypt(sealed); err != nil || plaintext != "bob@example.com" {
+		t.Errorf("Expected old ciphertexts to stay readable, got %q (err=%v)", plaintext, err)
+	}
+	resealed, _ := rotated.Encrypt("bob@example.com")
+	if rotated.NeedsRotation(resealed) {
+		t.Errorf("Expected new ciphertexts under the current key, got %q", resealed)
+	}
+
+	// Dropping the old key makes its ciphertexts unreadable
+	retired, _ := ParseKeyring("v2:"+testKey(2), testKey(9))
+	if _, err := retired.Decrypt(sealed); err == nil {
+		t.Errorf("Expected an error decrypting with a retired key")
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	for _, tc := range []struct{ keys, hash string }{
+		{"", testKey(9)},
+		{"v1:" + testKey(1), ""},
+		{testKey(1), testKey(9)},
+		{"v1:short", testKey(9)},
+		{"v1:" + testKey(1) + ",v1:" + testKey(2), testKey(9)},
+	} {
+		if _, err := ParseKeyring(tc.keys, tc.hash); err == nil {
+			t.Errorf("Expected an error for keys %q hash %q", tc.keys, tc.hash)
+		}
+	}
+}
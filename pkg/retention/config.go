@@ -0,0 +1,87 @@
+package retention
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config controls the scheduled retention job.
+type Config struct {
+	Policy   models.RetentionPolicy
+	Interval time.Duration
+	// DryRun makes scheduled runs only log what they would delete.
+	DryRun bool
+	// ArchiveDir, if set, is where expiring events are archived before they are purged.
+	ArchiveDir string
+}
+
+// fileConfig is the JSON layout of a retention config file, for example:
+//
+//	{
+//	  "default_days": 2,
+//	  "event_types": {"WatchEvent": 1},
+//	  "repos": {"https://api.github.com/repos/octo/important": 30},
+//	  "legal_hold": ["https://api.github.com/repos/octo/litigation"],
+//	  "interval": "1h",
+//	  "dry_run": false,
+//	  "archive_dir": "/var/lib/github-events/archive"
+//	}
+type fileConfig struct {
+	models.RetentionPolicy
+	Interval   string `json:"interval"`
+	DryRun     bool   `json:"dry_run"`
+	ArchiveDir string `json:"archive_dir"`
+}
+
+// DefaultConfig keeps events for two days and purges hourly.
+func DefaultConfig() Config {
+	return Config{
+		Policy:   models.RetentionPolicy{DefaultDays: 2},
+		Interval: time.Hour,
+	}
+}
+
+// LoadConfig reads the retention config file named by the RETENTION_CONFIG
+// environment variable, or returns DefaultConfig if it is not set.
+func LoadConfig() (Config, error) {
+	path := os.Getenv("RETENTION_CONFIG")
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses and validates a JSON retention config. Omitted fields take
+// their values from DefaultConfig.
+func ParseConfig(data []byte) (Config, error) {
+	defaults := DefaultConfig()
+	fc := fileConfig{RetentionPolicy: defaults.Policy, Interval: defaults.Interval.String()}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return Config{}, fmt.Errorf("parsing retention config: %w", err)
+	}
+
+	interval, err := time.ParseDuration(fc.Interval)
+	if err != nil || interval <= 0 {
+		return Config{}, fmt.Errorf("retention config: invalid interval %q", fc.Interval)
+	}
+	if fc.DefaultDays < 0 {
+		return Config{}, fmt.Errorf("retention config: default_days must not be negative")
+	}
+	for name, rules := range map[string]map[string]int{"event_types": fc.EventTypes, "repos": fc.Repos} {
+		for key, days := range rules {
+			if days < 0 {
+				return Config{}, fmt.Errorf("retention config: %s[%q] must not be negative", name, key)
+			}
+		}
+	}
+
+	return Config{Policy: fc.RetentionPolicy, Interval: interval, DryRun: fc.DryRun, ArchiveDir: fc.ArchiveDir}, nil
+}
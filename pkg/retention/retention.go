@@ -0,0 +1,80 @@
+// Package retention runs the scheduled job that purges expired events
+// according to a configurable retention policy.
+package retention
+
+import (
+	"awsomeProject/pkg/archive"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"awsomeProject/pkg/store"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Job purges expired events from a store.
+type Job struct {
+	store  store.EventStore
+	config Config
+	keys   *pii.Keyring
+}
+
+// NewJob returns a retention job for the given store and config. keys
+// encrypts the commit author emails of archives.
+func NewJob(eventStore store.EventStore, config Config, keys *pii.Keyring) *Job {
+	return &Job{store: eventStore, config: config, keys: keys}
+}
+
+// Run applies the retention policy once. When an archive directory is
+// configured, expiring events are archived first and nothing is purged if
+// archiving fails. Real runs are recorded in the audit log; dry runs only
+// report what would have been deleted.
+func (j *Job) Run(dryRun bool) (models.PurgeReport, error) {
+	now := time.Now()
+
+	var archiveDir string
+	if j.config.ArchiveDir != "" && !dryRun {
+		expired, err := j.store.ExpiredEvents(j.config.Policy, now)
+		if err != nil {
+			return models.PurgeReport{}, err
+		}
+		if len(expired) > 0 {
+			dir, manifest, err := archive.Write(j.config.ArchiveDir, now, expired, j.keys)
+			if err != nil {
+				return models.PurgeReport{}, fmt.Errorf("archiving expired events: %w", err)
+			}
+			log.Printf("Retention: archived %d events to %s", manifest.Events, dir)
+			archiveDir = dir
+		}
+	}
+
+	report, err := j.store.Purge(j.config.Policy, now, dryRun)
+	report.Archive = archiveDir
+	if err != nil {
+		return report, err
+	}
+
+	if dryRun {
+		log.Printf("Retention dry run: %d events would be purged, %d held", report.TotalEvents, report.HeldEvents)
+		return report, nil
+	}
+
+	log.Printf("Retention: purged %d events, %d held, %d partitions dropped", report.TotalEvents, report.HeldEvents, len(report.PartitionsDropped))
+	return report, j.store.RecordPurge(report)
+}
+
+// Start runs the job now and then every configured interval in the background.
+func (j *Job) Start() {
+	run := func() {
+		if _, err := j.Run(j.config.DryRun); err != nil {
+			log.Printf("Error applying retention policy: %v", err)
+		}
+	}
+
+	run()
+	go func() {
+		for range time.Tick(j.config.Interval) {
+			run()
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package retention
+
+import (
+	"awsomeProject/pkg/archive"
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"awsomeProject/pkg/store"
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"event_types": {"WatchEvent": 1}, "legal_hold": ["repo"], "interval": "30m"}`))
+	if err != nil {
+		t.Fatalf("Error parsing config: %v", err)
+	}
+	if config.Policy.DefaultDays != 2 || config.Policy.EventTypes["WatchEvent"] != 1 || config.Interval != 30*time.Minute {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	for _, invalid := range []string{`{"interval": "soon"}`, `{"default_days": -1}`, `{"repos": {"repo": -3}}`, `not json`} {
+		if _, err := ParseConfig([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestJobRun(t *testing.T) {
+	eventStore := store.NewMemoryStore()
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", CreatedAt: time.Now().AddDate(0, 0, -3)})
+	job := NewJob(eventStore, DefaultConfig(), nil)
+
+	// A dry run is not audited
+	if report, err := job.Run(true); err != nil || report.TotalEvents != 1 {
+		t.Fatalf("Expected a dry run to report 1 event, got %+v (err=%v)", report, err)
+	}
+	if len(eventStore.Purges()) != 0 {
+		t.Errorf("Expected no audit record for a dry run")
+	}
+
+	// A real run purges and is audited
+	if report, err := job.Run(false); err != nil || report.TotalEvents != 1 {
+		t.Fatalf("Expected 1 event to be purged, got %+v (err=%v)", report, err)
+	}
+	if purges := eventStore.Purges(); len(purges) != 1 || purges[0].TotalEvents != 1 {
+		t.Errorf("Expected one audit record, got %+v", purges)
+	}
+}
+
+func TestJobRunArchivesBeforePurging(t *testing.T) {
+	eventStore := store.NewMemoryStore()
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", CreatedAt: time.Now().AddDate(0, 0, -3)})
+	eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", CreatedAt: time.Now()})
+	config := DefaultConfig()
+	config.ArchiveDir = t.TempDir()
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, pii.KeySize))
+	keys, err := pii.ParseKeyring("v1:"+key, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := NewJob(eventStore, config, keys).Run(false)
+	if err != nil {
+		t.Fatalf("Error running retention: %v", err)
+	}
+	if report.Archive == "" {
+		t.Fatal("Expected the report to name the archive")
+	}
+
+	// The archive holds exactly the purged event
+	manifest, err := archive.ReadManifest(report.Archive)
+	if err != nil || manifest.Events != 1 || report.TotalEvents != 1 {
+		t.Errorf("Expected 1 archived and purged event, got manifest %+v, report %+v (err=%v)", manifest, report, err)
+	}
+}
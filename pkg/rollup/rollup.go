@@ -0,0 +1,100 @@
+// Package rollup runs the scheduled job that downsamples event count rollups
+// from minute to hour to day resolution as they age.
+package rollup
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Config controls the rollup compaction job.
+type Config struct {
+	Tiers    models.RollupTiers
+	Interval time.Duration
+}
+
+// DefaultConfig keeps minute rollups for a day, hour rollups for 30 days and
+// day rollups forever, compacting every 10 minutes.
+func DefaultConfig() Config {
+	return Config{
+		Tiers:    models.RollupTiers{Minute: 24 * time.Hour, Hour: 30 * 24 * time.Hour},
+		Interval: 10 * time.Minute,
+	}
+}
+
+// LoadConfig overrides DefaultConfig with the ROLLUP_MINUTE_RETENTION,
+// ROLLUP_HOUR_RETENTION, ROLLUP_DAY_RETENTION and ROLLUP_COMPACT_INTERVAL
+// environment variables, each a Go duration such as "36h".
+func LoadConfig() (Config, error) {
+	config := DefaultConfig()
+	for name, target := range map[string]*time.Duration{
+		"ROLLUP_MINUTE_RETENTION": &config.Tiers.Minute,
+		"ROLLUP_HOUR_RETENTION":   &config.Tiers.Hour,
+		"ROLLUP_DAY_RETENTION":    &config.Tiers.Day,
+		"ROLLUP_COMPACT_INTERVAL": &config.Interval,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("%s: invalid duration %q", name, value)
+		}
+		*target = d
+	}
+
+	if config.Interval <= 0 {
+		return Config{}, fmt.Errorf("ROLLUP_COMPACT_INTERVAL must be positive")
+	}
+	if config.Tiers.Hour < config.Tiers.Minute {
+		return Config{}, fmt.Errorf("ROLLUP_HOUR_RETENTION must not be shorter than ROLLUP_MINUTE_RETENTION")
+	}
+	if config.Tiers.Day != 0 && config.Tiers.Day < config.Tiers.Hour {
+		return Config{}, fmt.Errorf("ROLLUP_DAY_RETENTION must not be shorter than ROLLUP_HOUR_RETENTION")
+	}
+	return config, nil
+}
+
+// Job compacts the rollups of a store.
+type Job struct {
+	store  store.EventStore
+	config Config
+}
+
+// NewJob returns a compaction job for the given store and config.
+func NewJob(eventStore store.EventStore, config Config) *Job {
+	return &Job{store: eventStore, config: config}
+}
+
+// Run compacts the rollups once.
+func (j *Job) Run() (models.CompactionReport, error) {
+	report, err := j.store.CompactRollups(j.config.Tiers, time.Now())
+	if err != nil {
+		return report, err
+	}
+	if report.HourRows+report.DayRows+report.DeletedDays > 0 {
+		log.Printf("Rollups: wrote %d hour rows and %d day rows, deleted %d day rows", report.HourRows, report.DayRows, report.DeletedDays)
+	}
+	return report, nil
+}
+
+// Start runs the job now and then every configured interval in the background.
+func (j *Job) Start() {
+	run := func() {
+		if _, err := j.Run(); err != nil {
+			log.Printf("Error compacting rollups: %v", err)
+		}
+	}
+
+	run()
+	go func() {
+		for range time.Tick(j.config.Interval) {
+			run()
+		}
+	}()
+}
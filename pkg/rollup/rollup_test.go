@@ -0,0 +1,61 @@
+package rollup
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/store"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("ROLLUP_MINUTE_RETENTION", "2h")
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if config.Tiers.Minute != 2*time.Hour || config.Tiers.Hour != DefaultConfig().Tiers.Hour {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	t.Setenv("ROLLUP_HOUR_RETENTION", "1h")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("Expected an error for an hour tier shorter than the minute tier")
+	}
+}
+
+func TestJobRunKeepsCounts(t *testing.T) {
+	eventStore := store.NewMemoryStore()
+	now := time.Now()
+	for _, age := range []time.Duration{time.Minute, 3 * time.Hour, 3*time.Hour + time.Minute, 60 * 24 * time.Hour} {
+		eventStore.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: now.Add(-age)})
+	}
+	config := Config{Tiers: models.RollupTiers{Minute: time.Hour, Hour: 24 * time.Hour}, Interval: time.Minute}
+
+	report, err := NewJob(eventStore, config).Run()
+	if err != nil {
+		t.Fatalf("Error compacting rollups: %v", err)
+	}
+	if report.HourRows == 0 || report.DayRows == 0 {
+		t.Errorf("Expected hour and day rows to be written, got %+v", report)
+	}
+
+	// Compaction changes resolution, not totals
+	counts, _ := eventStore.EventCounts(models.TimeRange{})
+	if !reflect.DeepEqual(counts, map[string]int{"PushEvent": 4}) {
+		t.Errorf("Expected counts to survive compaction, got %v", counts)
+	}
+
+	// Daily series are unaffected by compaction too
+	var total int64
+	points, _ := eventStore.EventTimeseries(models.ResolutionDay, models.EventFilter{})
+	for _, point := range points {
+		total += point.Count
+		if !point.Bucket.Equal(point.Bucket.Truncate(24 * time.Hour)) {
+			t.Errorf("Expected day buckets, got %v", point.Bucket)
+		}
+	}
+	if total != 4 {
+		t.Errorf("Expected 4 events in the daily series, got %v", points)
+	}
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a Page.After that is not a key of the list.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// activity is the latest event time and event count in a window of an actor
+// or repository. Activities are ordered by lastSeen.
+type activity struct {
+	value    string
+	lastSeen time.Time
+	events   int64
+}
+
+// activityKey is the keyset position of an activity in the most recent first
+// order: its latest event time and value.
+func activityKey(a activity) string {
+	return a.lastSeen.UTC().Format(time.RFC3339Nano) + "|" + a.value
+}
+
+// parseActivityKey splits a key made by activityKey.
+func parseActivityKey(key string) (time.Time, string, error) {
+	at, value, ok := strings.Cut(key, "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	lastSeen, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return lastSeen, value, nil
+}
+
+// before reports whether a comes before b, most recently seen first with ties broken by value.
+func (a activity) before(b activity) bool {
+	if !a.lastSeen.Equal(b.lastSeen) {
+		return a.lastSeen.After(b.lastSeen)
+	}
+	return a.value < b.value
+}
+
+// activityPage cuts activities, fetched with one more than the page limit, to
+// the page and returns the key of its last entry if another page follows.
+func activityPage(activities []activity, limit int) ([]activity, string) {
+	if len(activities) <= limit {
+		return activities, ""
+	}
+	activities = activities[:limit]
+	return activities, activityKey(activities[limit-1])
+}
+
+// recentActivity returns a page of the rows of an actor or repository table,
+// identified by column, with an event in the window, most recently active in
+// the window first. It aggregates the events in the window, linked through fk
+// of github, rather than following the last_seen column of the table, which
+// purges and the end of the window do not move back.
+func (s *PostgresStore) recentActivity(table, column, fk string, window models.TimeRange, page models.Page) ([]activity, string, error) {
+	var afterSeen interface{}
+	var afterValue string
+	if page.After != "" {
+		latest, value, err := parseActivityKey(page.After)
+		if err != nil {
+			return nil, "", err
+		}
+		afterSeen, afterValue = latest, value
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT e.%[2]s, w.last_seen, w.events
+		FROM (
+			SELECT g.%[3]s AS id, MAX(g.created_at) AS last_seen, COUNT(*) AS events
+			FROM github g WHERE %[4]s
+			GROUP BY g.%[3]s
+		) w
+		JOIN %[1]s e ON e.id = w.id
+		WHERE $3::timestamp IS NULL OR w.last_seen < $3 OR (w.last_seen = $3 AND e.%[2]s > $4)
+		ORDER BY w.last_seen DESC, e.%[2]s
+		LIMIT $5`, table, column, fk, fmt.Sprintf(inWindow, "g.created_at")),
+		append(windowArgs(window), afterSeen, afterValue, page.Limit+1)...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var activities []activity
+	for rows.Next() {
+		var a activity
+		if err := rows.Scan(&a.value, &a.lastSeen, &a.events); err != nil {
+			return nil, "", err
+		}
+		activities = append(activities, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	activities, next := activityPage(activities, page.Limit)
+	return activities, next, nil
+}
+
+// UniqueActors returns a page of the actors with an event in the window, most recently active first.
+func (s *PostgresStore) UniqueActors(window models.TimeRange, page models.Page) ([]models.ActorActivity, string, error) {
+	activities, next, err := s.recentActivity("github_actors", "login", "actor_id", window, page)
+	return actorActivities(activities), next, err
+}
+
+// UniqueRepoURLs returns a page of the repositories with an event in the window, most recently active first.
+func (s *PostgresStore) UniqueRepoURLs(window models.TimeRange, page models.Page) ([]models.RepoActivity, string, error) {
+	activities, next, err := s.recentActivity("github_repositories", "url", "repo_id", window, page)
+	return repoActivities(activities), next, err
+}
+
+// recentActivity returns a page of the values extracted by ref from the events
+// in the window, most recently active in the window first.
+func (s *MemoryStore) recentActivity(ref func(models.GitHubEvent) string, window models.TimeRange, page models.Page) ([]activity, string, error) {
+	var after activity
+	if page.After != "" {
+		latest, value, err := parseActivityKey(page.After)
+		if err != nil {
+			return nil, "", err
+		}
+		after = activity{value: value, lastSeen: latest}
+	}
+
+	s.mu.RLock()
+	byValue := make(map[string]*activity)
+	for _, event := range s.events {
+		if !window.Contains(event.CreatedAt) {
+			continue
+		}
+		value := ref(event.GitHubEvent)
+		a, ok := byValue[value]
+		if !ok {
+			a = &activity{value: value}
+			byValue[value] = a
+		}
+		if event.CreatedAt.After(a.lastSeen) {
+			a.lastSeen = event.CreatedAt
+		}
+		a.events++
+	}
+	s.mu.RUnlock()
+
+	var activities []activity
+	for _, a := range byValue {
+		if page.After == "" || after.before(*a) {
+			activities = append(activities, *a)
+		}
+	}
+	sort.Slice(activities, func(i, j int) bool { return activities[i].before(activities[j]) })
+	if len(activities) > page.Limit+1 {
+		activities = activities[:page.Limit+1]
+	}
+	activities, next := activityPage(activities, page.Limit)
+	return activities, next, nil
+}
+
+// UniqueActors returns a page of the actors with an event in the window, most recently active first.
+func (s *MemoryStore) UniqueActors(window models.TimeRange, page models.Page) ([]models.ActorActivity, string, error) {
+	activities, next, err := s.recentActivity(func(event models.GitHubEvent) string { return event.Actor.Login }, window, page)
+	return actorActivities(activities), next, err
+}
+
+// UniqueRepoURLs returns a page of the repositories with an event in the window, most recently active first.
+func (s *MemoryStore) UniqueRepoURLs(window models.TimeRange, page models.Page) ([]models.RepoActivity, string, error) {
+	activities, next, err := s.recentActivity(func(event models.GitHubEvent) string { return event.Repo.URL }, window, page)
+	return repoActivities(activities), next, err
+}
+
+// actorActivities converts activities of actor logins.
+func actorActivities(activities []activity) []models.ActorActivity {
+	actors := make([]models.ActorActivity, 0, len(activities))
+	for _, a := range activities {
+		actors = append(actors, models.ActorActivity{Login: a.value, LastSeen: a.lastSeen, Events: a.events})
+	}
+	return actors
+}
+
+// repoActivities converts activities of repository URLs.
+func repoActivities(activities []activity) []models.RepoActivity {
+	repos := make([]models.RepoActivity, 0, len(activities))
+	for _, a := range activities {
+		repos = append(repos, models.RepoActivity{URL: a.value, LastSeen: a.lastSeen, Events: a.events})
+	}
+	return repos
+}
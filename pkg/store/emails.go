@@ -0,0 +1,102 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+)
+
+// upsertEmail inserts a commit author email into github_emails, encrypted and
+// deduplicated by its keyed hash, widens its first_seen/last_seen range to
+// include seenAt and returns its id.
+func (s *PostgresStore) upsertEmail(tx *sql.Tx, email string, seenAt time.Time) (int64, error) {
+	encrypted, err := s.keys.Encrypt(email)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRow(`INSERT INTO github_emails (email_hash, email_encrypted, first_seen, last_seen) VALUES ($1, $2, $3, $3)
+		ON CONFLICT (email_hash) DO UPDATE SET
+			first_seen = LEAST(github_emails.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(github_emails.last_seen, EXCLUDED.last_seen)
+		RETURNING id`, s.keys.Hash(email), encrypted, seenAt).Scan(&id)
+	return id, err
+}
+
+// UniqueEmails returns the decrypted commit author emails in the github_emails
+// table linked to an event in the window.
+func (s *PostgresStore) UniqueEmails(window models.TimeRange) ([]string, error) {
+	encrypted, err := s.distinct("SELECT email_encrypted FROM github_emails m WHERE email_encrypted IS NOT NULL AND "+
+		activeIn("github_event_emails", "email_id", "m.id"), windowArgs(window)...)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make([]string, 0, len(encrypted))
+	for _, value := range encrypted {
+		email, err := s.keys.Decrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return emails, nil
+}
+
+// ReencryptEmails encrypts and hashes emails still stored in plaintext and
+// re-encrypts those sealed under a key other than the current one, clearing
+// the plaintext column. It returns the number of rows rewritten.
+func (s *PostgresStore) ReencryptEmails() (int, error) {
+	rows, err := s.db.Query(`
+		SELECT id, email, email_encrypted FROM github_emails
+		WHERE email IS NOT NULL OR email_encrypted NOT LIKE $1 || ':%'`, s.keys.CurrentKey())
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		id    int64
+		email string
+	}
+	var updates []pending
+	for rows.Next() {
+		var id int64
+		var plaintext, encrypted sql.NullString
+		if err := rows.Scan(&id, &plaintext, &encrypted); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		email := plaintext.String
+		if !plaintext.Valid {
+			if email, err = s.keys.Decrypt(encrypted.String); err != nil {
+				rows.Close()
+				return 0, err
+			}
+		}
+		updates = append(updates, pending{id, email})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, update := range updates {
+		encrypted, err := s.keys.Encrypt(update.email)
+		if err != nil {
+			return i, err
+		}
+		_, err = s.db.Exec("UPDATE github_emails SET email = NULL, email_hash = $2, email_encrypted = $3 WHERE id = $1",
+			update.id, s.keys.Hash(update.email), encrypted)
+		if err != nil {
+			return i, err
+		}
+	}
+	if len(updates) > 0 {
+		log.Printf("Encrypted %d emails with key %s", len(updates), s.keys.CurrentKey())
+	}
+	return len(updates), nil
+}
@@ -0,0 +1,303 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// scrubEvent returns a copy of an event with an erased actor login replaced by
+// its pseudonym and erased commit author emails removed.
+func scrubEvent(event models.GitHubEvent, erasedLogin func(string) (string, bool), erasedEmail func(string) bool) models.GitHubEvent {
+	if pseudonym, ok := erasedLogin(event.Actor.Login); ok {
+		event.Actor.Login = pseudonym
+	}
+	commits := make([]models.Commit, 0, len(event.Payload.Commits))
+	for _, commit := range event.Payload.Commits {
+		if commit.Author.Email != "" && erasedEmail(commit.Author.Email) {
+			commit.Author.Email = ""
+		}
+		commits = append(commits, commit)
+	}
+	if event.Payload.Commits != nil {
+		event.Payload.Commits = commits
+	}
+	return event
+}
+
+// scrubIssueUpdate replaces erased logins in an issue update by their pseudonyms.
+func scrubIssueUpdate(update models.IssueUpdate, erasedLogin func(string) (string, bool)) models.IssueUpdate {
+	if pseudonym, ok := erasedLogin(update.Author); ok {
+		update.Author = pseudonym
+	}
+	if pseudonym, ok := erasedLogin(update.Actor); ok {
+		update.Actor = pseudonym
+	}
+	return update
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tombstones returns the keyed hashes among those of values that have an
+// erasure tombstone of the given kind. Logins are hashed lower-cased.
+func (s *PostgresStore) tombstones(q queryer, kind string, values ...string) (map[string]bool, error) {
+	var hashes [][]byte
+	for _, value := range values {
+		if value != "" {
+			hashes = append(hashes, s.keys.Hash(erasureValue(kind, value)))
+		}
+	}
+	erased := make(map[string]bool)
+	if len(hashes) == 0 {
+		return erased, nil
+	}
+
+	rows, err := q.Query("SELECT value_hash FROM erasure_tombstones WHERE kind = $1 AND value_hash = ANY($2)", kind, pq.ByteaArray(hashes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		erased[string(hash)] = true
+	}
+	return erased, rows.Err()
+}
+
+// erasureValue returns the form of an identity that is hashed and matched:
+// logins are case-insensitive on GitHub and are lower-cased.
+func erasureValue(kind, value string) string {
+	if kind == models.ErasureActor {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// erasureRetained lists the copies of an identity of the given kind that an
+// erasure leaves in place.
+func erasureRetained(kind string) []string {
+	retained := []string{"retention archives and exports already written"}
+	if kind == models.ErasureActor {
+		retained = append(retained, "repository URLs containing the login")
+	}
+	return retained
+}
+
+// erasedLogins returns a lookup of the given logins that have been erased.
+func (s *PostgresStore) erasedLogins(q queryer, logins ...string) (func(string) (string, bool), error) {
+	erased, err := s.tombstones(q, models.ErasureActor, logins...)
+	if err != nil {
+		return nil, err
+	}
+	return func(login string) (string, bool) {
+		login = strings.ToLower(login)
+		if login == "" || !erased[string(s.keys.Hash(login))] {
+			return "", false
+		}
+		return s.keys.Pseudonym(login), true
+	}, nil
+}
+
+// scrubEvent applies the erasure tombstones to an event about to be stored.
+func (s *PostgresStore) scrubEvent(q queryer, event models.GitHubEvent) (models.GitHubEvent, error) {
+	erasedLogin, err := s.erasedLogins(q, event.Actor.Login)
+	if err != nil {
+		return event, err
+	}
+	var emails []string
+	for _, commit := range event.Payload.Commits {
+		emails = append(emails, commit.Author.Email)
+	}
+	erased, err := s.tombstones(q, models.ErasureEmail, emails...)
+	if err != nil {
+		return event, err
+	}
+	return scrubEvent(event, erasedLogin, func(email string) bool { return erased[string(s.keys.Hash(email))] }), nil
+}
+
+// Erase pseudonymizes an actor login or removes a commit author email across
+// the events, entity, issue and rollup tables, and records a tombstone so the
+// identity is scrubbed from future ingestion. Actor rows are replaced by a
+// pseudonym rather than deleted so counts and issue metrics stay intact.
+// Logins match regardless of case.
+func (s *PostgresStore) Erase(request models.ErasureRequest, now time.Time) (models.ErasureReport, error) {
+	kind, value, err := request.Subject()
+	if err != nil {
+		return models.ErasureReport{}, err
+	}
+	report := models.ErasureReport{Kind: kind, ErasedAt: now, Rows: make(map[string]int64), Retained: erasureRetained(kind)}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	exec := func(table, query string, args ...interface{}) error {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if table != "" {
+			report.Add(table, n)
+		}
+		return nil
+	}
+
+	hash := s.keys.Hash(value)
+	switch kind {
+	case models.ErasureActor:
+		pseudonym := s.keys.Pseudonym(value)
+		report.Pseudonym = pseudonym
+		for _, step := range []struct{ table, query string }{
+			// Merge the actor, in every case it was seen in, into its pseudonym
+			// before repointing its events
+			{"", `INSERT INTO github_actors (login, first_seen, last_seen)
+				SELECT $2, min(first_seen), max(last_seen) FROM github_actors WHERE lower(login) = $1
+				HAVING count(*) > 0
+				ON CONFLICT (login) DO UPDATE SET
+					first_seen = LEAST(github_actors.first_seen, EXCLUDED.first_seen),
+					last_seen = GREATEST(github_actors.last_seen, EXCLUDED.last_seen)`},
+			{"github", "UPDATE github SET actor = $2, actor_id = (SELECT id FROM github_actors WHERE login = $2) WHERE lower(actor) = $1"},
+			{"github_actors", "DELETE FROM github_actors WHERE lower(login) = $1 AND login <> $2"},
+			{"github_issues", "UPDATE github_issues SET author = $2 WHERE lower(author) = $1"},
+			{"github_issue_transitions", "UPDATE github_issue_transitions SET actor = $2 WHERE lower(actor) = $1"},
+			{"event_rollups", `WITH moved AS (
+					DELETE FROM event_rollups WHERE lower(actor) = $1
+					RETURNING resolution, bucket, event_type, repo_url, count
+				)
+				INSERT INTO event_rollups (resolution, bucket, event_type, repo_url, actor, count)
+				SELECT resolution, bucket, event_type, repo_url, $2, sum(count) FROM moved
+				GROUP BY resolution, bucket, event_type, repo_url
+				ON CONFLICT (resolution, bucket, event_type, repo_url, actor) DO UPDATE SET
+					count = event_rollups.count + EXCLUDED.count`},
+		} {
+			if err := exec(step.table, step.query, value, pseudonym); err != nil {
+				return report, err
+			}
+		}
+	case models.ErasureEmail:
+		if err := exec("github_event_emails", `DELETE FROM github_event_emails WHERE email_id IN (
+			SELECT id FROM github_emails WHERE email_hash = $1 OR email = $2)`, hash, value); err != nil {
+			return report, err
+		}
+		if err := exec("github_emails", "DELETE FROM github_emails WHERE email_hash = $1 OR email = $2", hash, value); err != nil {
+			return report, err
+		}
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return report, err
+	}
+	_, err = tx.Exec(`INSERT INTO erasure_tombstones (kind, value_hash, pseudonym, erased_at, report) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (kind, value_hash) DO UPDATE SET erased_at = EXCLUDED.erased_at, report = EXCLUDED.report`,
+		kind, hash, report.Pseudonym, now, data)
+	if err != nil {
+		return report, err
+	}
+	return report, tx.Commit()
+}
+
+// Erase pseudonymizes an actor login or removes a commit author email from
+// the stored events, entities, issues and rollups and remembers the identity
+// so it is scrubbed from events stored later.
+func (s *MemoryStore) Erase(request models.ErasureRequest, now time.Time) (models.ErasureReport, error) {
+	kind, value, err := request.Subject()
+	if err != nil {
+		return models.ErasureReport{}, err
+	}
+	report := models.ErasureReport{Kind: kind, ErasedAt: now, Rows: make(map[string]int64), Retained: erasureRetained(kind)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tombstones[kind+":"+value] = true
+
+	switch kind {
+	case models.ErasureActor:
+		pseudonym := memoryPseudonym(value)
+		report.Pseudonym = pseudonym
+		for i, event := range s.events {
+			if strings.ToLower(event.Actor.Login) == value {
+				s.events[i].Actor.Login = pseudonym
+				report.Add("github", 1)
+			}
+		}
+		for login, e := range s.actors {
+			if strings.ToLower(login) == value && login != pseudonym {
+				delete(s.actors, login)
+				seen(s.actors, pseudonym, e.firstSeen)
+				seen(s.actors, pseudonym, e.lastSeen)
+				report.Add("github_actors", 1)
+			}
+		}
+		for _, issue := range s.issues {
+			if strings.ToLower(issue.author) == value {
+				issue.author = pseudonym
+				report.Add("github_issues", 1)
+			}
+		}
+		for i := range s.transitions {
+			if strings.ToLower(s.transitions[i].Actor) == value {
+				s.transitions[i].Actor = pseudonym
+				report.Add("github_issue_transitions", 1)
+			}
+		}
+		for key, count := range s.rollups {
+			if strings.ToLower(key.actor) == value && key.actor != pseudonym {
+				delete(s.rollups, key)
+				key.actor = pseudonym
+				s.rollups[key] += count
+				report.Add("event_rollups", 1)
+			}
+		}
+	case models.ErasureEmail:
+		for i, event := range s.events {
+			scrubbed := scrubEvent(event.GitHubEvent, noErasedLogins, func(email string) bool { return email == value })
+			for j, commit := range event.Payload.Commits {
+				if commit.Author.Email != scrubbed.Payload.Commits[j].Author.Email {
+					report.Add("github_event_emails", 1)
+				}
+			}
+			s.events[i].GitHubEvent = scrubbed
+		}
+		if _, ok := s.emails[value]; ok {
+			delete(s.emails, value)
+			report.Add("github_emails", 1)
+		}
+	}
+	return report, nil
+}
+
+// erasedLogin looks up a login among the erasure tombstones. The caller must hold the lock.
+func (s *MemoryStore) erasedLogin(login string) (string, bool) {
+	login = strings.ToLower(login)
+	if login == "" || !s.tombstones[models.ErasureActor+":"+login] {
+		return "", false
+	}
+	return memoryPseudonym(login), true
+}
+
+// erasedEmail reports whether an email has been erased. The caller must hold the lock.
+func (s *MemoryStore) erasedEmail(email string) bool {
+	return s.tombstones[models.ErasureEmail+":"+email]
+}
+
+// noErasedLogins is a login lookup that matches nothing.
+func noErasedLogins(string) (string, bool) {
+	return "", false
+}
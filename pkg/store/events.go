@@ -0,0 +1,290 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// eventColumns selects an event of github g with the encrypted commit author
+// emails of github_emails m, for queries grouped by event and read by scanEvents.
+const eventColumns = `COALESCE(g.external_id, ''), g.event_type, g.actor, g.repo_url, g.created_at, g.commit_count,
+	COALESCE(array_agg(m.email_encrypted) FILTER (WHERE m.email_encrypted IS NOT NULL), '{}')`
+
+// scanEvents reads the rows of a query selecting eventColumns, decrypting the emails.
+func (s *PostgresStore) scanEvents(rows *sql.Rows) ([]models.GitHubEvent, error) {
+	defer rows.Close()
+
+	var events []models.GitHubEvent
+	for rows.Next() {
+		var event models.GitHubEvent
+		var emails []string
+		if err := rows.Scan(&event.ID, &event.Type, &event.Actor.Login, &event.Repo.URL, &event.CreatedAt, &event.Payload.Size, pq.Array(&emails)); err != nil {
+			return nil, err
+		}
+		for _, encrypted := range emails {
+			email, err := s.keys.Decrypt(encrypted)
+			if err != nil {
+				return nil, err
+			}
+			event.Payload.Commits = append(event.Payload.Commits, models.Commit{Author: models.Author{Email: email}})
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// inWindow restricts a timestamp column, formatted in as %[1]s, to the time
+// range passed as $1 and $2 by windowArgs.
+const inWindow = `($1::timestamp IS NULL OR %[1]s >= $1) AND ($2::timestamp IS NULL OR %[1]s < $2)`
+
+// windowArgs returns the query parameters for inWindow.
+func windowArgs(window models.TimeRange) []interface{} {
+	return []interface{}{nullTime(window.Since), nullTime(window.Until)}
+}
+
+// nullTime returns nil for the zero time so it can be passed as a NULL parameter.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// ErrNotFound is returned for an event ID that is not stored.
+var ErrNotFound = errors.New("not found")
+
+// matchesFilter restricts events of github g to those passing an EventFilter
+// given as $1 to $6 by filterArgs.
+var matchesFilter = fmt.Sprintf(inWindow, "g.created_at") + `
+	AND (COALESCE(cardinality($3::text[]), 0) = 0 OR g.event_type = ANY($3))
+	AND (COALESCE(cardinality($4::text[]), 0) = 0 OR g.repo_url = ANY($4))
+	AND (COALESCE(cardinality($5::text[]), 0) = 0 OR g.actor = ANY($5))
+	AND (COALESCE(cardinality($6::text[]), 0) = 0 OR g.source = ANY($6))`
+
+// filterArgs returns the query parameters for matchesFilter.
+func filterArgs(filter models.EventFilter) []interface{} {
+	return append(windowArgs(filter.TimeRange),
+		pq.Array(filter.Types), pq.Array(filter.Repos), pq.Array(filter.Actors), pq.Array(filter.Sources))
+}
+
+// FindEvents returns the events matching the filter, including their decrypted
+// commit author emails, oldest first.
+func (s *PostgresStore) FindEvents(filter models.EventFilter) ([]models.GitHubEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT `+eventColumns+`
+		FROM github g
+		LEFT JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		LEFT JOIN github_emails m ON m.id = e.email_id
+		WHERE `+matchesFilter+`
+		GROUP BY g.id, g.created_at
+		ORDER BY g.created_at, g.id`, filterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanEvents(rows)
+}
+
+// storedEventColumns selects a StoredEvent of github g with the encrypted
+// commit author emails of github_emails m, for queries grouped by event.
+const storedEventColumns = `g.id, COALESCE(g.external_id, ''), COALESCE(g.event_type, ''), COALESCE(g.actor, ''),
+	COALESCE(g.repo_url, ''), g.created_at, g.source,
+	COALESCE(array_agg(m.email_encrypted) FILTER (WHERE m.email_encrypted IS NOT NULL), '{}')`
+
+// scanStoredEvents reads the rows of a query selecting storedEventColumns, decrypting the emails.
+func (s *PostgresStore) scanStoredEvents(rows *sql.Rows) ([]models.StoredEvent, error) {
+	defer rows.Close()
+
+	events := []models.StoredEvent{}
+	for rows.Next() {
+		var event models.StoredEvent
+		var emails []string
+		err := rows.Scan(&event.ID, &event.GitHubID, &event.Type, &event.Actor, &event.Repo, &event.CreatedAt, &event.Source, pq.Array(&emails))
+		if err != nil {
+			return nil, err
+		}
+		event.Emails = []string{}
+		for _, encrypted := range emails {
+			email, err := s.keys.Decrypt(encrypted)
+			if err != nil {
+				return nil, err
+			}
+			event.Emails = append(event.Emails, email)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// ListEvents returns a page of the events matching the filter ordered by
+// creation time, newest first if descending, with ties broken by ID.
+func (s *PostgresStore) ListEvents(filter models.EventFilter, descending bool, page models.Page) ([]models.StoredEvent, string, error) {
+	var afterTime interface{}
+	var afterID int64
+	if page.After != "" {
+		t, id, err := parseEventKey(page.After)
+		if err != nil {
+			return nil, "", err
+		}
+		afterTime, afterID = t, id
+	}
+
+	order, compare := "ASC", ">"
+	if descending {
+		order, compare = "DESC", "<"
+	}
+	rows, err := s.db.Query(`
+		SELECT `+storedEventColumns+`
+		FROM github g
+		LEFT JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		LEFT JOIN github_emails m ON m.id = e.email_id
+		WHERE `+matchesFilter+`
+			AND ($7::timestamp IS NULL OR (g.created_at, g.id) `+compare+` ($7, $8))
+		GROUP BY g.id, g.created_at
+		ORDER BY g.created_at `+order+`, g.id `+order+`
+		LIMIT $9`, append(filterArgs(filter), afterTime, afterID, page.Limit+1)...)
+	if err != nil {
+		return nil, "", err
+	}
+	events, err := s.scanStoredEvents(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	events, next := eventPage(events, page.Limit)
+	return events, next, nil
+}
+
+// EventsAfter returns up to limit events matching the filter with a store ID
+// greater than afterID, in ID order.
+func (s *PostgresStore) EventsAfter(filter models.EventFilter, afterID int64, limit int) ([]models.StoredEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT `+storedEventColumns+`
+		FROM github g
+		LEFT JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		LEFT JOIN github_emails m ON m.id = e.email_id
+		WHERE `+matchesFilter+` AND g.id > $7
+		GROUP BY g.id, g.created_at
+		ORDER BY g.id
+		LIMIT $8`, append(filterArgs(filter), afterID, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanStoredEvents(rows)
+}
+
+// LatestActorEvents returns up to limit of the latest events of each of the
+// actors with the given logins, newest first.
+func (s *PostgresStore) LatestActorEvents(logins []string, limit int) (map[string][]models.StoredEvent, error) {
+	events, err := s.latestEvents("actor", logins, limit)
+	if err != nil {
+		return nil, err
+	}
+	return groupEvents(events, func(event models.StoredEvent) string { return event.Actor }), nil
+}
+
+// LatestRepoEvents returns up to limit of the latest events of each of the
+// repositories with the given URLs, newest first.
+func (s *PostgresStore) LatestRepoEvents(urls []string, limit int) (map[string][]models.StoredEvent, error) {
+	events, err := s.latestEvents("repo_url", urls, limit)
+	if err != nil {
+		return nil, err
+	}
+	return groupEvents(events, func(event models.StoredEvent) string { return event.Repo }), nil
+}
+
+// latestEvents returns the latest limit events of each value of a github
+// column in one query, newest first.
+func (s *PostgresStore) latestEvents(column string, values []string, limit int) ([]models.StoredEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT `+storedEventColumns+`
+		FROM github g
+		LEFT JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		LEFT JOIN github_emails m ON m.id = e.email_id
+		WHERE g.id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY `+column+` ORDER BY created_at DESC, id DESC) AS position
+				FROM github WHERE `+column+` = ANY($1)) latest
+			WHERE position <= $2)
+		GROUP BY g.id, g.created_at
+		ORDER BY g.created_at DESC, g.id DESC`, pq.Array(values), limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanStoredEvents(rows)
+}
+
+// groupEvents groups events by key, keeping their order.
+func groupEvents(events []models.StoredEvent, key func(models.StoredEvent) string) map[string][]models.StoredEvent {
+	groups := make(map[string][]models.StoredEvent)
+	for _, event := range events {
+		groups[key(event)] = append(groups[key(event)], event)
+	}
+	return groups
+}
+
+// LastEventID returns the greatest store ID of an event, or 0 if there are none.
+func (s *PostgresStore) LastEventID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM github").Scan(&id)
+	return id, err
+}
+
+// GetEvent returns the event with the given store ID, or ErrNotFound.
+func (s *PostgresStore) GetEvent(id int64) (models.StoredEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT `+storedEventColumns+`
+		FROM github g
+		LEFT JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		LEFT JOIN github_emails m ON m.id = e.email_id
+		WHERE g.id = $1
+		GROUP BY g.id, g.created_at`, id)
+	if err != nil {
+		return models.StoredEvent{}, err
+	}
+	events, err := s.scanStoredEvents(rows)
+	if err != nil {
+		return models.StoredEvent{}, err
+	}
+	if len(events) == 0 {
+		return models.StoredEvent{}, ErrNotFound
+	}
+	return events[0], nil
+}
+
+// eventKey is the keyset position of an event in creation order.
+func eventKey(event models.StoredEvent) string {
+	return event.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(event.ID, 10)
+}
+
+// parseEventKey splits a key made by eventKey.
+func parseEventKey(key string) (time.Time, int64, error) {
+	at, id, ok := strings.Cut(key, "|")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return createdAt, n, nil
+}
+
+// eventPage cuts events, fetched with one more than the page limit, to the
+// page and returns the key of its last event if another page follows.
+func eventPage(events []models.StoredEvent, limit int) ([]models.StoredEvent, string) {
+	if len(events) <= limit {
+		return events, ""
+	}
+	events = events[:limit]
+	return events, eventKey(events[limit-1])
+}
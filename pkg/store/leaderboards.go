@@ -0,0 +1,136 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// ranked is an actor or repository with its number of events and leaderboard rank.
+type ranked struct {
+	value  string
+	events int64
+	rank   int
+}
+
+// rankAll orders entries by number of events, most first, breaking ties by
+// value, and assigns ranks shared by equal counts.
+func rankAll(entries []ranked) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].events != entries[j].events {
+			return entries[i].events > entries[j].events
+		}
+		return entries[i].value < entries[j].value
+	})
+	for i := range entries {
+		entries[i].rank = i + 1
+		if i > 0 && entries[i].events == entries[i-1].events {
+			entries[i].rank = entries[i-1].rank
+		}
+	}
+}
+
+// topRanked returns the limit values of a rollup column with the most events
+// of the given types in the window, optionally ignoring events of bots.
+func (s *PostgresStore) topRanked(column string, window models.TimeRange, types []string, limit int, excludeBots bool) ([]ranked, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT %[1]s, SUM(count) FROM event_rollups
+		WHERE %[2]s AND %[1]s <> ''
+			AND (COALESCE(cardinality($3::text[]), 0) = 0 OR event_type = ANY($3))
+			AND NOT ($4::boolean AND actor LIKE '%%'||$5)
+		GROUP BY %[1]s
+		ORDER BY SUM(count) DESC, %[1]s
+		LIMIT $6`, column, fmt.Sprintf(inWindow, "bucket")),
+		append(windowArgs(window), pq.Array(types), excludeBots, models.BotSuffix, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ranked
+	for rows.Next() {
+		var entry ranked
+		if err := rows.Scan(&entry.value, &entry.events); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rankAll(entries)
+	return entries, nil
+}
+
+// TopActors returns the limit actors with the most events of the given types
+// in the window, read from the rollups.
+func (s *PostgresStore) TopActors(window models.TimeRange, types []string, limit int, excludeBots bool) ([]models.ActorRank, error) {
+	entries, err := s.topRanked("actor", window, types, limit, excludeBots)
+	return actorRanks(entries), err
+}
+
+// TopRepos returns the limit repositories with the most events of the given
+// types in the window, read from the rollups.
+func (s *PostgresStore) TopRepos(window models.TimeRange, types []string, limit int, excludeBots bool) ([]models.RepoRank, error) {
+	entries, err := s.topRanked("repo_url", window, types, limit, excludeBots)
+	return repoRanks(entries), err
+}
+
+// topRanked returns the limit values, extracted from rollups by ref, with the
+// most events of the given types in the window, optionally ignoring events of bots.
+func (s *MemoryStore) topRanked(ref func(rollupKey) string, window models.TimeRange, types []string, limit int, excludeBots bool) []ranked {
+	filter := models.EventFilter{TimeRange: window, Types: types}
+	s.mu.RLock()
+	counts := make(map[string]int64)
+	for key, count := range s.rollups {
+		rollup := models.StoredEvent{Type: key.eventType, Actor: key.actor, Repo: key.repoURL, CreatedAt: key.bucket}
+		if ref(key) == "" || !filter.MatchStored(rollup) {
+			continue
+		}
+		if excludeBots && models.IsBot(key.actor) {
+			continue
+		}
+		counts[ref(key)] += count
+	}
+	s.mu.RUnlock()
+
+	entries := make([]ranked, 0, len(counts))
+	for value, events := range counts {
+		entries = append(entries, ranked{value: value, events: events})
+	}
+	rankAll(entries)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// TopActors returns the limit actors with the most events of the given types in the window.
+func (s *MemoryStore) TopActors(window models.TimeRange, types []string, limit int, excludeBots bool) ([]models.ActorRank, error) {
+	return actorRanks(s.topRanked(func(key rollupKey) string { return key.actor }, window, types, limit, excludeBots)), nil
+}
+
+// TopRepos returns the limit repositories with the most events of the given types in the window.
+func (s *MemoryStore) TopRepos(window models.TimeRange, types []string, limit int, excludeBots bool) ([]models.RepoRank, error) {
+	return repoRanks(s.topRanked(func(key rollupKey) string { return key.repoURL }, window, types, limit, excludeBots)), nil
+}
+
+// actorRanks converts ranked actor logins.
+func actorRanks(entries []ranked) []models.ActorRank {
+	ranks := []models.ActorRank{}
+	for _, entry := range entries {
+		ranks = append(ranks, models.ActorRank{Rank: entry.rank, Login: entry.value, Events: entry.events})
+	}
+	return ranks
+}
+
+// repoRanks converts ranked repository URLs.
+func repoRanks(entries []ranked) []models.RepoRank {
+	ranks := []models.RepoRank{}
+	for _, entry := range entries {
+		ranks = append(ranks, models.RepoRank{Rank: entry.rank, URL: entry.value, Events: entry.events})
+	}
+	return ranks
+}
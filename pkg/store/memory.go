@@ -0,0 +1,675 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an EventStore that keeps everything in process memory.
+// It mirrors the behaviour of PostgresStore and is intended for tests.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	events      []memoryEvent
+	lastID      int64
+	actors      map[string]*entity
+	repos       map[string]*entity
+	emails      map[string]*entity
+	issues      map[issueKey]*memoryIssue
+	transitions []models.IssueUpdate
+	purges      []models.PurgeReport
+	rollups     map[rollupKey]int64
+	tombstones  map[string]bool
+	// stored holds the keys of the stored events
+	stored map[string]bool
+}
+
+// memoryEvent is a stored event with its store ID.
+type memoryEvent struct {
+	id int64
+	models.GitHubEvent
+}
+
+// rollupKey identifies a rollup row.
+type rollupKey struct {
+	resolution string
+	bucket     time.Time
+	eventType  string
+	repoURL    string
+	actor      string
+}
+
+// entity tracks when an actor, repository or email was first and last seen.
+type entity struct {
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+type issueKey struct {
+	repoURL string
+	number  int
+}
+
+type memoryIssue struct {
+	author          string
+	state           string
+	openedAt        time.Time
+	closedAt        *time.Time
+	firstResponseAt *time.Time
+	reopenCount     int
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		actors:     make(map[string]*entity),
+		repos:      make(map[string]*entity),
+		emails:     make(map[string]*entity),
+		issues:     make(map[issueKey]*memoryIssue),
+		rollups:    make(map[rollupKey]int64),
+		tombstones: make(map[string]bool),
+		stored:     make(map[string]bool),
+	}
+}
+
+// StoreEvent appends an event to the store, records its actor, repository
+// and commit author emails and counts it in the minute rollups. Erased
+// identities are scrubbed first. An event whose GitHub ID is already stored
+// is skipped. It reports whether the event was inserted.
+func (s *MemoryStore) StoreEvent(event models.GitHubEvent) (bool, error) {
+	return s.insertEvent(event, event.ID)
+}
+
+// ImportEvent stores an event like StoreEvent, keyed by GitHubEvent.Key so
+// events without an ID are imported once too, and reports whether it was inserted.
+func (s *MemoryStore) ImportEvent(event models.GitHubEvent) (bool, error) {
+	return s.insertEvent(event, event.Key())
+}
+
+// insertEvent stores an event under key unless that key is stored already.
+// An empty key stores the event unconditionally.
+func (s *MemoryStore) insertEvent(event models.GitHubEvent, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key != "" {
+		if s.stored[key] {
+			return false, nil
+		}
+		s.stored[key] = true
+		event.ID = key
+	}
+	event = scrubEvent(event, s.erasedLogin, s.erasedEmail)
+
+	s.lastID++
+	s.events = append(s.events, memoryEvent{s.lastID, event})
+	s.rollups[rollupKey{models.ResolutionMinute, event.CreatedAt.UTC().Truncate(time.Minute), event.Type, event.Repo.URL, event.Actor.Login}]++
+	seen(s.actors, event.Actor.Login, event.CreatedAt)
+	seen(s.repos, event.Repo.URL, event.CreatedAt)
+	for _, commit := range event.Payload.Commits {
+		if commit.Author.Email != "" {
+			seen(s.emails, commit.Author.Email, event.CreatedAt)
+		}
+	}
+	return true, nil
+}
+
+// memoryPseudonym derives the pseudonym of an erased login. The memory store
+// has no keyring, so an unkeyed hash stands in for the keyed one.
+func memoryPseudonym(login string) string {
+	hash := sha256.Sum256([]byte(login))
+	return pii.Pseudonym(hash[:])
+}
+
+// seen widens the first/last seen range of an entity to include seenAt.
+func seen(entities map[string]*entity, key string, seenAt time.Time) {
+	e, ok := entities[key]
+	if !ok {
+		entities[key] = &entity{firstSeen: seenAt, lastSeen: seenAt}
+		return
+	}
+	if seenAt.Before(e.firstSeen) {
+		e.firstSeen = seenAt
+	}
+	if seenAt.After(e.lastSeen) {
+		e.lastSeen = seenAt
+	}
+}
+
+// StoreIssueUpdate records an issue transition and applies it to the issue state.
+func (s *MemoryStore) StoreIssueUpdate(update models.IssueUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update = scrubIssueUpdate(update, s.erasedLogin)
+
+	if update.Action != "commented" {
+		s.transitions = append(s.transitions, update)
+	}
+
+	key := issueKey{update.RepoURL, update.Number}
+	issue, ok := s.issues[key]
+	if !ok {
+		issue = &memoryIssue{author: update.Author, state: "open", openedAt: update.OpenedAt}
+		s.issues[key] = issue
+	}
+
+	switch update.Action {
+	case "opened":
+		issue.author = update.Author
+		issue.openedAt = update.OpenedAt
+	case "closed":
+		closedAt := update.OccurredAt
+		issue.state = "closed"
+		issue.closedAt = &closedAt
+	case "reopened":
+		issue.state = "open"
+		issue.closedAt = nil
+		issue.reopenCount++
+	case "commented":
+		if update.FirstResponse != nil && (issue.firstResponseAt == nil || update.FirstResponse.Before(*issue.firstResponseAt)) {
+			respondedAt := *update.FirstResponse
+			issue.firstResponseAt = &respondedAt
+		}
+	}
+	return nil
+}
+
+// Events returns a copy of every stored event.
+func (s *MemoryStore) Events() ([]models.GitHubEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]models.GitHubEvent, 0, len(s.events))
+	for _, event := range s.events {
+		events = append(events, event.GitHubEvent)
+	}
+	return events, nil
+}
+
+// FindEvents returns the events matching the filter, oldest first.
+func (s *MemoryStore) FindEvents(filter models.EventFilter) ([]models.GitHubEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.GitHubEvent
+	for _, event := range s.events {
+		if filter.Match(event.GitHubEvent) {
+			events = append(events, event.GitHubEvent)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events, nil
+}
+
+// ListEvents returns a page of the events matching the filter ordered by
+// creation time, newest first if descending, with ties broken by ID.
+func (s *MemoryStore) ListEvents(filter models.EventFilter, descending bool, page models.Page) ([]models.StoredEvent, string, error) {
+	var after models.StoredEvent
+	if page.After != "" {
+		createdAt, id, err := parseEventKey(page.After)
+		if err != nil {
+			return nil, "", err
+		}
+		after = models.StoredEvent{ID: id, CreatedAt: createdAt}
+	}
+	// less orders events in creation order, ties broken by ID
+	less := func(a, b models.StoredEvent) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+
+	s.mu.RLock()
+	events := []models.StoredEvent{}
+	for _, event := range s.events {
+		if !filter.Match(event.GitHubEvent) {
+			continue
+		}
+		stored := event.stored()
+		if page.After != "" && (descending && !less(stored, after) || !descending && !less(after, stored)) {
+			continue
+		}
+		events = append(events, stored)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		if descending {
+			return less(events[j], events[i])
+		}
+		return less(events[i], events[j])
+	})
+	events, next := eventPage(events, page.Limit)
+	return events, next, nil
+}
+
+// EventsAfter returns up to limit events matching the filter with a store ID
+// greater than afterID, in ID order.
+func (s *MemoryStore) EventsAfter(filter models.EventFilter, afterID int64, limit int) ([]models.StoredEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := []models.StoredEvent{}
+	for _, event := range s.events {
+		if len(events) == limit {
+			break
+		}
+		if event.id > afterID && filter.Match(event.GitHubEvent) {
+			events = append(events, event.stored())
+		}
+	}
+	return events, nil
+}
+
+// LatestActorEvents returns up to limit of the latest events of each of the
+// actors with the given logins, newest first.
+func (s *MemoryStore) LatestActorEvents(logins []string, limit int) (map[string][]models.StoredEvent, error) {
+	return s.latestEvents(models.EventFilter{Actors: logins}, limit, func(event models.StoredEvent) string { return event.Actor }), nil
+}
+
+// LatestRepoEvents returns up to limit of the latest events of each of the
+// repositories with the given URLs, newest first.
+func (s *MemoryStore) LatestRepoEvents(urls []string, limit int) (map[string][]models.StoredEvent, error) {
+	return s.latestEvents(models.EventFilter{Repos: urls}, limit, func(event models.StoredEvent) string { return event.Repo }), nil
+}
+
+// latestEvents returns the latest limit events matching the filter for each key.
+func (s *MemoryStore) latestEvents(filter models.EventFilter, limit int, key func(models.StoredEvent) string) map[string][]models.StoredEvent {
+	groups := make(map[string][]models.StoredEvent)
+	if len(filter.Actors) == 0 && len(filter.Repos) == 0 {
+		return groups
+	}
+
+	s.mu.RLock()
+	var events []models.StoredEvent
+	for _, event := range s.events {
+		if filter.Match(event.GitHubEvent) {
+			events = append(events, event.stored())
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+	groups = groupEvents(events, key)
+	for k, group := range groups {
+		if len(group) > limit {
+			groups[k] = group[:limit]
+		}
+	}
+	return groups
+}
+
+// LastEventID returns the greatest store ID of an event, or 0 if there are none.
+func (s *MemoryStore) LastEventID() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastID, nil
+}
+
+// GetEvent returns the event with the given store ID, or ErrNotFound.
+func (s *MemoryStore) GetEvent(id int64) (models.StoredEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, event := range s.events {
+		if event.id == id {
+			return event.stored(), nil
+		}
+	}
+	return models.StoredEvent{}, ErrNotFound
+}
+
+// stored returns the event as a StoredEvent with its distinct commit author emails.
+func (e memoryEvent) stored() models.StoredEvent {
+	source := e.Source
+	if source == "" {
+		source = models.SourceGitHub
+	}
+	event := models.StoredEvent{
+		ID:        e.id,
+		GitHubID:  e.ID,
+		Type:      e.Type,
+		Actor:     e.Actor.Login,
+		Repo:      e.Repo.URL,
+		CreatedAt: e.CreatedAt,
+		Source:    source,
+		Emails:    []string{},
+	}
+	linked := make(map[string]bool)
+	for _, email := range commitEmails(e.GitHubEvent) {
+		if email != "" && !linked[email] {
+			linked[email] = true
+			event.Emails = append(event.Emails, email)
+		}
+	}
+	return event
+}
+
+// EventCounts returns the number of events per event type in the window,
+// summed over the rollups of every resolution whose bucket starts in it.
+func (s *MemoryStore) EventCounts(window models.TimeRange) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	eventTypeCount := make(map[string]int)
+	for key, count := range s.rollups {
+		if window.Contains(key.bucket) {
+			eventTypeCount[key.eventType] += int(count)
+		}
+	}
+	return eventTypeCount, nil
+}
+
+// CompactRollups downsamples aged minute rollups into hours and aged hour
+// rollups into days, and deletes day rollups past their tier.
+func (s *MemoryStore) CompactRollups(tiers models.RollupTiers, now time.Time) (models.CompactionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var report models.CompactionReport
+	minuteCutoff, hourCutoff, dayCutoff := tierCutoffs(tiers, now)
+	report.HourRows = s.compact(models.ResolutionMinute, models.ResolutionHour, time.Hour, minuteCutoff)
+	report.DayRows = s.compact(models.ResolutionHour, models.ResolutionDay, 24*time.Hour, hourCutoff)
+	if !dayCutoff.IsZero() {
+		for key := range s.rollups {
+			if key.resolution == models.ResolutionDay && key.bucket.Before(dayCutoff) {
+				delete(s.rollups, key)
+				report.DeletedDays++
+			}
+		}
+	}
+	return report, nil
+}
+
+// compact moves rollups of one resolution older than cutoff into the next
+// coarser resolution and returns the number of rows written.
+func (s *MemoryStore) compact(from, to string, width time.Duration, cutoff time.Time) int64 {
+	written := make(map[rollupKey]bool)
+	for key, count := range s.rollups {
+		if key.resolution != from || !key.bucket.Before(cutoff) {
+			continue
+		}
+		delete(s.rollups, key)
+		coarse := key
+		coarse.resolution = to
+		coarse.bucket = key.bucket.Truncate(width)
+		s.rollups[coarse] += count
+		written[coarse] = true
+	}
+	return int64(len(written))
+}
+
+// UniqueEmails returns the commit author emails of events in the window in sorted order.
+func (s *MemoryStore) UniqueEmails(window models.TimeRange) ([]string, error) {
+	return s.keys(s.emails, window, commitEmails), nil
+}
+
+// commitEmails returns the commit author emails of an event.
+func commitEmails(event models.GitHubEvent) []string {
+	var emails []string
+	for _, commit := range event.Payload.Commits {
+		emails = append(emails, commit.Author.Email)
+	}
+	return emails
+}
+
+// keys returns the sorted keys of an entity map that the events in the
+// window refer to, as extracted by refs.
+func (s *MemoryStore) keys(entities map[string]*entity, window models.TimeRange, refs func(models.GitHubEvent) []string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	active := make(map[string]bool)
+	for _, event := range s.events {
+		if window.Contains(event.CreatedAt) {
+			for _, ref := range refs(event.GitHubEvent) {
+				active[ref] = true
+			}
+		}
+	}
+
+	var values []string
+	for key := range entities {
+		if active[key] {
+			values = append(values, key)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// IssueMetrics computes median response and close times per repository.
+func (s *MemoryStore) IssueMetrics(repoURL string) ([]models.IssueMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type samples struct {
+		metrics       models.IssueMetrics
+		firstResponse []float64
+		timeToClose   []float64
+	}
+	byRepo := make(map[string]*samples)
+	for key, issue := range s.issues {
+		if repoURL != "" && key.repoURL != repoURL {
+			continue
+		}
+		repo, ok := byRepo[key.repoURL]
+		if !ok {
+			repo = &samples{metrics: models.IssueMetrics{RepoURL: key.repoURL}}
+			byRepo[key.repoURL] = repo
+		}
+		if issue.state == "closed" {
+			repo.metrics.ClosedIssues++
+		} else {
+			repo.metrics.OpenIssues++
+		}
+		if issue.firstResponseAt != nil {
+			repo.firstResponse = append(repo.firstResponse, issue.firstResponseAt.Sub(issue.openedAt).Seconds())
+		}
+		if issue.closedAt != nil {
+			repo.timeToClose = append(repo.timeToClose, issue.closedAt.Sub(issue.openedAt).Seconds())
+		}
+	}
+
+	metrics := []models.IssueMetrics{}
+	for _, repo := range byRepo {
+		repo.metrics.MedianFirstResponseSeconds = median(repo.firstResponse)
+		repo.metrics.MedianTimeToCloseSeconds = median(repo.timeToClose)
+		metrics = append(metrics, repo.metrics)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].RepoURL < metrics[j].RepoURL })
+	return metrics, nil
+}
+
+// IssueBacklog counts the issues open at the end of each of the last days days.
+func (s *MemoryStore) IssueBacklog(repoURL string, days int) ([]models.BacklogPoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	backlog := []models.BacklogPoint{}
+	counts := make(map[string]map[time.Time]int)
+	for key, issue := range s.issues {
+		if repoURL != "" && key.repoURL != repoURL {
+			continue
+		}
+		for i := days - 1; i >= 0; i-- {
+			day := today.AddDate(0, 0, -i)
+			end := day.AddDate(0, 0, 1)
+			if issue.openedAt.Before(end) && (issue.closedAt == nil || !issue.closedAt.Before(end)) {
+				if counts[key.repoURL] == nil {
+					counts[key.repoURL] = make(map[time.Time]int)
+				}
+				counts[key.repoURL][day]++
+			}
+		}
+	}
+	for repo, byDay := range counts {
+		for day, n := range byDay {
+			backlog = append(backlog, models.BacklogPoint{RepoURL: repo, Day: day, OpenIssues: n})
+		}
+	}
+	sort.Slice(backlog, func(i, j int) bool {
+		if backlog[i].RepoURL != backlog[j].RepoURL {
+			return backlog[i].RepoURL < backlog[j].RepoURL
+		}
+		return backlog[i].Day.Before(backlog[j].Day)
+	})
+	return backlog, nil
+}
+
+// Purge deletes the events that have expired under the policy as of now,
+// uncounts them from the rollups and removes actors, repositories and emails
+// left without events.
+func (s *MemoryStore) Purge(policy models.RetentionPolicy, now time.Time, dryRun bool) (models.PurgeReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type ruleKey struct{ rule, match string }
+	counts := make(map[ruleKey]int64)
+	days := make(map[ruleKey]int)
+	kept := make([]memoryEvent, 0, len(s.events))
+	var purged []models.GitHubEvent
+	for _, event := range s.events {
+		rule, match, d, expired := classify(policy, now, event.GitHubEvent)
+		if !expired {
+			kept = append(kept, event)
+			continue
+		}
+		key := ruleKey{rule, match}
+		counts[key]++
+		if _, ok := days[key]; !ok || d < days[key] {
+			days[key] = d
+		}
+		if rule == models.RuleLegalHold {
+			kept = append(kept, event)
+		} else {
+			purged = append(purged, event.GitHubEvent)
+		}
+	}
+
+	keys := make([]ruleKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].rule != keys[j].rule {
+			return keys[i].rule < keys[j].rule
+		}
+		return keys[i].match < keys[j].match
+	})
+
+	report := models.PurgeReport{StartedAt: now, DryRun: dryRun, Policy: policy}
+	for _, key := range keys {
+		report.Add(key.rule, key.match, days[key], counts[key])
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	s.events = kept
+	for _, event := range purged {
+		s.releaseRollup(event)
+	}
+	s.stored = make(map[string]bool, len(kept))
+	for _, event := range kept {
+		if event.ID != "" {
+			s.stored[event.ID] = true
+		}
+	}
+	s.pruneEntities()
+	return report, nil
+}
+
+// ExpiredEvents returns the events that Purge would delete under the policy as of now, oldest first.
+func (s *MemoryStore) ExpiredEvents(policy models.RetentionPolicy, now time.Time) ([]models.GitHubEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.GitHubEvent
+	for _, event := range s.events {
+		if rule, _, _, expired := classify(policy, now, event.GitHubEvent); expired && rule != models.RuleLegalHold {
+			events = append(events, event.GitHubEvent)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	return events, nil
+}
+
+// classify applies the retention policy to an event and reports whether it has expired as of now.
+func classify(policy models.RetentionPolicy, now time.Time, event models.GitHubEvent) (rule, match string, days int, expired bool) {
+	rule, match, days = policy.Classify(event.Type, event.Repo.URL)
+	expired = days > 0 && event.CreatedAt.Before(models.Cutoff(now, days))
+	return rule, match, days, expired
+}
+
+// pruneEntities removes actors, repositories and emails no longer referenced
+// by any event. The caller must hold the write lock.
+func (s *MemoryStore) pruneEntities() {
+	actors := make(map[string]bool)
+	repos := make(map[string]bool)
+	emails := make(map[string]bool)
+	for _, event := range s.events {
+		actors[event.Actor.Login] = true
+		repos[event.Repo.URL] = true
+		for _, commit := range event.Payload.Commits {
+			emails[commit.Author.Email] = true
+		}
+	}
+	prune(s.actors, actors)
+	prune(s.repos, repos)
+	prune(s.emails, emails)
+}
+
+// prune deletes the entities that are not referenced.
+func prune(entities map[string]*entity, referenced map[string]bool) {
+	for key := range entities {
+		if !referenced[key] {
+			delete(entities, key)
+		}
+	}
+}
+
+// RecordPurge keeps an audit record of a purge.
+func (s *MemoryStore) RecordPurge(report models.PurgeReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purges = append(s.purges, report)
+	return nil
+}
+
+// Purges returns the audit records kept by RecordPurge.
+func (s *MemoryStore) Purges() []models.PurgeReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.PurgeReport(nil), s.purges...)
+}
+
+// median returns the median of the values, or nil if there are none.
+func median(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	m := sorted[mid]
+	if len(sorted)%2 == 0 {
+		m = (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return &m
+}
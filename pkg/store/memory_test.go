@@ -0,0 +1,364 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreAggregates(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	for _, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo2"}, CreatedAt: now},
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: now},
+		{Type: "WatchEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}, CreatedAt: now.AddDate(0, 0, -3)},
+	} {
+		if _, err := s.StoreEvent(event); err != nil {
+			t.Fatalf("Error storing event: %v", err)
+		}
+	}
+
+	counts, _ := s.EventCounts(models.TimeRange{})
+	if !reflect.DeepEqual(counts, map[string]int{"PushEvent": 2, "WatchEvent": 1}) {
+		t.Errorf("Unexpected event counts: %v", counts)
+	}
+
+	actors, _, _ := s.UniqueActors(models.TimeRange{}, models.Page{Limit: 100})
+	if !reflect.DeepEqual(logins(actors), []string{"alice", "bob"}) || actors[0].Events != 2 {
+		t.Errorf("Expected distinct actors by latest activity, got %+v", actors)
+	}
+
+	// Purging with a two day policy removes only the older event
+	report, err := s.Purge(models.RetentionPolicy{DefaultDays: 2}, now, false)
+	if err != nil || report.TotalEvents != 1 {
+		t.Errorf("Expected 1 event to be removed, got %+v (err=%v)", report, err)
+	}
+	events, _ := s.Events()
+	if len(events) != 2 {
+		t.Errorf("Expected 2 events to remain, got %d", len(events))
+	}
+}
+
+func TestMemoryStoreRecentActivity(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	for i, actor := range []string{"carol", "alice", "bob", "alice", "dave"} {
+		s.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: actor}, CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	// Most recently active first, paged by keyset
+	var pages [][]string
+	page := models.Page{Limit: 2}
+	for {
+		actors, next, err := s.UniqueActors(models.TimeRange{}, page)
+		if err != nil {
+			t.Fatalf("Error listing actors: %v", err)
+		}
+		pages = append(pages, logins(actors))
+		if next == "" {
+			break
+		}
+		page.After = next
+	}
+	if !reflect.DeepEqual(pages, [][]string{{"dave", "alice"}, {"bob", "carol"}}) {
+		t.Errorf("Unexpected pages: %v", pages)
+	}
+
+	// Counts and last seen times are limited to the window
+	actors, _, _ := s.UniqueActors(models.TimeRange{Until: now.Add(2 * time.Minute)}, models.Page{Limit: 10})
+	if len(actors) != 2 || actors[0].Login != "alice" || actors[0].Events != 1 || !actors[0].LastSeen.Equal(now.Add(time.Minute)) {
+		t.Errorf("Unexpected actors in window: %+v", actors)
+	}
+
+	// and so is the order
+	actors, _, _ = s.UniqueActors(models.TimeRange{Until: now.Add(3 * time.Minute)}, models.Page{Limit: 10})
+	if !reflect.DeepEqual(logins(actors), []string{"bob", "alice", "carol"}) {
+		t.Errorf("Expected actors by latest activity in the window, got %v", logins(actors))
+	}
+
+	if _, _, err := s.UniqueActors(models.TimeRange{}, models.Page{Limit: 2, After: "garbage"}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// logins returns the logins of a list of actors.
+func logins(actors []models.ActorActivity) []string {
+	var values []string
+	for _, actor := range actors {
+		values = append(values, actor.Login)
+	}
+	return values
+}
+
+func TestMemoryStoreIssueMetrics(t *testing.T) {
+	s := NewMemoryStore()
+	opened := time.Now().Add(-48 * time.Hour)
+	respond := func(number int, after time.Duration) {
+		respondedAt := opened.Add(after)
+		s.StoreIssueUpdate(models.IssueUpdate{RepoURL: "repo", Number: number, Action: "opened", OpenedAt: opened, OccurredAt: opened})
+		s.StoreIssueUpdate(models.IssueUpdate{RepoURL: "repo", Number: number, Action: "commented", OpenedAt: opened, OccurredAt: respondedAt, FirstResponse: &respondedAt})
+	}
+	respond(1, time.Hour)
+	respond(2, 3*time.Hour)
+
+	// A later comment does not replace an earlier first response
+	later := opened.Add(10 * time.Hour)
+	s.StoreIssueUpdate(models.IssueUpdate{RepoURL: "repo", Number: 1, Action: "commented", OpenedAt: opened, OccurredAt: later, FirstResponse: &later})
+
+	// Closing and reopening leaves the issue open
+	s.StoreIssueUpdate(models.IssueUpdate{RepoURL: "repo", Number: 2, Action: "closed", OpenedAt: opened, OccurredAt: opened.Add(time.Hour)})
+	s.StoreIssueUpdate(models.IssueUpdate{RepoURL: "repo", Number: 2, Action: "reopened", OpenedAt: opened, OccurredAt: opened.Add(2 * time.Hour)})
+
+	metrics, err := s.IssueMetrics("")
+	if err != nil {
+		t.Fatalf("Error computing issue metrics: %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Expected metrics for one repository, got %+v", metrics)
+	}
+	m := metrics[0]
+	if m.OpenIssues != 2 || m.ClosedIssues != 0 {
+		t.Errorf("Expected 2 open issues, got %+v", m)
+	}
+	if m.MedianFirstResponseSeconds == nil || *m.MedianFirstResponseSeconds != 7200 {
+		t.Errorf("Expected median first response of 7200 seconds, got %v", m.MedianFirstResponseSeconds)
+	}
+	if m.MedianTimeToCloseSeconds != nil {
+		t.Errorf("Expected no time to close for reopened issues, got %v", *m.MedianTimeToCloseSeconds)
+	}
+
+	backlog, _ := s.IssueBacklog("repo", 3)
+	if len(backlog) != 3 || backlog[2].OpenIssues != 2 {
+		t.Errorf("Expected 2 open issues on each of the last 3 days, got %+v", backlog)
+	}
+}
+
+func TestMemoryStoreEntities(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	push := func(actor, email string, at time.Time) {
+		s.StoreEvent(models.GitHubEvent{
+			Type:      "PushEvent",
+			Actor:     models.Actor{Login: actor},
+			Repo:      models.Repo{URL: "repo"},
+			Payload:   models.Payload{Commits: []models.Commit{{Author: models.Author{Email: email}}, {}}},
+			CreatedAt: at,
+		})
+	}
+	push("old", "old@example.com", now.AddDate(0, 0, -5))
+	push("new", "new@example.com", now)
+
+	emails, _ := s.UniqueEmails(models.TimeRange{})
+	if !reflect.DeepEqual(emails, []string{"new@example.com", "old@example.com"}) {
+		t.Errorf("Expected commit author emails without blanks, got %v", emails)
+	}
+
+	// Entities left without events are removed with them
+	s.Purge(models.RetentionPolicy{DefaultDays: 2}, now, false)
+	actors, _, _ := s.UniqueActors(models.TimeRange{}, models.Page{Limit: 100})
+	emails, _ = s.UniqueEmails(models.TimeRange{})
+	repos, _, _ := s.UniqueRepoURLs(models.TimeRange{}, models.Page{Limit: 100})
+	if !reflect.DeepEqual(logins(actors), []string{"new"}) || !reflect.DeepEqual(emails, []string{"new@example.com"}) || len(repos) != 1 || repos[0].URL != "repo" {
+		t.Errorf("Unexpected entities after cleanup: actors=%v emails=%v repos=%v", actors, emails, repos)
+	}
+}
+
+func TestMemoryStorePurgePolicy(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Date(2023, 9, 10, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -5)
+	for _, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Repo: models.Repo{URL: "plain"}, CreatedAt: old},      // default rule: purged
+		{Type: "PushEvent", Repo: models.Repo{URL: "plain"}, CreatedAt: now},      // default rule: kept
+		{Type: "WatchEvent", Repo: models.Repo{URL: "plain"}, CreatedAt: old},     // event type rule: kept forever
+		{Type: "PushEvent", Repo: models.Repo{URL: "important"}, CreatedAt: old},  // repo rule: kept
+		{Type: "PushEvent", Repo: models.Repo{URL: "litigation"}, CreatedAt: old}, // legal hold: kept
+	} {
+		s.StoreEvent(event)
+	}
+	policy := models.RetentionPolicy{
+		DefaultDays: 2,
+		EventTypes:  map[string]int{"WatchEvent": 0},
+		Repos:       map[string]int{"important": 30},
+		LegalHold:   []string{"litigation"},
+	}
+
+	// A dry run reports without deleting
+	report, _ := s.Purge(policy, now, true)
+	if report.TotalEvents != 1 || report.HeldEvents != 1 || !report.DryRun {
+		t.Errorf("Unexpected dry run report: %+v", report)
+	}
+	if events, _ := s.Events(); len(events) != 5 {
+		t.Errorf("Expected a dry run to keep all events, got %d", len(events))
+	}
+
+	report, _ = s.Purge(policy, now, false)
+	if report.TotalEvents != 1 || len(report.Rules) != 2 || report.Rules[0].Rule != models.RuleDefault || report.Rules[1].Rule != models.RuleLegalHold {
+		t.Errorf("Unexpected purge report: %+v", report)
+	}
+	if events, _ := s.Events(); len(events) != 4 {
+		t.Errorf("Expected 4 events to remain, got %d", len(events))
+	}
+}
+
+func TestMemoryStoreCountedWindow(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Date(2023, 9, 10, 10, 0, 0, 0, time.UTC)
+	for _, minutes := range []int{5, 90, 170} {
+		s.StoreEvent(models.GitHubEvent{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: start.Add(time.Duration(minutes) * time.Minute)})
+	}
+	window := models.TimeRange{Since: start.Add(30 * time.Minute), Until: start.Add(100 * time.Minute)}
+
+	// Minute rollups cover the window as requested
+	if counted, _ := s.CountedWindow(window); counted != window {
+		t.Errorf("Expected the window %+v, got %+v", window, counted)
+	}
+
+	// Hour rollups are counted from the first hour starting in the window to
+	// the end of the last
+	s.CompactRollups(models.RollupTiers{Minute: time.Hour, Hour: 48 * time.Hour}, start.Add(5*time.Hour))
+	counted, err := s.CountedWindow(window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (models.TimeRange{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)}); counted != expected {
+		t.Errorf("Expected the counted window %+v, got %+v", expected, counted)
+	}
+	if counts, _ := s.EventCounts(window); counts["PushEvent"] != 1 {
+		t.Errorf("Expected the event of the counted hour, got %v", counts)
+	}
+	if counted, _ := s.CountedWindow(models.TimeRange{}); counted != (models.TimeRange{}) {
+		t.Errorf("Expected an unbounded window to stay unbounded, got %+v", counted)
+	}
+}
+
+func TestMemoryStorePurgeReleasesRollups(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Date(2023, 9, 10, 12, 0, 0, 0, time.UTC)
+	old := time.Date(2023, 9, 5, 10, 15, 0, 0, time.UTC)
+	events := []models.GitHubEvent{
+		{ID: "1", Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: old},
+		{ID: "2", Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: old.Add(25 * time.Minute)},
+		{ID: "3", Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo"}, CreatedAt: now},
+	}
+	for _, event := range events {
+		s.StoreEvent(event)
+	}
+
+	// Compacted into a day rollup, the old events are still uncounted when purged
+	s.CompactRollups(models.RollupTiers{Minute: time.Hour, Hour: 48 * time.Hour}, now)
+	if _, err := s.Purge(models.RetentionPolicy{DefaultDays: 2}, now, false); err != nil {
+		t.Fatal(err)
+	}
+	if counts, _ := s.EventCounts(models.TimeRange{}); !reflect.DeepEqual(counts, map[string]int{"PushEvent": 1}) {
+		t.Errorf("Expected only the kept event to be counted, got %v", counts)
+	}
+	if len(s.rollups) != 1 {
+		t.Errorf("Expected the emptied rollup to be deleted, got %v", s.rollups)
+	}
+
+	// Restoring a purged event counts it once
+	if inserted, _ := s.ImportEvent(events[0]); !inserted {
+		t.Fatal("Expected the purged event to be restored")
+	}
+	if counts, _ := s.EventCounts(models.TimeRange{}); !reflect.DeepEqual(counts, map[string]int{"PushEvent": 2}) {
+		t.Errorf("Expected the restored event to be counted once, got %v", counts)
+	}
+}
+
+func TestMemoryStoreErase(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	push := func(actor, email string) {
+		s.StoreEvent(models.GitHubEvent{
+			Type:      "PushEvent",
+			Actor:     models.Actor{Login: actor},
+			Repo:      models.Repo{URL: "repo"},
+			Payload:   models.Payload{Commits: []models.Commit{{Author: models.Author{Email: email}}}},
+			CreatedAt: now,
+		})
+	}
+	push("alice", "alice@example.com")
+	push("bob", "bob@example.com")
+	s.StoreIssueUpdate(models.IssueUpdate{RepoURL: "repo", Number: 1, Author: "alice", Actor: "alice", Action: "opened", OpenedAt: now, OccurredAt: now})
+
+	// Logins are matched regardless of case
+	report, err := s.Erase(models.ErasureRequest{Login: "Alice"}, now)
+	if err != nil {
+		t.Fatalf("Error erasing actor: %v", err)
+	}
+	if report.Rows["github"] != 1 || report.Rows["github_actors"] != 1 || report.Rows["github_issues"] != 1 || report.Rows["event_rollups"] != 1 || len(report.Retained) != 2 {
+		t.Errorf("Unexpected erasure report: %+v", report)
+	}
+	if _, err := s.Erase(models.ErasureRequest{Email: "bob@example.com"}, now); err != nil {
+		t.Fatalf("Error erasing email: %v", err)
+	}
+
+	// Later events of erased identities are scrubbed on ingestion
+	push("ALICE", "bob@example.com")
+	actors, _, _ := s.UniqueActors(models.TimeRange{}, models.Page{Limit: 100})
+	emails, _ := s.UniqueEmails(models.TimeRange{})
+	if !reflect.DeepEqual(logins(actors), []string{"bob", report.Pseudonym}) || !reflect.DeepEqual(emails, []string{"alice@example.com"}) {
+		t.Errorf("Unexpected entities after erasure: actors=%v emails=%v", actors, emails)
+	}
+
+	// Counts are kept under the pseudonym
+	counts, _ := s.EventCounts(models.TimeRange{})
+	if counts["PushEvent"] != 3 {
+		t.Errorf("Expected counts to survive erasure, got %v", counts)
+	}
+}
+
+func TestMemoryStoreBatchLookups(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i, event := range []models.GitHubEvent{
+		{Type: "PushEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}},
+		{Type: "PushEvent", Actor: models.Actor{Login: "bob"}, Repo: models.Repo{URL: "repo1"}},
+		{Type: "WatchEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo2"}},
+		{Type: "IssuesEvent", Actor: models.Actor{Login: "alice"}, Repo: models.Repo{URL: "repo1"}},
+	} {
+		event.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		if _, err := s.StoreEvent(event); err != nil {
+			t.Fatalf("Error storing event: %v", err)
+		}
+	}
+
+	profiles, err := s.ActorProfilesByLogin([]string{"alice", "bob", "nobody"}, models.TimeRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles["alice"].Events != 3 || profiles["bob"].Events != 1 {
+		t.Errorf("Unexpected profiles %+v", profiles)
+	}
+
+	stats, err := s.RepoStatsByURL([]string{"repo1", "repo3"}, models.TimeRange{Since: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats["repo1"].Events != 2 || stats["repo1"].Contributors != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	latest, err := s.LatestActorEvents([]string{"alice", "bob"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, event := range latest["alice"] {
+		types = append(types, event.Type)
+	}
+	if !reflect.DeepEqual(types, []string{"IssuesEvent", "WatchEvent"}) || len(latest["bob"]) != 1 {
+		t.Errorf("Unexpected latest events %+v", latest)
+	}
+
+	byRepo, err := s.LatestRepoEvents([]string{"repo2"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byRepo) != 1 || len(byRepo["repo2"]) != 1 || byRepo["repo2"][0].Actor != "alice" {
+		t.Errorf("Unexpected latest repository events %+v", byRepo)
+	}
+}
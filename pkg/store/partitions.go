@@ -0,0 +1,166 @@
+package store
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The github and github_event_emails tables are range-partitioned by day
+// (see migration 0005). Partitions are named <table>_pYYYYMMDD.
+const partitionDateFormat = "20060102"
+
+// day truncates t to the start of its UTC day, the granularity of partitions.
+func day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// CreatePartitions creates the daily partitions covering from through to, inclusive.
+func (s *PostgresStore) CreatePartitions(from, to time.Time) error {
+	_, err := s.db.Exec("SELECT github_create_partitions($1::date, $2::date)", day(from), day(to))
+	if err != nil {
+		return err
+	}
+
+	s.partitionsMu.Lock()
+	defer s.partitionsMu.Unlock()
+	for d := day(from); !d.After(day(to)); d = d.AddDate(0, 0, 1) {
+		s.partitions[d] = true
+	}
+	return nil
+}
+
+// ensurePartition makes sure the partition for the day of t exists before an
+// event from that day is inserted.
+func (s *PostgresStore) ensurePartition(t time.Time) error {
+	d := day(t)
+	s.partitionsMu.Lock()
+	exists := s.partitions[d]
+	s.partitionsMu.Unlock()
+	if exists {
+		return nil
+	}
+	return s.CreatePartitions(d, d)
+}
+
+// MaintainPartitions creates partitions for today and the next daysAhead days
+// now and then again every interval, so inserts never wait on partition creation.
+func (s *PostgresStore) MaintainPartitions(daysAhead int, interval time.Duration) {
+	maintain := func() {
+		now := time.Now()
+		if err := s.CreatePartitions(now, now.AddDate(0, 0, daysAhead)); err != nil {
+			log.Printf("Error creating partitions: %v", err)
+		}
+	}
+
+	maintain()
+	go func() {
+		for range time.Tick(interval) {
+			maintain()
+		}
+	}()
+}
+
+// partitionDays returns the days that currently have a github partition.
+func (s *PostgresStore) partitionDays() ([]time.Time, error) {
+	rows, err := s.db.Query(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'github'::regclass
+		ORDER BY c.relname`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		d, err := time.Parse(partitionDateFormat, strings.TrimPrefix(name, "github_p"))
+		if err != nil {
+			log.Printf("Skipping unexpected partition %s", name)
+			continue
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// dropPartitionsBefore detaches and drops every partition whose day ends on
+// or before cutoff and that holds no events of the held repositories, and
+// returns the names of the dropped partitions.
+func (s *PostgresStore) dropPartitionsBefore(cutoff time.Time, held []string) ([]string, error) {
+	days, err := s.partitionDays()
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, d := range days {
+		if d.AddDate(0, 0, 1).After(cutoff) {
+			break
+		}
+		name := "github_p" + d.Format(partitionDateFormat)
+
+		var hasHeld bool
+		if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM "+name+" WHERE repo_url = ANY($1))", pq.Array(held)).Scan(&hasHeld); err != nil {
+			return dropped, err
+		}
+		if hasHeld {
+			continue
+		}
+
+		if _, err := s.dropPartition(d); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
+
+// dropPartition uncounts the events of a day from the rollups and detaches and
+// drops its github and github_event_emails partitions.
+func (s *PostgresStore) dropPartition(d time.Time) (int64, error) {
+	suffix := "_p" + d.Format(partitionDateFormat)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int64
+	if err := tx.QueryRow("SELECT COUNT(*) FROM github" + suffix).Scan(&count); err != nil {
+		return 0, err
+	}
+	err = releaseRollupsOf(tx, `WITH gone AS (
+		SELECT COALESCE(event_type, '') AS event_type, COALESCE(repo_url, '') AS repo_url, COALESCE(actor, '') AS actor, created_at
+		FROM github`+suffix+`
+	)`)
+	if err != nil {
+		return 0, err
+	}
+	for _, table := range []string{"github", "github_event_emails"} {
+		if _, err := tx.Exec("ALTER TABLE " + table + " DETACH PARTITION " + table + suffix); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec("DROP TABLE " + table + suffix); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	s.partitionsMu.Lock()
+	delete(s.partitions, d)
+	s.partitionsMu.Unlock()
+
+	log.Printf("Dropped partition %s with %d events", d.Format("2006-01-02"), count)
+	return count, nil
+}
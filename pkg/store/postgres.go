@@ -0,0 +1,322 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"awsomeProject/pkg/pii"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PostgresStore is an EventStore backed by the PostgreSQL schema defined in pkg/migrations.
+type PostgresStore struct {
+	db *sql.DB
+
+	// keys encrypts and hashes commit author emails
+	keys *pii.Keyring
+
+	// partitions caches the days known to have a partition
+	partitionsMu sync.Mutex
+	partitions   map[time.Time]bool
+}
+
+// NewPostgresStore returns a store using the given database handle and
+// keyring for commit author emails.
+func NewPostgresStore(db *sql.DB, keys *pii.Keyring) *PostgresStore {
+	return &PostgresStore{db: db, keys: keys, partitions: make(map[time.Time]bool)}
+}
+
+// StoreEvent inserts a GitHub event into the github table, upserting its
+// actor, repository and commit author emails, linking the event to them and
+// counting it in the minute rollups. Erased identities are scrubbed first.
+// An event whose GitHub ID is already stored is skipped. It reports whether
+// the event was inserted.
+func (s *PostgresStore) StoreEvent(event models.GitHubEvent) (bool, error) {
+	return s.insertEvent(event, event.ID)
+}
+
+// ImportEvent stores an event like StoreEvent, keyed by GitHubEvent.Key so
+// events without an ID are imported once too, and reports whether it was inserted.
+func (s *PostgresStore) ImportEvent(event models.GitHubEvent) (bool, error) {
+	return s.insertEvent(event, event.Key())
+}
+
+// insertEvent stores an event under key unless that key is stored already.
+// An empty key stores the event unconditionally.
+func (s *PostgresStore) insertEvent(event models.GitHubEvent, key string) (bool, error) {
+	if err := s.ensurePartition(event.CreatedAt); err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	event, err = s.scrubEvent(tx, event)
+	if err != nil {
+		return false, err
+	}
+
+	actorID, err := upsertEntity(tx, "github_actors", "login", event.Actor.Login, event.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	repoID, err := upsertEntity(tx, "github_repositories", "url", event.Repo.URL, event.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	var eventID int64
+	source := event.Source
+	if source == "" {
+		source = models.SourceGitHub
+	}
+	err = tx.QueryRow(`INSERT INTO github (external_id, event_type, actor, repo_url, created_at, actor_id, repo_id, source, commit_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (external_id, created_at) DO NOTHING RETURNING id`,
+		sql.NullString{String: key, Valid: key != ""}, event.Type, event.Actor.Login, event.Repo.URL, event.CreatedAt, actorID, repoID, source,
+		event.Payload.CommitCount()).Scan(&eventID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := incrementRollup(tx, event); err != nil {
+		return false, err
+	}
+
+	for _, commit := range event.Payload.Commits {
+		if commit.Author.Email == "" {
+			continue
+		}
+		emailID, err := s.upsertEmail(tx, commit.Author.Email, event.CreatedAt)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.Exec("INSERT INTO github_event_emails (event_id, created_at, email_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", eventID, event.CreatedAt, emailID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// upsertEntity inserts a row into one of the actor, repository or email tables,
+// widening its first_seen/last_seen range to include seenAt, and returns its id.
+func upsertEntity(tx *sql.Tx, table, column, value string, seenAt time.Time) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`INSERT INTO `+table+` (`+column+`, first_seen, last_seen) VALUES ($1, $2, $2)
+		ON CONFLICT (`+column+`) DO UPDATE SET
+			first_seen = LEAST(`+table+`.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(`+table+`.last_seen, EXCLUDED.last_seen)
+		RETURNING id`, value, seenAt).Scan(&id)
+	return id, err
+}
+
+// StoreIssueUpdate records an issue transition and applies it to github_issues.
+// Erased logins are replaced by their pseudonyms first.
+func (s *PostgresStore) StoreIssueUpdate(update models.IssueUpdate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	erasedLogin, err := s.erasedLogins(tx, update.Author, update.Actor)
+	if err != nil {
+		return err
+	}
+	update = scrubIssueUpdate(update, erasedLogin)
+
+	if update.Action != "commented" {
+		_, err = tx.Exec("INSERT INTO github_issue_transitions (repo_url, number, action, actor, occurred_at) VALUES ($1, $2, $3, $4, $5)",
+			update.RepoURL, update.Number, update.Action, update.Actor, update.OccurredAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := upsertIssue(tx, update); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// upsertIssue applies an issue update to the github_issues table.
+func upsertIssue(tx *sql.Tx, update models.IssueUpdate) error {
+	var query string
+	var closedAt *time.Time
+	state := "open"
+	reopenCount := 0
+
+	switch update.Action {
+	case "opened":
+		query = `ON CONFLICT (repo_url, number) DO UPDATE SET
+			author = EXCLUDED.author,
+			opened_at = EXCLUDED.opened_at`
+	case "closed":
+		state = "closed"
+		closedAt = &update.OccurredAt
+		query = `ON CONFLICT (repo_url, number) DO UPDATE SET
+			state = 'closed',
+			closed_at = EXCLUDED.closed_at`
+	case "reopened":
+		reopenCount = 1
+		query = `ON CONFLICT (repo_url, number) DO UPDATE SET
+			state = 'open',
+			closed_at = NULL,
+			reopen_count = github_issues.reopen_count + 1`
+	case "commented":
+		query = `ON CONFLICT (repo_url, number) DO UPDATE SET
+			first_response_at = LEAST(github_issues.first_response_at, EXCLUDED.first_response_at)`
+	}
+
+	_, err := tx.Exec(`INSERT INTO github_issues (repo_url, number, author, state, opened_at, closed_at, first_response_at, reopen_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) `+query,
+		update.RepoURL, update.Number, update.Author, state, update.OpenedAt, closedAt, update.FirstResponse, reopenCount)
+	return err
+}
+
+// Events returns every event in the github table.
+func (s *PostgresStore) Events() ([]models.GitHubEvent, error) {
+	rows, err := s.db.Query("SELECT event_type, actor, repo_url, created_at FROM github")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.GitHubEvent
+	for rows.Next() {
+		var event models.GitHubEvent
+		err := rows.Scan(&event.Type, &event.Actor.Login, &event.Repo.URL, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// EventCounts returns the number of events per event type in the window,
+// summed over the rollups of every resolution whose bucket starts in it.
+func (s *PostgresStore) EventCounts(window models.TimeRange) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT event_type, SUM(count) FROM event_rollups
+		WHERE `+fmt.Sprintf(inWindow, "bucket")+`
+		GROUP BY event_type`, windowArgs(window)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eventTypeCount := make(map[string]int)
+	for rows.Next() {
+		var eventType string
+		var count int
+		if err := rows.Scan(&eventType, &count); err != nil {
+			return nil, err
+		}
+		eventTypeCount[eventType] = count
+	}
+
+	return eventTypeCount, rows.Err()
+}
+
+// activeIn restricts entity rows, identified by id, to those linked through
+// column of table to an event in the window passed as $1 and $2.
+func activeIn(table, column, id string) string {
+	return fmt.Sprintf("(($1::timestamp IS NULL AND $2::timestamp IS NULL) OR EXISTS (SELECT 1 FROM %s g WHERE g.%s = %s AND %s))",
+		table, column, id, fmt.Sprintf(inWindow, "g.created_at"))
+}
+
+// distinct runs a single-column query and collects the results.
+func (s *PostgresStore) distinct(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// IssueMetrics computes median response and close times per repository.
+func (s *PostgresStore) IssueMetrics(repoURL string) ([]models.IssueMetrics, error) {
+	rows, err := s.db.Query(`
+		SELECT repo_url,
+			COUNT(*) FILTER (WHERE state = 'open'),
+			COUNT(*) FILTER (WHERE state = 'closed'),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM first_response_at - opened_at))
+				FILTER (WHERE first_response_at IS NOT NULL),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM closed_at - opened_at))
+				FILTER (WHERE closed_at IS NOT NULL)
+		FROM github_issues
+		WHERE $1 = '' OR repo_url = $1
+		GROUP BY repo_url
+		ORDER BY repo_url`, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []models.IssueMetrics{}
+	for rows.Next() {
+		var m models.IssueMetrics
+		var firstResponse, timeToClose sql.NullFloat64
+		if err := rows.Scan(&m.RepoURL, &m.OpenIssues, &m.ClosedIssues, &firstResponse, &timeToClose); err != nil {
+			return nil, err
+		}
+		if firstResponse.Valid {
+			m.MedianFirstResponseSeconds = &firstResponse.Float64
+		}
+		if timeToClose.Valid {
+			m.MedianTimeToCloseSeconds = &timeToClose.Float64
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, rows.Err()
+}
+
+// IssueBacklog counts the issues open at the end of each of the last days days.
+func (s *PostgresStore) IssueBacklog(repoURL string, days int) ([]models.BacklogPoint, error) {
+	rows, err := s.db.Query(`
+		SELECT i.repo_url, d.day, COUNT(*)
+		FROM generate_series(current_date - ($1::int - 1), current_date, interval '1 day') AS d(day)
+		JOIN github_issues i
+			ON i.opened_at < d.day + interval '1 day'
+			AND (i.closed_at IS NULL OR i.closed_at >= d.day + interval '1 day')
+		WHERE $2 = '' OR i.repo_url = $2
+		GROUP BY i.repo_url, d.day
+		ORDER BY i.repo_url, d.day`, days, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backlog := []models.BacklogPoint{}
+	for rows.Next() {
+		var p models.BacklogPoint
+		if err := rows.Scan(&p.RepoURL, &p.Day, &p.OpenIssues); err != nil {
+			return nil, err
+		}
+		backlog = append(backlog, p)
+	}
+
+	return backlog, rows.Err()
+}
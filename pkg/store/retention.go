@@ -0,0 +1,164 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// classifyEvents is the common prefix of the retention queries. It labels every
+// event with the rule of the policy that governs it (see RetentionPolicy.Classify)
+// and its retention in days. Parameters:
+// $1/$2 repository rules, $3/$4 event type rules, $5 default days, $6 legal hold repositories.
+const classifyEvents = `
+	WITH repo_rules(repo_url, days) AS (SELECT * FROM unnest($1::text[], $2::int[])),
+	type_rules(event_type, days) AS (SELECT * FROM unnest($3::text[], $4::int[])),
+	classified AS (
+		SELECT g.id, g.created_at,
+			CASE WHEN g.repo_url = ANY($6::text[]) THEN 'legal_hold'
+				WHEN r.repo_url IS NOT NULL THEN 'repo'
+				WHEN t.event_type IS NOT NULL THEN 'event_type'
+				ELSE 'default' END AS rule,
+			CASE WHEN g.repo_url = ANY($6::text[]) OR r.repo_url IS NOT NULL THEN g.repo_url
+				WHEN t.event_type IS NOT NULL THEN g.event_type
+				ELSE '' END AS match,
+			COALESCE(r.days, t.days, $5::int) AS days
+		FROM github g
+		LEFT JOIN repo_rules r ON r.repo_url = g.repo_url
+		LEFT JOIN type_rules t ON t.event_type = g.event_type
+	),
+	expired AS (
+		SELECT * FROM classified
+		WHERE days > 0 AND created_at < $7::timestamp - days * interval '1 day'
+	)`
+
+// policyArgs returns the query parameters for classifyEvents followed by now as $7.
+func policyArgs(policy models.RetentionPolicy, now time.Time) []interface{} {
+	var repos, types []string
+	var repoDays, typeDays []int64
+	for repo, days := range policy.Repos {
+		repos = append(repos, repo)
+		repoDays = append(repoDays, int64(days))
+	}
+	for eventType, days := range policy.EventTypes {
+		types = append(types, eventType)
+		typeDays = append(typeDays, int64(days))
+	}
+	return []interface{}{
+		pq.Array(repos), pq.Array(repoDays),
+		pq.Array(types), pq.Array(typeDays),
+		policy.DefaultDays, pq.Array(policy.LegalHold), now,
+	}
+}
+
+// Purge deletes the events that have expired under the policy as of now.
+// Day partitions in which every event has expired and none is held are
+// dropped whole; remaining expired events are deleted row by row. Deleted
+// events are uncounted from the rollups. Actors,
+// repositories and emails left without events are removed too. With dryRun
+// set nothing is deleted and the report says what would have been.
+func (s *PostgresStore) Purge(policy models.RetentionPolicy, now time.Time, dryRun bool) (models.PurgeReport, error) {
+	report := models.PurgeReport{StartedAt: now, DryRun: dryRun, Policy: policy}
+	args := policyArgs(policy, now)
+
+	rows, err := s.db.Query(classifyEvents+`
+		SELECT rule, match, MIN(days), COUNT(*) FROM expired GROUP BY rule, match ORDER BY rule, match`, args...)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rule, match string
+		var days int
+		var count int64
+		if err := rows.Scan(&rule, &match, &days, &count); err != nil {
+			return report, err
+		}
+		report.Add(rule, match, days, count)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	if dryRun || report.TotalEvents == 0 {
+		return report, nil
+	}
+
+	if maxDays := policy.MaxDays(); maxDays > 0 {
+		dropped, err := s.dropPartitionsBefore(day(models.Cutoff(now, maxDays)), policy.LegalHold)
+		report.PartitionsDropped = dropped
+		if err != nil {
+			return report, err
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+	err = releaseRollupsOf(tx, classifyEvents+`,
+		deleted_links AS (
+			DELETE FROM github_event_emails e USING expired x
+			WHERE x.rule <> 'legal_hold' AND e.event_id = x.id AND e.created_at = x.created_at
+		),
+		gone AS (
+			DELETE FROM github g USING expired x
+			WHERE x.rule <> 'legal_hold' AND g.id = x.id AND g.created_at = x.created_at
+			RETURNING COALESCE(g.event_type, '') AS event_type, COALESCE(g.repo_url, '') AS repo_url,
+				COALESCE(g.actor, '') AS actor, g.created_at
+		)`, args...)
+	if err != nil {
+		return report, err
+	}
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+
+	return report, s.pruneEntities()
+}
+
+// ExpiredEvents returns the events that Purge would delete under the policy as
+// of now, including the decrypted commit author emails linked to them, oldest first.
+func (s *PostgresStore) ExpiredEvents(policy models.RetentionPolicy, now time.Time) ([]models.GitHubEvent, error) {
+	rows, err := s.db.Query(classifyEvents+`
+		SELECT `+eventColumns+`
+		FROM expired x
+		JOIN github g ON g.id = x.id AND g.created_at = x.created_at
+		LEFT JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		LEFT JOIN github_emails m ON m.id = e.email_id
+		WHERE x.rule <> 'legal_hold'
+		GROUP BY g.id, g.created_at
+		ORDER BY g.created_at, g.id`, policyArgs(policy, now)...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanEvents(rows)
+}
+
+// pruneEntities removes actors, repositories and emails no longer referenced by any event.
+func (s *PostgresStore) pruneEntities() error {
+	for _, query := range []string{
+		"DELETE FROM github_actors a WHERE NOT EXISTS (SELECT 1 FROM github g WHERE g.actor_id = a.id)",
+		"DELETE FROM github_repositories r WHERE NOT EXISTS (SELECT 1 FROM github g WHERE g.repo_id = r.id)",
+		"DELETE FROM github_emails m WHERE NOT EXISTS (SELECT 1 FROM github_event_emails e WHERE e.email_id = m.id)",
+	} {
+		if _, err := s.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordPurge writes an audit record of a purge to retention_audit.
+func (s *PostgresStore) RecordPurge(report models.PurgeReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("INSERT INTO retention_audit (started_at, total_events, held_events, report) VALUES ($1, $2, $3, $4)",
+		report.StartedAt, report.TotalEvents, report.HeldEvents, data)
+	return err
+}
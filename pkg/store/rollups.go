@@ -0,0 +1,268 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// incrementRollup counts an event in its minute rollup.
+func incrementRollup(tx *sql.Tx, event models.GitHubEvent) error {
+	_, err := tx.Exec(`INSERT INTO event_rollups (resolution, bucket, event_type, repo_url, actor, count)
+		VALUES ('minute', date_trunc('minute', $1::timestamp), $2, $3, $4, 1)
+		ON CONFLICT (resolution, bucket, event_type, repo_url, actor) DO UPDATE SET count = event_rollups.count + 1`,
+		event.CreatedAt, event.Type, event.Repo.URL, event.Actor.Login)
+	return err
+}
+
+// rollupResolutions are the rollup resolutions from finest to coarsest.
+var rollupResolutions = []string{models.ResolutionMinute, models.ResolutionHour, models.ResolutionDay}
+
+// releaseRollup uncounts a deleted event from the rollup row holding it, the
+// row of the finest resolution with a count in the event's bucket, as
+// compaction may have moved it to a coarser one. Events whose day rollups
+// have expired are not counted anywhere. The caller must hold the lock.
+func (s *MemoryStore) releaseRollup(event models.GitHubEvent) {
+	for _, resolution := range rollupResolutions {
+		key := rollupKey{resolution, event.CreatedAt.UTC().Truncate(models.ResolutionWidths[resolution]), event.Type, event.Repo.URL, event.Actor.Login}
+		if s.rollups[key] == 0 {
+			continue
+		}
+		if s.rollups[key]--; s.rollups[key] == 0 {
+			delete(s.rollups, key)
+		}
+		return
+	}
+}
+
+// releaseRollups completes a statement whose gone CTE lists the event_type,
+// repo_url, actor and created_at of deleted events. It uncounts the events from
+// the rollup rows holding them, like MemoryStore.releaseRollup.
+const releaseRollups = `,
+	counted AS (
+		SELECT date_trunc('minute', created_at) AS minute, event_type, repo_url, actor, COUNT(*) AS n
+		FROM gone GROUP BY 1, 2, 3, 4
+	),
+	targets AS (
+		SELECT c.*, (
+			SELECT r.resolution FROM event_rollups r
+			WHERE r.event_type = c.event_type AND r.repo_url = c.repo_url AND r.actor = c.actor
+				AND r.bucket = date_trunc(r.resolution, c.minute)
+			ORDER BY array_position(ARRAY['minute', 'hour', 'day'], r.resolution::text)
+			LIMIT 1
+		) AS resolution
+		FROM counted c
+	)
+	UPDATE event_rollups r SET count = r.count - t.n
+	FROM (
+		SELECT resolution, date_trunc(resolution, minute) AS bucket, event_type, repo_url, actor, SUM(n) AS n
+		FROM targets WHERE resolution IS NOT NULL
+		GROUP BY 1, 2, 3, 4, 5
+	) t
+	WHERE r.resolution = t.resolution AND r.bucket = t.bucket AND r.event_type = t.event_type
+		AND r.repo_url = t.repo_url AND r.actor = t.actor`
+
+// releaseRollupsOf runs gone, a WITH clause ending in a gone CTE as described
+// at releaseRollups, uncounts the events it lists from the rollups and deletes
+// the rollup rows left empty.
+func releaseRollupsOf(tx *sql.Tx, gone string, args ...interface{}) error {
+	if _, err := tx.Exec(gone+releaseRollups, args...); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM event_rollups WHERE count <= 0")
+	return err
+}
+
+// compactRollups moves the rows of one resolution older than cutoff into the
+// next coarser resolution and returns the number of rows written.
+const compactRollups = `
+	WITH moved AS (
+		DELETE FROM event_rollups WHERE resolution = $1::text AND bucket < $3
+		RETURNING bucket, event_type, repo_url, actor, count
+	)
+	INSERT INTO event_rollups (resolution, bucket, event_type, repo_url, actor, count)
+	SELECT $2::text, date_trunc($2::text, bucket), event_type, repo_url, actor, SUM(count) FROM moved
+	GROUP BY 2, 3, 4, 5
+	ON CONFLICT (resolution, bucket, event_type, repo_url, actor) DO UPDATE SET count = event_rollups.count + EXCLUDED.count`
+
+// CompactRollups downsamples aged minute rollups into hours and aged hour
+// rollups into days, and deletes day rollups past their tier.
+func (s *PostgresStore) CompactRollups(tiers models.RollupTiers, now time.Time) (models.CompactionReport, error) {
+	var report models.CompactionReport
+	tx, err := s.db.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	minuteCutoff, hourCutoff, dayCutoff := tierCutoffs(tiers, now)
+	result, err := tx.Exec(compactRollups, models.ResolutionMinute, models.ResolutionHour, minuteCutoff)
+	if err != nil {
+		return report, err
+	}
+	if report.HourRows, err = result.RowsAffected(); err != nil {
+		return report, err
+	}
+
+	result, err = tx.Exec(compactRollups, models.ResolutionHour, models.ResolutionDay, hourCutoff)
+	if err != nil {
+		return report, err
+	}
+	if report.DayRows, err = result.RowsAffected(); err != nil {
+		return report, err
+	}
+
+	if !dayCutoff.IsZero() {
+		result, err = tx.Exec("DELETE FROM event_rollups WHERE resolution = $1 AND bucket < $2", models.ResolutionDay, dayCutoff)
+		if err != nil {
+			return report, err
+		}
+		if report.DeletedDays, err = result.RowsAffected(); err != nil {
+			return report, err
+		}
+	}
+
+	return report, tx.Commit()
+}
+
+// tierCutoffs returns the bucket times before which minute and hour rollups
+// are compacted and day rollups deleted. Cutoffs are aligned to the coarser
+// resolution so that only whole hours and days are compacted. The day cutoff
+// is zero when day rollups are kept forever.
+func tierCutoffs(tiers models.RollupTiers, now time.Time) (minute, hour, day time.Time) {
+	now = now.UTC()
+	minute = now.Add(-tiers.Minute).Truncate(time.Hour)
+	hour = now.Add(-tiers.Hour).Truncate(24 * time.Hour)
+	if tiers.Day > 0 {
+		day = now.Add(-tiers.Day).Truncate(24 * time.Hour)
+	}
+	return minute, hour, day
+}
+
+// rollupEnd is the end of the bucket of an event_rollups row.
+const rollupEnd = "(bucket + ('1 ' || resolution::text)::interval)"
+
+// CountedWindow returns the time span whose events the rollups matching the
+// window by bucket start hold.
+func (s *PostgresStore) CountedWindow(window models.TimeRange) (models.TimeRange, error) {
+	var first, last sql.NullTime
+	err := s.db.QueryRow(`SELECT
+			(SELECT MAX(`+rollupEnd+`) FROM event_rollups
+				WHERE bucket < $1 AND bucket > $1::timestamp - interval '1 day' AND `+rollupEnd+` > $1),
+			(SELECT MAX(`+rollupEnd+`) FROM event_rollups WHERE $2::timestamp IS NOT NULL AND `+fmt.Sprintf(inWindow, "bucket")+`)`,
+		windowArgs(window)...).Scan(&first, &last)
+	if err != nil {
+		return window, err
+	}
+	return countedWindow(window, first.Time, last.Time), nil
+}
+
+// CountedWindow returns the time span whose events the rollups matching the
+// window by bucket start hold.
+func (s *MemoryStore) CountedWindow(window models.TimeRange) (models.TimeRange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var first, last time.Time
+	for key := range s.rollups {
+		end := key.bucket.Add(models.ResolutionWidths[key.resolution])
+		if !window.Since.IsZero() && key.bucket.Before(window.Since) && end.After(window.Since) && end.After(first) {
+			first = end
+		}
+		if !window.Until.IsZero() && window.Contains(key.bucket) && end.After(last) {
+			last = end
+		}
+	}
+	return countedWindow(window, first, last), nil
+}
+
+// countedWindow moves the start of a window to first and its end to last
+// when they lie beyond them. Zero times leave the window as is.
+func countedWindow(window models.TimeRange, first, last time.Time) models.TimeRange {
+	if !window.Since.IsZero() && first.After(window.Since) {
+		window.Since = first
+	}
+	if !window.Until.IsZero() && last.After(window.Until) {
+		window.Until = last
+	}
+	return window
+}
+
+// EventTimeseries returns the number of events per event type and bucket of
+// the resolution, summed over the rollups whose bucket starts in the filter's
+// window. Rollups coarser than the resolution count at the start of their
+// bucket, and points holding them report the coarser resolution. The
+// filter's Sources are not applied, as rollups do not record them.
+func (s *PostgresStore) EventTimeseries(resolution string, filter models.EventFilter) ([]models.CountPoint, error) {
+	rows, err := s.db.Query(`SELECT date_trunc($6::text, bucket) AS b, event_type, SUM(count),
+			(ARRAY['minute', 'hour', 'day'])[MAX(array_position(ARRAY['minute', 'hour', 'day'], resolution::text))]
+		FROM event_rollups
+		WHERE `+fmt.Sprintf(inWindow, "bucket")+`
+			AND (COALESCE(cardinality($3::text[]), 0) = 0 OR event_type = ANY($3))
+			AND (COALESCE(cardinality($4::text[]), 0) = 0 OR repo_url = ANY($4))
+			AND (COALESCE(cardinality($5::text[]), 0) = 0 OR actor = ANY($5))
+		GROUP BY b, event_type
+		ORDER BY b, event_type`,
+		append(windowArgs(filter.TimeRange), pq.Array(filter.Types), pq.Array(filter.Repos), pq.Array(filter.Actors), resolution)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []models.CountPoint{}
+	for rows.Next() {
+		var point models.CountPoint
+		if err := rows.Scan(&point.Bucket, &point.EventType, &point.Count, &point.Resolution); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// EventTimeseries returns the number of events per event type and bucket of
+// the resolution, summed over the rollups whose bucket starts in the filter's
+// window. Rollups coarser than the resolution count at the start of their
+// bucket, and points holding them report the coarser resolution. The
+// filter's Sources are not applied.
+func (s *MemoryStore) EventTimeseries(resolution string, filter models.EventFilter) ([]models.CountPoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type pointKey struct {
+		bucket    time.Time
+		eventType string
+	}
+	width := models.ResolutionWidths[resolution]
+	counts := make(map[pointKey]int64)
+	coarsest := make(map[pointKey]int)
+	filter.Sources = nil
+	for key, count := range s.rollups {
+		event := models.GitHubEvent{Type: key.eventType, Actor: models.Actor{Login: key.actor}, Repo: models.Repo{URL: key.repoURL}, CreatedAt: key.bucket}
+		if filter.Match(event) {
+			point := pointKey{key.bucket.Truncate(width), key.eventType}
+			counts[point] += count
+			for i, r := range rollupResolutions {
+				if r == key.resolution && i > coarsest[point] {
+					coarsest[point] = i
+				}
+			}
+		}
+	}
+
+	points := []models.CountPoint{}
+	for key, count := range counts {
+		points = append(points, models.CountPoint{Bucket: key.bucket, EventType: key.eventType, Count: count, Resolution: rollupResolutions[coarsest[key]]})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if !points[i].Bucket.Equal(points[j].Bucket) {
+			return points[i].Bucket.Before(points[j].Bucket)
+		}
+		return points[i].EventType < points[j].EventType
+	})
+	return points, nil
+}
@@ -0,0 +1,434 @@
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// Lengths of the lists in RepoStats
+const (
+	statsTopActors    = 10
+	statsBusiestHours = 5
+)
+
+// single returns the entry for key of a batch lookup, or ErrNotFound.
+func single[T any](entries map[string]T, key string, err error) (T, error) {
+	entry, ok := entries[key]
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return entry, err
+}
+
+// reposEvents restricts events of github g to the repositories with the urls
+// $3 in the time range passed as $1 and $2.
+var reposEvents = `g.repo_id IN (SELECT id FROM github_repositories WHERE url = ANY($3)) AND ` + fmt.Sprintf(inWindow, "g.created_at")
+
+// RepoStats summarizes the events of a repository in the window from the
+// github table, or returns ErrNotFound for a repository that is not stored.
+func (s *PostgresStore) RepoStats(repoURL string, window models.TimeRange) (models.RepoStats, error) {
+	all, err := s.RepoStatsByURL([]string{repoURL}, window)
+	return single(all, repoURL, err)
+}
+
+// RepoStatsByURL summarizes the events of each stored repository of urls in
+// the window from the github table, with one query per part of the summary.
+func (s *PostgresStore) RepoStatsByURL(urls []string, window models.TimeRange) (map[string]models.RepoStats, error) {
+	rows, err := s.db.Query("SELECT url FROM github_repositories WHERE url = ANY($1)", pq.Array(urls))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	all := make(map[string]*models.RepoStats)
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		all[url] = &models.RepoStats{URL: url, EventsByType: map[string]int64{}, BusiestHours: []models.HourCount{}}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	args := append(windowArgs(window), pq.Array(urls))
+
+	rows, err = s.db.Query(`SELECT g.repo_url, COUNT(*), COUNT(DISTINCT g.actor), COALESCE(SUM(g.commit_count), 0), MIN(g.created_at), MAX(g.created_at)
+		FROM github g WHERE `+reposEvents+` GROUP BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var url string
+		var events, contributors, commits int64
+		var first, last sql.NullTime
+		if err := rows.Scan(&url, &events, &contributors, &commits, &first, &last); err != nil {
+			return nil, err
+		}
+		if stats, ok := all[url]; ok {
+			stats.Events, stats.Contributors, stats.Commits = events, contributors, commits
+			if first.Valid {
+				stats.FirstActivity, stats.LastActivity = &first.Time, &last.Time
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT g.repo_url, COALESCE(g.event_type, ''), COUNT(*) FROM github g WHERE `+reposEvents+` GROUP BY 1, 2`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var url, eventType string
+		var count int64
+		if err := rows.Scan(&url, &eventType, &count); err != nil {
+			return nil, err
+		}
+		if stats, ok := all[url]; ok {
+			stats.EventsByType[eventType] = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT repo_url, hour, events FROM (
+			SELECT g.repo_url, EXTRACT(HOUR FROM g.created_at)::int AS hour, COUNT(*) AS events,
+				ROW_NUMBER() OVER (PARTITION BY g.repo_url ORDER BY COUNT(*) DESC, EXTRACT(HOUR FROM g.created_at)::int) AS position
+			FROM github g WHERE `+reposEvents+` GROUP BY 1, 2) hours
+		WHERE position <= $4 ORDER BY repo_url, position`, append(args, statsBusiestHours)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var url string
+		var hour models.HourCount
+		if err := rows.Scan(&url, &hour.Hour, &hour.Events); err != nil {
+			return nil, err
+		}
+		if stats, ok := all[url]; ok {
+			stats.BusiestHours = append(stats.BusiestHours, hour)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT repo_url, actor, events FROM (
+			SELECT g.repo_url, g.actor, COUNT(*) AS events,
+				ROW_NUMBER() OVER (PARTITION BY g.repo_url ORDER BY COUNT(*) DESC, g.actor) AS position
+			FROM github g WHERE `+reposEvents+` AND g.actor IS NOT NULL GROUP BY 1, 2) actors
+		WHERE position <= $4 ORDER BY repo_url, position`, append(args, statsTopActors)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make(map[string][]ranked)
+	for rows.Next() {
+		var url string
+		var entry ranked
+		if err := rows.Scan(&url, &entry.value, &entry.events); err != nil {
+			return nil, err
+		}
+		entries[url] = append(entries[url], entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]models.RepoStats, len(all))
+	for url, stats := range all {
+		rankAll(entries[url])
+		stats.TopActors = actorRanks(entries[url])
+		result[url] = *stats
+	}
+	return result, nil
+}
+
+// RepoStats summarizes the events of a repository in the window, or returns
+// ErrNotFound for a repository that is not stored.
+func (s *MemoryStore) RepoStats(repoURL string, window models.TimeRange) (models.RepoStats, error) {
+	all, err := s.RepoStatsByURL([]string{repoURL}, window)
+	return single(all, repoURL, err)
+}
+
+// RepoStatsByURL summarizes the events of each stored repository of urls in the window.
+func (s *MemoryStore) RepoStatsByURL(urls []string, window models.TimeRange) (map[string]models.RepoStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[string]*models.RepoStats)
+	actors := make(map[string]map[string]int64)
+	hours := make(map[string]map[int]int64)
+	for _, url := range urls {
+		if _, ok := s.repos[url]; ok {
+			all[url] = &models.RepoStats{URL: url, EventsByType: map[string]int64{}}
+			actors[url] = make(map[string]int64)
+			hours[url] = make(map[int]int64)
+		}
+	}
+
+	for _, event := range s.events {
+		stats, ok := all[event.Repo.URL]
+		if !ok || !window.Contains(event.CreatedAt) {
+			continue
+		}
+		createdAt := event.CreatedAt
+		if stats.FirstActivity == nil || createdAt.Before(*stats.FirstActivity) {
+			stats.FirstActivity = &createdAt
+		}
+		if stats.LastActivity == nil || createdAt.After(*stats.LastActivity) {
+			stats.LastActivity = &createdAt
+		}
+		stats.Events++
+		stats.EventsByType[event.Type]++
+		stats.Commits += int64(event.Payload.CommitCount())
+		actors[event.Repo.URL][event.Actor.Login]++
+		hours[event.Repo.URL][event.CreatedAt.UTC().Hour()]++
+	}
+
+	result := make(map[string]models.RepoStats, len(all))
+	for url, stats := range all {
+		stats.Contributors = int64(len(actors[url]))
+		stats.BusiestHours = busiestHours(hours[url], statsBusiestHours)
+
+		entries := make([]ranked, 0, len(actors[url]))
+		for login, events := range actors[url] {
+			entries = append(entries, ranked{value: login, events: events})
+		}
+		rankAll(entries)
+		if len(entries) > statsTopActors {
+			entries = entries[:statsTopActors]
+		}
+		stats.TopActors = actorRanks(entries)
+		result[url] = *stats
+	}
+	return result, nil
+}
+
+// busiestHours returns up to limit hours of the day with the most events,
+// busiest first and earliest first among equals.
+func busiestHours(hours map[int]int64, limit int) []models.HourCount {
+	counts := []models.HourCount{}
+	for hour, events := range hours {
+		counts = append(counts, models.HourCount{Hour: hour, Events: events})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Events != counts[j].Events {
+			return counts[i].Events > counts[j].Events
+		}
+		return counts[i].Hour < counts[j].Hour
+	})
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts
+}
+
+// actorsEvents restricts events of github g to the actors with the logins $3
+// in the time range passed as $1 and $2.
+var actorsEvents = `g.actor_id IN (SELECT id FROM github_actors WHERE login = ANY($3)) AND ` + fmt.Sprintf(inWindow, "g.created_at")
+
+// ActorProfile summarizes the events of an actor in the window from the
+// github table, or returns ErrNotFound for an actor that is not stored.
+func (s *PostgresStore) ActorProfile(login string, window models.TimeRange) (models.ActorProfile, error) {
+	all, err := s.ActorProfilesByLogin([]string{login}, window)
+	return single(all, login, err)
+}
+
+// ActorProfilesByLogin summarizes the events of each stored actor of logins
+// in the window from the github table, with one query per part of the summary.
+func (s *PostgresStore) ActorProfilesByLogin(logins []string, window models.TimeRange) (map[string]models.ActorProfile, error) {
+	rows, err := s.db.Query("SELECT login FROM github_actors WHERE login = ANY($1)", pq.Array(logins))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	all := make(map[string]*models.ActorProfile)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+		all[login] = &models.ActorProfile{Login: login, EventsByType: map[string]int64{}, Repos: []models.RepoActivity{}, Emails: []string{}}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	args := append(windowArgs(window), pq.Array(logins))
+
+	rows, err = s.db.Query(`SELECT g.actor, COUNT(*), MIN(g.created_at), MAX(g.created_at) FROM github g WHERE `+actorsEvents+` GROUP BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var login string
+		var events int64
+		var first, last sql.NullTime
+		if err := rows.Scan(&login, &events, &first, &last); err != nil {
+			return nil, err
+		}
+		if profile, ok := all[login]; ok {
+			profile.Events = events
+			if first.Valid {
+				profile.FirstSeen, profile.LastSeen = &first.Time, &last.Time
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT g.actor, COALESCE(g.event_type, ''), COUNT(*) FROM github g WHERE `+actorsEvents+` GROUP BY 1, 2`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var login, eventType string
+		var count int64
+		if err := rows.Scan(&login, &eventType, &count); err != nil {
+			return nil, err
+		}
+		if profile, ok := all[login]; ok {
+			profile.EventsByType[eventType] = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT g.actor, g.repo_url, MAX(g.created_at), COUNT(*) FROM github g WHERE `+actorsEvents+` AND g.repo_url IS NOT NULL
+		GROUP BY 1, 2 ORDER BY 1, 3 DESC, 2`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var login string
+		var repo models.RepoActivity
+		if err := rows.Scan(&login, &repo.URL, &repo.LastSeen, &repo.Events); err != nil {
+			return nil, err
+		}
+		if profile, ok := all[login]; ok {
+			profile.Repos = append(profile.Repos, repo)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT DISTINCT g.actor, m.email_encrypted FROM github g
+		JOIN github_event_emails e ON e.event_id = g.id AND e.created_at = g.created_at
+		JOIN github_emails m ON m.id = e.email_id
+		WHERE `+actorsEvents+` AND m.email_encrypted IS NOT NULL`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var login, encrypted string
+		if err := rows.Scan(&login, &encrypted); err != nil {
+			return nil, err
+		}
+		email, err := s.keys.Decrypt(encrypted)
+		if err != nil {
+			return nil, err
+		}
+		if profile, ok := all[login]; ok {
+			profile.Emails = append(profile.Emails, email)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]models.ActorProfile, len(all))
+	for login, profile := range all {
+		sort.Strings(profile.Emails)
+		result[login] = *profile
+	}
+	return result, nil
+}
+
+// ActorProfile summarizes the events of an actor in the window, or returns
+// ErrNotFound for an actor that is not stored.
+func (s *MemoryStore) ActorProfile(login string, window models.TimeRange) (models.ActorProfile, error) {
+	all, err := s.ActorProfilesByLogin([]string{login}, window)
+	return single(all, login, err)
+}
+
+// ActorProfilesByLogin summarizes the events of each stored actor of logins in the window.
+func (s *MemoryStore) ActorProfilesByLogin(logins []string, window models.TimeRange) (map[string]models.ActorProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[string]*models.ActorProfile)
+	repos := make(map[string]map[string]*models.RepoActivity)
+	emails := make(map[string]map[string]bool)
+	for _, login := range logins {
+		if _, ok := s.actors[login]; ok {
+			all[login] = &models.ActorProfile{Login: login, EventsByType: map[string]int64{}, Repos: []models.RepoActivity{}, Emails: []string{}}
+			repos[login] = make(map[string]*models.RepoActivity)
+			emails[login] = make(map[string]bool)
+		}
+	}
+
+	for _, event := range s.events {
+		profile, ok := all[event.Actor.Login]
+		if !ok || !window.Contains(event.CreatedAt) {
+			continue
+		}
+		createdAt := event.CreatedAt
+		if profile.FirstSeen == nil || createdAt.Before(*profile.FirstSeen) {
+			profile.FirstSeen = &createdAt
+		}
+		if profile.LastSeen == nil || createdAt.After(*profile.LastSeen) {
+			profile.LastSeen = &createdAt
+		}
+		profile.Events++
+		profile.EventsByType[event.Type]++
+
+		repo, ok := repos[profile.Login][event.Repo.URL]
+		if !ok {
+			repo = &models.RepoActivity{URL: event.Repo.URL}
+			repos[profile.Login][event.Repo.URL] = repo
+		}
+		if createdAt.After(repo.LastSeen) {
+			repo.LastSeen = createdAt
+		}
+		repo.Events++
+
+		for _, email := range commitEmails(event.GitHubEvent) {
+			if email != "" && !emails[profile.Login][email] {
+				emails[profile.Login][email] = true
+				profile.Emails = append(profile.Emails, email)
+			}
+		}
+	}
+
+	result := make(map[string]models.ActorProfile, len(all))
+	for login, profile := range all {
+		for _, repo := range repos[login] {
+			profile.Repos = append(profile.Repos, *repo)
+		}
+		sort.Slice(profile.Repos, func(i, j int) bool {
+			if !profile.Repos[i].LastSeen.Equal(profile.Repos[j].LastSeen) {
+				return profile.Repos[i].LastSeen.After(profile.Repos[j].LastSeen)
+			}
+			return profile.Repos[i].URL < profile.Repos[j].URL
+		})
+		sort.Strings(profile.Emails)
+		result[login] = *profile
+	}
+	return result, nil
+}
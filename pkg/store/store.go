@@ -0,0 +1,116 @@
+// Package store persists collected GitHub events and answers the queries the
+// API is built on. PostgresStore is used in production; MemoryStore keeps
+// everything in process so handlers and the ingestion pipeline can be tested
+// without a database.
+package store
+
+import (
+	"awsomeProject/pkg/models"
+	"time"
+)
+
+// EventStore is the storage used by the fetcher and the API handlers.
+type EventStore interface {
+	// StoreEvent persists a single GitHub event, skipping events whose
+	// GitHub ID is already stored, and reports whether it was inserted.
+	StoreEvent(event models.GitHubEvent) (bool, error)
+	// ImportEvent persists an event unless one with the same GitHubEvent.Key
+	// is stored, and reports whether it was inserted.
+	ImportEvent(event models.GitHubEvent) (bool, error)
+	// StoreIssueUpdate applies an issue lifecycle change.
+	StoreIssueUpdate(update models.IssueUpdate) error
+
+	// Events returns every stored event.
+	Events() ([]models.GitHubEvent, error)
+	// FindEvents returns the events matching the filter with their commit
+	// author emails, oldest first.
+	FindEvents(filter models.EventFilter) ([]models.GitHubEvent, error)
+	// ListEvents returns a page of the events matching the filter ordered by
+	// creation time, newest first if descending, and the key of the next page
+	// (see UniqueActors).
+	ListEvents(filter models.EventFilter, descending bool, page models.Page) ([]models.StoredEvent, string, error)
+	// GetEvent returns the event with the given store ID, or ErrNotFound.
+	GetEvent(id int64) (models.StoredEvent, error)
+	// EventsAfter returns up to limit events matching the filter with a store
+	// ID greater than afterID, in ID order, which is the order of ingestion.
+	EventsAfter(filter models.EventFilter, afterID int64, limit int) ([]models.StoredEvent, error)
+	// LatestActorEvents returns up to limit of the latest events of each of
+	// the actors with the given logins, newest first, keyed by login.
+	LatestActorEvents(logins []string, limit int) (map[string][]models.StoredEvent, error)
+	// LatestRepoEvents returns up to limit of the latest events of each of
+	// the repositories with the given URLs, newest first, keyed by URL.
+	LatestRepoEvents(urls []string, limit int) (map[string][]models.StoredEvent, error)
+	// LastEventID returns the greatest store ID of an event, or 0 if there are none.
+	LastEventID() (int64, error)
+
+	// EventCounts returns the number of stored events per event type in the
+	// window, read from the rollups. Rollups match the window by their bucket
+	// start, so an hour or day rollup counts in full if its bucket starts in it.
+	EventCounts(window models.TimeRange) (map[string]int, error)
+	// CountedWindow returns the time span whose events the rollups matched
+	// by EventCounts, TopActors and TopRepos hold: the window's start moved
+	// past the end of a rollup that starts before it, and its end extended to
+	// the end of the last rollup that starts in it. Unbounded ends stay zero.
+	CountedWindow(window models.TimeRange) (models.TimeRange, error)
+	// EventTimeseries returns the number of events per event type in each
+	// bucket of a rollup resolution that has events matching the filter,
+	// matching the window by bucket start like EventCounts. The filter's
+	// Sources are not applied.
+	EventTimeseries(resolution string, filter models.EventFilter) ([]models.CountPoint, error)
+	// UniqueActors returns a page of the actors with an event in the window,
+	// with their last event time and event count in the window, ordered by
+	// that last event time, most recent first. It also returns the key to
+	// pass as Page.After for the next page, which is empty on the last page. An After that is not such a key
+	// returns ErrInvalidCursor.
+	UniqueActors(window models.TimeRange, page models.Page) ([]models.ActorActivity, string, error)
+	// UniqueRepoURLs returns a page of the repositories with an event in the
+	// window like UniqueActors.
+	UniqueRepoURLs(window models.TimeRange, page models.Page) ([]models.RepoActivity, string, error)
+	// UniqueEmails returns the commit author emails of events in the window.
+	UniqueEmails(window models.TimeRange) ([]string, error)
+	// TopActors returns the limit actors with the most events of the given
+	// types (any if empty) in the window, read from the rollups matched by
+	// bucket start like EventCounts, ordered by event count and then login.
+	// Events of bots are ignored if excludeBots.
+	TopActors(window models.TimeRange, types []string, limit int, excludeBots bool) ([]models.ActorRank, error)
+	// TopRepos returns the limit repositories with the most events like TopActors.
+	TopRepos(window models.TimeRange, types []string, limit int, excludeBots bool) ([]models.RepoRank, error)
+	// RepoStats summarizes the events of a repository in the window, or
+	// returns ErrNotFound for a repository that is not stored.
+	RepoStats(repoURL string, window models.TimeRange) (models.RepoStats, error)
+	// RepoStatsByURL returns RepoStats for each of the urls, omitting
+	// repositories that are not stored, in a fixed number of queries.
+	RepoStatsByURL(urls []string, window models.TimeRange) (map[string]models.RepoStats, error)
+	// ActorProfile summarizes the events of an actor in the window, or
+	// returns ErrNotFound for an actor that is not stored.
+	ActorProfile(login string, window models.TimeRange) (models.ActorProfile, error)
+	// ActorProfilesByLogin returns ActorProfile for each of the logins,
+	// omitting actors that are not stored, in a fixed number of queries.
+	ActorProfilesByLogin(logins []string, window models.TimeRange) (map[string]models.ActorProfile, error)
+	// IssueMetrics returns issue response metrics per repository, optionally
+	// restricted to a single repository URL.
+	IssueMetrics(repoURL string) ([]models.IssueMetrics, error)
+	// IssueBacklog returns the open issue count per repository at the end of
+	// each of the last days days, optionally restricted to a single repository URL.
+	IssueBacklog(repoURL string, days int) ([]models.BacklogPoint, error)
+
+	// Purge deletes the events that have expired under the retention policy
+	// as of now, along with actors, repositories and emails left without
+	// events. With dryRun set nothing is deleted and the report says what would be.
+	Purge(policy models.RetentionPolicy, now time.Time, dryRun bool) (models.PurgeReport, error)
+	// ExpiredEvents returns the events Purge would delete under the policy as of now.
+	ExpiredEvents(policy models.RetentionPolicy, now time.Time) ([]models.GitHubEvent, error)
+	// RecordPurge stores an audit record of a purge.
+	RecordPurge(report models.PurgeReport) error
+	// CompactRollups downsamples the rollups according to the tiers as of now.
+	CompactRollups(tiers models.RollupTiers, now time.Time) (models.CompactionReport, error)
+
+	// Erase removes or pseudonymizes every stored trace of an actor login or
+	// commit author email and records a tombstone so later events are scrubbed.
+	Erase(request models.ErasureRequest, now time.Time) (models.ErasureReport, error)
+}
+
+var (
+	_ EventStore = (*PostgresStore)(nil)
+	_ EventStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example handshake from RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected accept key %s", got)
+	}
+}
+
+// echo upgrades requests and echoes their messages until the client closes.
+func echo(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, []string{"https://allowed.example"})
+		if err != nil {
+			return
+		}
+		conn.SetReadLimit(1 << 10)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestEcho(t *testing.T) {
+	server := echo(t)
+	defer server.Close()
+
+	conn, err := Dial("ws" + strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatalf("Error dialing: %v", err)
+	}
+
+	for _, message := range [][]byte{[]byte("hello"), bytes.Repeat([]byte("x"), 200), bytes.Repeat([]byte("y"), 1000)} {
+		if err := conn.WriteMessage(TextMessage, message); err != nil {
+			t.Fatal(err)
+		}
+		messageType, data, err := conn.ReadMessage()
+		if err != nil || messageType != TextMessage || !bytes.Equal(data, message) {
+			t.Fatalf("Expected the %d byte message echoed, got %d bytes (%v)", len(message), len(data), err)
+		}
+	}
+
+	// Pings are answered without surfacing as messages
+	conn.WriteMessage(PingMessage, []byte("ping"))
+	conn.WriteMessage(BinaryMessage, []byte{1, 2, 3})
+	if messageType, data, err := conn.ReadMessage(); err != nil || messageType != BinaryMessage || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Errorf("Expected the binary message after the pong, got %d %v %v", messageType, data, err)
+	}
+
+	// Messages over the server's limit close the connection
+	conn.WriteMessage(TextMessage, bytes.Repeat([]byte("z"), 2000))
+	_, _, err = conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("Expected a close for a message over the limit, got %v", err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	server := echo(t)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a request without an upgrade, got %d", resp.StatusCode)
+	}
+}
+
+func TestUpgradeChecksOrigin(t *testing.T) {
+	for origin, allowed := range map[string]bool{
+		"":                        true,
+		"http://api.example:8080": true,
+		"https://allowed.example": true,
+		"https://ALLOWED.example": true,
+		"https://evil.example":    false,
+		"http://api.example":      false,
+		"null":                    false,
+	} {
+		req := httptest.NewRequest("GET", "http://api.example:8080/events/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if got := CheckOrigin(req, []string{"https://allowed.example/"}); got != allowed {
+			t.Errorf("Expected origin %q allowed to be %v", origin, allowed)
+		}
+
+		// Allowed origins get past the check to the hijack the recorder does not support
+		rr := httptest.NewRecorder()
+		_, err := Upgrade(rr, req, []string{"https://allowed.example/"})
+		if allowed && rr.Code != http.StatusInternalServerError || !allowed && (rr.Code != http.StatusForbidden || !errors.Is(err, errBadOrigin)) {
+			t.Errorf("Unexpected upgrade of origin %q: %d %v", origin, rr.Code, err)
+		}
+	}
+}